@@ -0,0 +1,210 @@
+package broker
+
+import (
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/logger"
+)
+
+var (
+	// DefaultBatchSize is the number of messages which flushes a batch unless set by BatchSize
+	DefaultBatchSize = 100
+	// DefaultBatchWait is how long a batch waits to fill unless set by BatchWait
+	DefaultBatchWait = time.Second
+)
+
+// BatchHandler handles the messages of a batch subscription, see SubscribeBatch
+type BatchHandler func([]Event) error
+
+// Batcher is implemented by brokers which publish and consume messages in batches
+type Batcher interface {
+	// PublishBatch publishes the messages to the topic in one round trip
+	PublishBatch(topic string, msgs []*Message, opts ...PublishOption) error
+	// SubscribeBatch hands the messages of the topic to the handler in batches,
+	// flushed once BatchSize messages are waiting or the oldest has waited BatchWait
+	SubscribeBatch(topic string, h BatchHandler, opts ...SubscribeOption) (Subscriber, error)
+}
+
+// PublishBatch publishes the messages to the topic in one round trip if the broker
+// is a Batcher, otherwise one by one
+func PublishBatch(b Broker, topic string, msgs []*Message, opts ...PublishOption) error {
+	if bb, ok := b.(Batcher); ok {
+		return bb.PublishBatch(topic, msgs, opts...)
+	}
+	for _, msg := range msgs {
+		if err := b.Publish(topic, msg, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubscribeBatch hands the messages of the topic to the handler in batches. Brokers which
+// aren't a Batcher have their messages buffered as they are received and acked once the
+// batch is handled.
+func SubscribeBatch(b Broker, topic string, h BatchHandler, opts ...SubscribeOption) (Subscriber, error) {
+	if bb, ok := b.(Batcher); ok {
+		return bb.SubscribeBatch(topic, h, opts...)
+	}
+
+	options := NewSubscribeOptions(opts...)
+	bt := newBatcher(options, h, b.Options().ErrorHandler)
+
+	// ack once the batch is handled rather than when it is buffered
+	sub, err := b.Subscribe(topic, func(e Event) error {
+		return bt.add(e)
+	}, append(opts, DisableAutoAck())...)
+	if err != nil {
+		bt.close()
+		return nil, err
+	}
+
+	return &batchSubscriber{Subscriber: sub, b: bt}, nil
+}
+
+type batchSubscriber struct {
+	Subscriber
+	b *batcher
+}
+
+func (s *batchSubscriber) Unsubscribe() error {
+	err := s.Subscriber.Unsubscribe()
+	s.b.close()
+	return err
+}
+
+// batchEvent carries the error of the batch it was handled in
+type batchEvent struct {
+	Event
+	err error
+}
+
+func (e *batchEvent) Error() error {
+	return e.err
+}
+
+// batcher buffers events until the batch is full or has waited long enough
+type batcher struct {
+	opts    SubscribeOptions
+	handler BatchHandler
+	// errors of batches flushed by time are passed to the error handler
+	errorHandler Handler
+
+	sync.Mutex
+	events []Event
+	timer  *time.Timer
+	closed bool
+
+	// hmtx hands batches to the handler one at a time, in order
+	hmtx sync.Mutex
+}
+
+func newBatcher(opts SubscribeOptions, h BatchHandler, eh Handler) *batcher {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1
+	}
+	return &batcher{
+		opts:         opts,
+		handler:      h,
+		errorHandler: eh,
+	}
+}
+
+// add buffers the events, handling the batch once it is full.
+// The error is that of the batch handled, if any.
+func (b *batcher) add(evs ...Event) error {
+	b.Lock()
+	b.events = append(b.events, evs...)
+
+	if len(b.events) < b.opts.BatchSize {
+		// the first event of a batch starts the wait
+		if b.timer == nil && len(b.events) > 0 {
+			b.timer = time.AfterFunc(b.opts.BatchWait, b.expire)
+		}
+		b.Unlock()
+		return nil
+	}
+
+	var err error
+	for len(b.events) >= b.opts.BatchSize {
+		if _, e := b.flush(b.opts.BatchSize); e != nil {
+			err = e
+		}
+		b.Lock()
+	}
+	if b.timer == nil && len(b.events) > 0 {
+		b.timer = time.AfterFunc(b.opts.BatchWait, b.expire)
+	}
+	b.Unlock()
+
+	return err
+}
+
+// expire flushes the batch once it has waited long enough
+func (b *batcher) expire() {
+	b.Lock()
+	b.handleError(b.flush(len(b.events)))
+}
+
+// flush hands up to n events to the handler, it is called locked and returns unlocked
+func (b *batcher) flush(n int) ([]Event, error) {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if n > len(b.events) {
+		n = len(b.events)
+	}
+	if n == 0 {
+		b.Unlock()
+		return nil, nil
+	}
+
+	evs := b.events[:n:n]
+	b.events = b.events[n:]
+
+	// take the handler before releasing the buffer to keep batches in order
+	b.hmtx.Lock()
+	b.Unlock()
+	defer b.hmtx.Unlock()
+
+	if err := b.handler(evs); err != nil {
+		return evs, err
+	}
+
+	if b.opts.AutoAck {
+		for _, e := range evs {
+			e.Ack()
+		}
+	}
+
+	return evs, nil
+}
+
+// handleError reports the error of a batch flushed when no publish is waiting on it
+func (b *batcher) handleError(evs []Event, err error) {
+	if err == nil {
+		return
+	}
+	if b.errorHandler == nil {
+		if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("[broker] batch handler error: %v", err)
+		}
+		return
+	}
+	for _, e := range evs {
+		b.errorHandler(&batchEvent{Event: e, err: err})
+	}
+}
+
+// close flushes the waiting events and stops the batcher
+func (b *batcher) close() {
+	b.Lock()
+	if b.closed {
+		b.Unlock()
+		return
+	}
+	b.closed = true
+	b.handleError(b.flush(len(b.events)))
+}
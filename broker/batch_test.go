@@ -0,0 +1,104 @@
+package broker_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/registry"
+)
+
+// plainBroker hides the batch methods of the broker it wraps
+type plainBroker struct {
+	broker.Broker
+}
+
+func testBatch(t *testing.T, b broker.Broker) {
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer b.Disconnect()
+
+	batches := make(chan []broker.Event, 10)
+	sub, err := broker.SubscribeBatch(b, "telemetry", func(evs []broker.Event) error {
+		batches <- evs
+		return nil
+	}, broker.BatchSize(3), broker.BatchWait(time.Second))
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	single := make(chan *broker.Message, 10)
+	ssub, err := b.Subscribe("telemetry", func(e broker.Event) error {
+		single <- e.Message()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	defer ssub.Unsubscribe()
+
+	var msgs []*broker.Message
+	for i := 0; i < 4; i++ {
+		msgs = append(msgs, &broker.Message{
+			Header: map[string]string{"seq": fmt.Sprintf("%d", i)},
+			Body:   []byte(fmt.Sprintf("reading %d", i)),
+		})
+	}
+	if err := broker.PublishBatch(b, "telemetry", msgs); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	// a full batch is flushed by size, the rest once it has waited
+	for _, size := range []int{3, 1} {
+		select {
+		case evs := <-batches:
+			if len(evs) != size {
+				t.Fatalf("Expected a batch of %d, got %d", size, len(evs))
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Timed out waiting for a batch of %d", size)
+		}
+	}
+
+	// other subscribers receive the messages one by one, in order
+	for i := 0; i < 4; i++ {
+		select {
+		case m := <-single:
+			if m.Header["seq"] != fmt.Sprintf("%d", i) || string(m.Body) != fmt.Sprintf("reading %d", i) {
+				t.Fatalf("Unexpected message %d: %v %s", i, m.Header, m.Body)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Timed out waiting for message %d", i)
+		}
+	}
+
+	// messages published one by one are batched too
+	for i := 0; i < 3; i++ {
+		if err := b.Publish("telemetry", &broker.Message{Body: []byte("reading")}); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+	select {
+	case evs := <-batches:
+		if len(evs) != 3 {
+			t.Fatalf("Expected a batch of 3, got %d", len(evs))
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for a batch")
+	}
+}
+
+func TestMemoryBatch(t *testing.T) {
+	testBatch(t, broker.NewMemoryBroker())
+}
+
+func TestHTTPBatch(t *testing.T) {
+	testBatch(t, broker.NewBroker(broker.Registry(registry.NewMemoryRegistry())))
+}
+
+func TestBatchFallback(t *testing.T) {
+	testBatch(t, &plainBroker{broker.NewMemoryBroker()})
+}
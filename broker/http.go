@@ -19,6 +19,7 @@ import (
 	"github.com/google/uuid"
 	"go-micro.dev/v4/codec/json"
 	merr "go-micro.dev/v4/errors"
+	"go-micro.dev/v4/logger"
 	"go-micro.dev/v4/registry"
 	"go-micro.dev/v4/registry/cache"
 	maddr "go-micro.dev/v4/util/addr"
@@ -42,10 +43,15 @@ type httpBroker struct {
 	subscribers map[string][]*httpSubscriber
 	running     bool
 	exit        chan chan error
-
-	// offline message inbox
-	mtx   sync.RWMutex
-	inbox map[string][][]byte
+	// dispatchExit is closed on disconnect, stopping the dispatch of
+	// scheduled messages and retries of spooled ones
+	dispatchExit chan bool
+
+	// topics with a retry of spooled messages scheduled
+	mtx      sync.Mutex
+	retrying map[string]bool
+	// flushing holds a lock per topic, serializing flushes of its spool
+	flushing map[string]*sync.Mutex
 }
 
 type httpSubscriber struct {
@@ -53,8 +59,13 @@ type httpSubscriber struct {
 	id    string
 	topic string
 	fn    Handler
+	// batch buffers the messages of batch subscribers instead of fn
+	batch *batcher
 	svc   *registry.Service
 	hb    *httpBroker
+	// gate holds back deliveries while the subscriber is paused,
+	// keeping the publisher waiting on the request
+	gate pauseGate
 }
 
 type httpEvent struct {
@@ -72,6 +83,9 @@ var (
 	registerInterval = time.Second * 30
 )
 
+// batchHeader marks a message whose body is a batch of messages, see PublishBatch
+const batchHeader = "Micro-Batch"
+
 func init() {
 	rand.Seed(time.Now().Unix())
 }
@@ -117,6 +131,13 @@ func newHttpBroker(opts ...Option) Broker {
 		o(&options)
 	}
 
+	if options.Spool == nil {
+		options.Spool = NewMemorySpool()
+	}
+	if options.Scheduler == nil {
+		options.Scheduler = NewMemoryScheduler()
+	}
+
 	// set address
 	addr := DefaultAddress
 
@@ -133,7 +154,8 @@ func newHttpBroker(opts ...Option) Broker {
 		subscribers: make(map[string][]*httpSubscriber),
 		exit:        make(chan chan error),
 		mux:         http.NewServeMux(),
-		inbox:       make(map[string][][]byte),
+		retrying:    make(map[string]bool),
+		flushing:    make(map[string]*sync.Mutex),
 	}
 
 	// specify the message handler
@@ -176,51 +198,233 @@ func (h *httpSubscriber) Topic() string {
 	return h.topic
 }
 
+// Pause holds back messages sent to the subscriber until it is resumed
+func (h *httpSubscriber) Pause() error {
+	h.gate.pause()
+	return nil
+}
+
+func (h *httpSubscriber) Resume() error {
+	h.gate.resume()
+	return nil
+}
+
 func (h *httpSubscriber) Unsubscribe() error {
-	return h.hb.unsubscribe(h)
+	err := h.hb.unsubscribe(h)
+	// release deliveries held back by a pause
+	h.gate.resume()
+	// hand over the messages waiting in the batch
+	if h.batch != nil {
+		h.batch.close()
+	}
+	return err
 }
 
-func (h *httpBroker) saveMessage(topic string, msg []byte) {
-	h.mtx.Lock()
-	defer h.mtx.Unlock()
+// flush sends the messages spooled for the topic in order, removing each once sent.
+// It stops at the first message which fails, leaving it at the front of the spool
+// to be retried, and returns false.
+func (h *httpBroker) flush(topic string, services []*registry.Service) bool {
+	spool := h.opts.Spool
 
-	// get messages
-	c := h.inbox[topic]
+	// a message is sent once even if a publish and a retry flush together
+	lock := h.flushLock(topic)
+	lock.Lock()
+	defer lock.Unlock()
 
-	// save message
-	c = append(c, msg)
+	// get a batch of the backlog
+	messages, err := spool.Peek(topic, 8)
+	if err != nil {
+		return false
+	}
 
-	// max length 64
-	if len(c) > 64 {
-		c = c[:64]
+	// publish all the messages
+	for _, msg := range messages {
+		// serialize here
+		if !h.send(topic, services, msg.Body) {
+			return false
+		}
+		if err := spool.Ack(topic, msg.Seq); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("[http] failed to remove sent message for %v from the spool: %v", topic, err)
+		}
 	}
 
-	// save inbox
-	h.inbox[topic] = c
+	return true
 }
 
-func (h *httpBroker) getMessage(topic string, num int) [][]byte {
+// flushLock returns the lock serializing flushes of the topic
+func (h *httpBroker) flushLock(topic string) *sync.Mutex {
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
 
-	// get messages
-	c, ok := h.inbox[topic]
+	lock, ok := h.flushing[topic]
 	if !ok {
+		lock = new(sync.Mutex)
+		h.flushing[topic] = lock
+	}
+	return lock
+}
+
+// send the message to the nodes subscribed to the topic, returning false if it failed
+func (h *httpBroker) send(topic string, services []*registry.Service, b []byte) bool {
+	pub := func(node *registry.Node, t string, b []byte) error {
+		scheme := "http"
+
+		// check if secure is added in metadata
+		if node.Metadata["secure"] == "true" {
+			scheme = "https"
+		}
+
+		vals := url.Values{}
+		vals.Add("id", node.Id)
+
+		uri := fmt.Sprintf("%s://%s%s?%s", scheme, node.Address, DefaultPath, vals.Encode())
+		r, err := h.c.Post(uri, "application/json", bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+
+		// discard response body
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
 		return nil
 	}
 
-	// more message than requests
-	if len(c) >= num {
-		msg := c[:num]
-		h.inbox[topic] = c[num:]
-		return msg
+	ok := true
+
+	for _, service := range services {
+		var nodes []*registry.Node
+
+		for _, node := range service.Nodes {
+			// only use nodes tagged with broker http
+			if node.Metadata["broker"] != "http" {
+				continue
+			}
+
+			// look for nodes for the topic
+			if node.Metadata["topic"] != topic {
+				// or subscribed to a pattern matching it
+				if node.Metadata["wildcard"] != "true" || !MatchTopic(node.Metadata["topic"], topic) {
+					continue
+				}
+			}
+
+			nodes = append(nodes, node)
+		}
+
+		// only process if we have nodes
+		if len(nodes) == 0 {
+			continue
+		}
+
+		switch service.Version {
+		// broadcast version means broadcast to all nodes
+		case broadcastVersion:
+			var success bool
+
+			// publish to all nodes
+			for _, node := range nodes {
+				// publish async
+				if err := pub(node, topic, b); err == nil {
+					success = true
+				}
+			}
+
+			// failed if it didn't publish at least once
+			if !success {
+				ok = false
+			}
+		default:
+			// select node to publish to
+			node := nodes[rand.Int()%len(nodes)]
+
+			// publish async to one node
+			if err := pub(node, topic, b); err != nil {
+				ok = false
+			}
+		}
+	}
+
+	return ok
+}
+
+// retry sends the messages spooled for the topic with backoff until none are left,
+// the broker disconnects or the spool's retries run out. Messages left in the spool
+// are sent with the next publish to the topic.
+func (h *httpBroker) retry(topic string) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.retrying[topic] {
+		return
+	}
+	h.retrying[topic] = true
+
+	spool := h.opts.Spool
+
+	// done stops retrying once the spool is empty, checked under the lock
+	// so a failure which misses the running retry schedules a new one
+	done := func() bool {
+		h.mtx.Lock()
+		defer h.mtx.Unlock()
+		if spool.Stats().Depth[topic] > 0 {
+			return false
+		}
+		delete(h.retrying, topic)
+		return true
+	}
+
+	// stop clears the retry so the next failure schedules a new one
+	stop := func() {
+		h.mtx.Lock()
+		delete(h.retrying, topic)
+		h.mtx.Unlock()
 	}
 
-	// reset inbox
-	h.inbox[topic] = nil
+	go func() {
+		h.RLock()
+		exit := h.dispatchExit
+		h.RUnlock()
+
+		for attempts := 1; ; attempts++ {
+			if max := spool.Options().Retries; max > 0 && attempts > max {
+				stop()
+				return
+			}
 
-	// return all messages
-	return c
+			// stop waiting once disconnected
+			t := time.NewTimer(spool.Options().Backoff(attempts))
+			select {
+			case <-t.C:
+			case <-exit:
+				t.Stop()
+			}
+
+			h.RLock()
+			running := h.running
+			h.RUnlock()
+
+			// spooled messages are sent with the next publish once connected again
+			if !running {
+				stop()
+				return
+			}
+
+			if done() {
+				return
+			}
+
+			h.RLock()
+			s, err := h.r.GetService(serviceName)
+			h.RUnlock()
+			if err != nil {
+				continue
+			}
+
+			if h.flush(topic, s) && done() {
+				return
+			}
+		}
+	}()
 }
 
 func (h *httpBroker) subscribe(s *httpSubscriber) error {
@@ -325,24 +529,57 @@ func (h *httpBroker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	p := &httpEvent{m: m, t: topic}
+	// messages published together are handed to batch subscribers together
+	msgs := []*Message{m}
+	if _, ok := m.Header[batchHeader]; ok {
+		msgs = nil
+		if err = h.opts.Codec.Unmarshal(m.Body, &msgs); err != nil {
+			errr := merr.InternalServerError("go.micro.broker", "Error parsing batch: %v", err)
+			w.WriteHeader(500)
+			w.Write([]byte(errr.Error()))
+			return
+		}
+	}
+
+	evs := make([]Event, 0, len(msgs))
+	for _, msg := range msgs {
+		evs = append(evs, &httpEvent{m: msg, t: topic})
+	}
+
 	id := req.Form.Get("id")
 
 	//nolint:prealloc
-	var subs []Handler
+	var subs []*httpSubscriber
 
 	h.RLock()
-	for _, subscriber := range h.subscribers[topic] {
-		if id != subscriber.id {
-			continue
+	for pattern, subscribers := range h.subscribers {
+		for _, subscriber := range subscribers {
+			if id != subscriber.id {
+				continue
+			}
+			// wildcard subscribers are kept under their pattern
+			if pattern != topic && (!subscriber.opts.Wildcard || !MatchTopic(pattern, topic)) {
+				continue
+			}
+			subs = append(subs, subscriber)
 		}
-		subs = append(subs, subscriber.fn)
 	}
 	h.RUnlock()
 
 	// execute the handler
-	for _, fn := range subs {
-		p.err = fn(p)
+	for _, sub := range subs {
+		sub.gate.wait()
+
+		if sub.batch != nil {
+			if err := sub.batch.add(evs...); err != nil {
+				sub.batch.handleError(evs, err)
+			}
+			continue
+		}
+		for _, e := range evs {
+			p := e.(*httpEvent)
+			p.err = sub.fn(p)
+		}
 	}
 }
 
@@ -425,6 +662,12 @@ func (h *httpBroker) Connect() error {
 	// set cache
 	h.r = cache.New(reg)
 
+	// send scheduled messages, including those due while disconnected
+	h.dispatchExit = make(chan bool)
+	go dispatch(h.opts.Scheduler, func(topic string, msg *Message) error {
+		return h.Publish(topic, msg)
+	}, h.dispatchExit)
+
 	// set running
 	h.running = true
 	return nil
@@ -447,6 +690,9 @@ func (h *httpBroker) Disconnect() error {
 		rc.Stop()
 	}
 
+	// stop sending scheduled messages and retrying spooled ones
+	close(h.dispatchExit)
+
 	// exit and return err
 	ch := make(chan error)
 	h.exit <- ch
@@ -509,6 +755,18 @@ func (h *httpBroker) Options() Options {
 }
 
 func (h *httpBroker) Publish(topic string, msg *Message, opts ...PublishOption) error {
+	options := PublishOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// hold delayed messages until they are due
+	if at := options.DeliverTime(); at.After(time.Now()) {
+		return h.opts.Scheduler.Schedule(topic, msg, at)
+	}
+
 	// create the message first
 	m := &Message{
 		Header: make(map[string]string),
@@ -527,109 +785,82 @@ func (h *httpBroker) Publish(topic string, msg *Message, opts ...PublishOption)
 		return err
 	}
 
-	// save the message
-	h.saveMessage(topic, b)
+	return h.publish(topic, b, options)
+}
 
-	// now attempt to get the service
-	h.RLock()
-	s, err := h.r.GetService(serviceName)
-	if err != nil {
-		h.RUnlock()
-		return err
+// PublishBatch sends the messages to each subscriber in one request
+func (h *httpBroker) PublishBatch(topic string, msgs []*Message, opts ...PublishOption) error {
+	options := PublishOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
 	}
-	h.RUnlock()
-
-	pub := func(node *registry.Node, t string, b []byte) error {
-		scheme := "http"
-
-		// check if secure is added in metadata
-		if node.Metadata["secure"] == "true" {
-			scheme = "https"
-		}
-
-		vals := url.Values{}
-		vals.Add("id", node.Id)
 
-		uri := fmt.Sprintf("%s://%s%s?%s", scheme, node.Address, DefaultPath, vals.Encode())
-		r, err := h.c.Post(uri, "application/json", bytes.NewReader(b))
-		if err != nil {
-			return err
+	// hold delayed messages until they are due
+	if at := options.DeliverTime(); at.After(time.Now()) {
+		for _, msg := range msgs {
+			if err := h.opts.Scheduler.Schedule(topic, msg, at); err != nil {
+				return err
+			}
 		}
-
-		// discard response body
-		io.Copy(io.Discard, r.Body)
-		r.Body.Close()
 		return nil
 	}
 
-	srv := func(s []*registry.Service, b []byte) {
-		for _, service := range s {
-			var nodes []*registry.Node
-
-			for _, node := range service.Nodes {
-				// only use nodes tagged with broker http
-				if node.Metadata["broker"] != "http" {
-					continue
-				}
-
-				// look for nodes for the topic
-				if node.Metadata["topic"] != topic {
-					continue
-				}
+	batch := make([]*Message, 0, len(msgs))
+	for _, msg := range msgs {
+		m := &Message{
+			Header: make(map[string]string),
+			Body:   msg.Body,
+		}
+		for k, v := range msg.Header {
+			m.Header[k] = v
+		}
+		m.Header["Micro-Topic"] = topic
+		batch = append(batch, m)
+	}
 
-				nodes = append(nodes, node)
-			}
+	body, err := h.opts.Codec.Marshal(batch)
+	if err != nil {
+		return err
+	}
 
-			// only process if we have nodes
-			if len(nodes) == 0 {
-				continue
-			}
+	// the batch travels as one message so it is spooled and retried as one
+	b, err := h.opts.Codec.Marshal(&Message{
+		Header: map[string]string{
+			"Micro-Topic": topic,
+			batchHeader:   fmt.Sprintf("%d", len(batch)),
+		},
+		Body: body,
+	})
+	if err != nil {
+		return err
+	}
 
-			switch service.Version {
-			// broadcast version means broadcast to all nodes
-			case broadcastVersion:
-				var success bool
+	return h.publish(topic, b, options)
+}
 
-				// publish to all nodes
-				for _, node := range nodes {
-					// publish async
-					if err := pub(node, topic, b); err == nil {
-						success = true
-					}
-				}
+// publish spools the encoded message and sends it
+func (h *httpBroker) publish(topic string, b []byte, options PublishOptions) error {
+	// spool the message, it is removed once sent
+	if _, err := h.opts.Spool.Push(options.Context, topic, b); err != nil {
+		return err
+	}
 
-				// save if it failed to publish at least once
-				if !success {
-					h.saveMessage(topic, b)
-				}
-			default:
-				// select node to publish to
-				node := nodes[rand.Int()%len(nodes)]
-
-				// publish async to one node
-				if err := pub(node, topic, b); err != nil {
-					// if failed save it
-					h.saveMessage(topic, b)
-				}
-			}
-		}
+	// now attempt to get the service
+	h.RLock()
+	s, err := h.r.GetService(serviceName)
+	if err != nil {
+		h.RUnlock()
+		h.retry(topic)
+		return err
 	}
+	h.RUnlock()
 
 	// do the rest async
 	go func() {
-		// get a third of the backlog
-		messages := h.getMessage(topic, 8)
-		delay := (len(messages) > 1)
-
-		// publish all the messages
-		for _, msg := range messages {
-			// serialize here
-			srv(s, msg)
-
-			// sending a backlog of messages
-			if delay {
-				time.Sleep(time.Millisecond * 100)
-			}
+		if !h.flush(topic, s) {
+			h.retry(topic)
 		}
 	}()
 
@@ -637,9 +868,19 @@ func (h *httpBroker) Publish(topic string, msg *Message, opts ...PublishOption)
 }
 
 func (h *httpBroker) Subscribe(topic string, handler Handler, opts ...SubscribeOption) (Subscriber, error) {
+	options := NewSubscribeOptions(opts...)
+	return h.newSubscriber(topic, handler, nil, options)
+}
+
+// SubscribeBatch hands the messages of the topic to the handler in batches
+func (h *httpBroker) SubscribeBatch(topic string, handler BatchHandler, opts ...SubscribeOption) (Subscriber, error) {
+	options := NewSubscribeOptions(opts...)
+	return h.newSubscriber(topic, nil, newBatcher(options, handler, h.opts.ErrorHandler), options)
+}
+
+func (h *httpBroker) newSubscriber(topic string, handler Handler, batch *batcher, options SubscribeOptions) (Subscriber, error) {
 	var err error
 	var host, port string
-	options := NewSubscribeOptions(opts...)
 
 	// parse address for host, port
 	host, port, err = net.SplitHostPort(h.Address())
@@ -668,6 +909,9 @@ func (h *httpBroker) Subscribe(topic string, handler Handler, opts ...SubscribeO
 			"topic":  topic,
 		},
 	}
+	if options.Wildcard {
+		node.Metadata["wildcard"] = "true"
+	}
 
 	// check for queue group or broadcast queue
 	version := options.Queue
@@ -688,6 +932,7 @@ func (h *httpBroker) Subscribe(topic string, handler Handler, opts ...SubscribeO
 		id:    node.Id,
 		topic: topic,
 		fn:    handler,
+		batch: batch,
 		svc:   service,
 	}
 
@@ -1,7 +1,11 @@
 package broker_test
 
 import (
+	"encoding/json"
+	"net"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -233,6 +237,215 @@ func TestBroker(t *testing.T) {
 	}
 }
 
+func TestBrokerSpoolRetry(t *testing.T) {
+	m := registry.NewMemoryRegistry()
+	spool := broker.NewMemorySpool(broker.SpoolBackoff(func(int) time.Duration {
+		return time.Millisecond * 50
+	}))
+	b := broker.NewBroker(broker.Registry(m), broker.OutboundSpool(spool))
+	topic := uuid.New().String()
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer b.Disconnect()
+
+	// a subscriber which isn't listening yet
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected listen error: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	if err := m.Register(&registry.Service{
+		Name:    "micro.http.broker",
+		Version: "ff.http.broadcast",
+		Nodes: []*registry.Node{{
+			Id:       topic + "-offline",
+			Address:  addr,
+			Metadata: map[string]string{"broker": "http", "topic": topic},
+		}},
+	}); err != nil {
+		t.Fatalf("Unexpected register error: %v", err)
+	}
+
+	want := []string{"1", "2", "3"}
+	for _, body := range want {
+		if err := b.Publish(topic, &broker.Message{Body: []byte(body)}); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+
+	// the messages stay spooled while they fail, other than while a retry sends them
+	var depth int
+	for i := 0; i < 200 && depth != len(want); i++ {
+		time.Sleep(time.Millisecond * 5)
+		depth = spool.Stats().Depth[topic]
+	}
+	if depth != len(want) {
+		t.Fatalf("Expected the failed messages to be spooled, got depth %d", depth)
+	}
+
+	// and are retried in order until the subscriber comes online
+	received := make(chan string, len(want))
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Unexpected listen error: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m broker.Message
+		if err := json.NewDecoder(r.Body).Decode(&m); err == nil {
+			received <- string(m.Body)
+		}
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	for _, body := range want {
+		select {
+		case got := <-received:
+			if got != body {
+				t.Fatalf("Expected message %s, got %s", body, got)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out waiting for the spooled messages")
+		}
+	}
+}
+
+func TestBrokerSpoolBurst(t *testing.T) {
+	m := registry.NewMemoryRegistry()
+	spool := broker.NewMemorySpool()
+	b := broker.NewBroker(broker.Registry(m), broker.OutboundSpool(spool))
+	topic := uuid.New().String()
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer b.Disconnect()
+
+	// a burst just short of the spool size, published at once
+	n := 60
+	received := make(chan bool, n)
+	sub, err := b.Subscribe(topic, func(p broker.Event) error {
+		received <- true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if err := b.Publish(topic, &broker.Message{Body: []byte("burst")}); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		select {
+		case <-received:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Timed out after %d of %d messages", i, n)
+		}
+	}
+
+	if d := time.Since(start); d > time.Second*2 {
+		t.Fatalf("Expected the burst to be sent within 2s, took %v", d)
+	}
+	if dropped := spool.Stats().Dropped[topic]; dropped > 0 {
+		t.Fatalf("Expected no messages dropped, got %d", dropped)
+	}
+}
+
+// lookupCounter counts the service lookups made through the registry
+type lookupCounter struct {
+	registry.Registry
+	lookups int32
+}
+
+func (l *lookupCounter) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	atomic.AddInt32(&l.lookups, 1)
+	return l.Registry.GetService(name, opts...)
+}
+
+func TestBrokerSpoolRetries(t *testing.T) {
+	// nothing is subscribed, so the lookup of the subscribers fails
+	m := &lookupCounter{Registry: registry.NewMemoryRegistry()}
+	spool := broker.NewMemorySpool(broker.SpoolRetries(2), broker.SpoolBackoff(func(int) time.Duration {
+		return time.Millisecond * 10
+	}))
+	b := broker.NewBroker(broker.Registry(m), broker.OutboundSpool(spool))
+	topic := uuid.New().String()
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer b.Disconnect()
+
+	if err := b.Publish(topic, &broker.Message{Body: []byte("1")}); err == nil {
+		t.Fatal("Expected the publish to fail without subscribers")
+	}
+
+	// the publish and its retries look up the subscribers, then the retry gives up
+	time.Sleep(time.Millisecond * 200)
+	lookups := atomic.LoadInt32(&m.lookups)
+	if lookups != 3 {
+		t.Fatalf("Expected a lookup by the publish and each of 2 retries, got %d", lookups)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if got := atomic.LoadInt32(&m.lookups); got != lookups {
+		t.Fatalf("Expected the retries to stop, got %d more lookups", got-lookups)
+	}
+
+	// the message stays spooled for the next publish
+	if depth := spool.Stats().Depth[topic]; depth != 1 {
+		t.Fatalf("Expected the message to stay spooled, got depth %d", depth)
+	}
+}
+
+func TestBrokerWildcard(t *testing.T) {
+	b := broker.NewBroker(broker.Registry(registry.NewMemoryRegistry()))
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer b.Disconnect()
+
+	done := make(chan string, 2)
+	sub, err := b.Subscribe("orders.*", func(p broker.Event) error {
+		done <- p.Topic()
+		return nil
+	}, broker.Wildcard())
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for _, topic := range []string{"orders.eu.created", "orders.created"} {
+		if err := b.Publish(topic, &broker.Message{Body: []byte("hello")}); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+
+	select {
+	case topic := <-done:
+		if topic != "orders.created" {
+			t.Fatalf("Expected orders.created, got %v", topic)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for message")
+	}
+
+	select {
+	case topic := <-done:
+		t.Fatalf("Unexpected message on %v", topic)
+	case <-time.After(time.Millisecond * 200):
+	}
+}
+
 func TestConcurrentSubBroker(t *testing.T) {
 	m := newTestRegistry()
 	b := broker.NewBroker(broker.Registry(m))
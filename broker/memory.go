@@ -21,6 +21,9 @@ type memoryBroker struct {
 	sync.RWMutex
 	connected   bool
 	Subscribers map[string][]*memorySubscriber
+
+	// exit stops dispatching scheduled messages
+	exit chan bool
 }
 
 type memoryEvent struct {
@@ -35,7 +38,11 @@ type memorySubscriber struct {
 	topic   string
 	exit    chan bool // 标识取消订阅
 	handler Handler
-	opts    SubscribeOptions
+	// batch buffers the messages of batch subscribers instead of the handler
+	batch *batcher
+	opts  SubscribeOptions
+	// gate holds back deliveries while the subscriber is paused
+	gate pauseGate
 }
 
 func (m *memoryBroker) Options() Options {
@@ -66,6 +73,11 @@ func (m *memoryBroker) Connect() error {
 	m.addr = addr
 	m.connected = true
 
+	m.exit = make(chan bool)
+	go dispatch(m.opts.Scheduler, func(topic string, msg *Message) error {
+		return m.Publish(topic, msg)
+	}, m.exit)
+
 	return nil
 }
 
@@ -78,6 +90,7 @@ func (m *memoryBroker) Disconnect() error {
 	}
 
 	m.connected = false
+	close(m.exit)
 
 	return nil
 }
@@ -90,43 +103,99 @@ func (m *memoryBroker) Init(opts ...Option) error {
 }
 
 func (m *memoryBroker) Publish(topic string, msg *Message, opts ...PublishOption) error {
+	return m.publish(topic, []*Message{msg}, opts...)
+}
+
+// PublishBatch hands the messages to batch subscribers together
+func (m *memoryBroker) PublishBatch(topic string, msgs []*Message, opts ...PublishOption) error {
+	return m.publish(topic, msgs, opts...)
+}
+
+func (m *memoryBroker) publish(topic string, msgs []*Message, opts ...PublishOption) error {
+	var options PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
 	m.RLock()
 	if !m.connected {
 		m.RUnlock()
 		return errors.New("not connected")
 	}
 
-	subs, ok := m.Subscribers[topic]
-	m.RUnlock()
-	if !ok {
+	// hold delayed messages until they are due
+	if at := options.DeliverTime(); at.After(time.Now()) {
+		m.RUnlock()
+		for _, msg := range msgs {
+			if err := m.opts.Scheduler.Schedule(topic, msg, at); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
 
-	var v interface{}
-	if m.opts.Codec != nil {
-		buf, err := m.opts.Codec.Marshal(msg)
-		if err != nil {
-			return err
+	subs := m.Subscribers[topic]
+	for pattern, wsubs := range m.Subscribers {
+		if pattern == topic || !MatchTopic(pattern, topic) {
+			continue
+		}
+		for _, sub := range wsubs {
+			if sub.opts.Wildcard {
+				subs = append(subs[:len(subs):len(subs)], sub)
+			}
 		}
-		v = buf
-	} else {
-		v = msg
+	}
+	m.RUnlock()
+	if len(subs) == 0 {
+		return nil
 	}
 
-	p := &memoryEvent{
-		topic:   topic,
-		message: v,
-		opts:    m.opts,
+	evs := make([]Event, 0, len(msgs))
+	for _, msg := range msgs {
+		var v interface{}
+		if m.opts.Codec != nil {
+			buf, err := m.opts.Codec.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			v = buf
+		} else {
+			v = msg
+		}
+
+		evs = append(evs, &memoryEvent{
+			topic:   topic,
+			message: v,
+			opts:    m.opts,
+		})
 	}
 
 	for _, sub := range subs {
-		if err := sub.handler(p); err != nil {
-			p.err = err
-			if eh := m.opts.ErrorHandler; eh != nil {
-				eh(p)
-				continue
+		sub.gate.wait()
+
+		if sub.batch != nil {
+			if err := sub.batch.add(evs...); err != nil {
+				if eh := m.opts.ErrorHandler; eh != nil {
+					for _, p := range evs {
+						eh(&batchEvent{Event: p, err: err})
+					}
+					continue
+				}
+				return err
+			}
+			continue
+		}
+
+		for _, e := range evs {
+			p := e.(*memoryEvent)
+			if err := sub.handler(p); err != nil {
+				p.err = err
+				if eh := m.opts.ErrorHandler; eh != nil {
+					eh(p)
+					continue
+				}
+				return err
 			}
-			return err
 		}
 	}
 
@@ -134,25 +203,42 @@ func (m *memoryBroker) Publish(topic string, msg *Message, opts ...PublishOption
 }
 
 func (m *memoryBroker) Subscribe(topic string, handler Handler, opts ...SubscribeOption) (Subscriber, error) {
-	m.RLock()
-	if !m.connected {
-		m.RUnlock()
-		return nil, errors.New("not connected")
-	}
-	m.RUnlock()
-
 	var options SubscribeOptions
 	for _, o := range opts {
 		o(&options)
 	}
 
-	sub := &memorySubscriber{
+	return m.subscribe(&memorySubscriber{
 		exit:    make(chan bool, 1),
 		id:      uuid.New().String(),
 		topic:   topic,
 		handler: handler,
 		opts:    options,
+	})
+}
+
+// SubscribeBatch hands the messages of the topic to the handler in batches
+func (m *memoryBroker) SubscribeBatch(topic string, handler BatchHandler, opts ...SubscribeOption) (Subscriber, error) {
+	options := NewSubscribeOptions(opts...)
+
+	return m.subscribe(&memorySubscriber{
+		exit:  make(chan bool, 1),
+		id:    uuid.New().String(),
+		topic: topic,
+		batch: newBatcher(options, handler, m.opts.ErrorHandler),
+		opts:  options,
+	})
+}
+
+func (m *memoryBroker) subscribe(sub *memorySubscriber) (Subscriber, error) {
+	m.RLock()
+	if !m.connected {
+		m.RUnlock()
+		return nil, errors.New("not connected")
 	}
+	m.RUnlock()
+
+	topic := sub.topic
 
 	m.Lock()
 	m.Subscribers[topic] = append(m.Subscribers[topic], sub)
@@ -170,6 +256,14 @@ func (m *memoryBroker) Subscribe(topic string, handler Handler, opts ...Subscrib
 		}
 		m.Subscribers[topic] = newSubscribers
 		m.Unlock()
+
+		// release deliveries held back by a pause
+		sub.gate.resume()
+
+		// hand over the messages waiting in the batch
+		if sub.batch != nil {
+			sub.batch.close()
+		}
 	}()
 
 	return sub, nil
@@ -217,6 +311,17 @@ func (m *memorySubscriber) Topic() string {
 	return m.topic
 }
 
+// Pause holds back messages published to the subscriber until it is resumed
+func (m *memorySubscriber) Pause() error {
+	m.gate.pause()
+	return nil
+}
+
+func (m *memorySubscriber) Resume() error {
+	m.gate.resume()
+	return nil
+}
+
 func (m *memorySubscriber) Unsubscribe() error {
 	m.exit <- true
 	return nil
@@ -232,6 +337,10 @@ func NewMemoryBroker(opts ...Option) Broker {
 		o(&options)
 	}
 
+	if options.Scheduler == nil {
+		options.Scheduler = NewMemoryScheduler()
+	}
+
 	return &memoryBroker{
 		opts:        options,
 		Subscribers: make(map[string][]*memorySubscriber),
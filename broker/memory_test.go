@@ -48,3 +48,38 @@ func TestMemoryBroker(t *testing.T) {
 		t.Fatalf("Unexpected connect error %v", err)
 	}
 }
+
+func TestMemoryBrokerWildcard(t *testing.T) {
+	b := broker.NewMemoryBroker()
+
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+
+	var one, tail []string
+	if _, err := b.Subscribe("orders.*", func(p broker.Event) error {
+		one = append(one, p.Topic())
+		return nil
+	}, broker.Wildcard()); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+	if _, err := b.Subscribe("orders.>", func(p broker.Event) error {
+		tail = append(tail, p.Topic())
+		return nil
+	}, broker.Wildcard()); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	for _, topic := range []string{"orders.created", "orders.eu.created", "payments.created"} {
+		if err := b.Publish(topic, &broker.Message{Body: []byte(`hello world`)}); err != nil {
+			t.Fatalf("Unexpected error publishing %v", err)
+		}
+	}
+
+	if len(one) != 1 || one[0] != "orders.created" {
+		t.Fatalf("Expected orders.* to receive orders.created, got %v", one)
+	}
+	if len(tail) != 2 || tail[0] != "orders.created" || tail[1] != "orders.eu.created" {
+		t.Fatalf("Expected orders.> to receive both order topics, got %v", tail)
+	}
+}
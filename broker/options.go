@@ -3,6 +3,7 @@ package broker
 import (
 	"context"
 	"crypto/tls"
+	"time"
 
 	"go-micro.dev/v4/codec"
 	"go-micro.dev/v4/registry"
@@ -19,20 +20,46 @@ type Options struct {
 	TLSConfig *tls.Config
 	// Registry used for clustering
 	Registry registry.Registry
+	// Spool queues outbound messages until they are published, used by the http broker
+	Spool Spool
+	// Scheduler holds delayed messages until they are due
+	Scheduler Scheduler
 	// 接口实现的其他选项可以存储在上下文中
 	Context context.Context
 }
 
 type PublishOptions struct {
+	// Delay delivery of the message by the duration
+	Delay time.Duration
+	// DeliverAt delivers the message at the time, it takes precedence over Delay
+	DeliverAt time.Time
+
 	// 接口实现的其他选项可以存储在上下文中
 	Context context.Context
 }
 
+// DeliverTime returns when a message should be delivered, zero for straight away
+func (o PublishOptions) DeliverTime() time.Time {
+	if !o.DeliverAt.IsZero() {
+		return o.DeliverAt
+	}
+	if o.Delay > 0 {
+		return time.Now().Add(o.Delay)
+	}
+	return time.Time{}
+}
+
 type SubscribeOptions struct {
 	// 默认为 true。当 handler 返回 nil error 消息被 acked。
 	AutoAck bool
 	// 具有相同队列名称的订阅者将创建共享订阅，其中每个订阅者都接收消息子集。
 	Queue string
+	// Wildcard subscribes to every topic matching the topic pattern, see MatchTopic
+	Wildcard bool
+	// BatchSize is the number of messages which flushes a batch, see SubscribeBatch
+	BatchSize int
+	// BatchWait is how long a batch waits to fill before it is flushed
+	BatchWait time.Duration
 
 	// 接口实现的其他选项可以存储在上下文中
 	Context context.Context
@@ -42,6 +69,20 @@ type Option func(*Options)
 
 type PublishOption func(*PublishOptions)
 
+// PublishDelay delays delivery of the message by the duration
+func PublishDelay(d time.Duration) PublishOption {
+	return func(o *PublishOptions) {
+		o.Delay = d
+	}
+}
+
+// DeliverAt delivers the message at the time
+func DeliverAt(t time.Time) PublishOption {
+	return func(o *PublishOptions) {
+		o.DeliverAt = t
+	}
+}
+
 // PublishContext set context
 func PublishContext(ctx context.Context) PublishOption {
 	return func(o *PublishOptions) {
@@ -53,7 +94,9 @@ type SubscribeOption func(*SubscribeOptions)
 
 func NewSubscribeOptions(opts ...SubscribeOption) SubscribeOptions {
 	opt := SubscribeOptions{
-		AutoAck: true,
+		AutoAck:   true,
+		BatchSize: DefaultBatchSize,
+		BatchWait: DefaultBatchWait,
 	}
 
 	for _, o := range opts {
@@ -101,6 +144,20 @@ func Queue(name string) SubscribeOption {
 	}
 }
 
+// OutboundSpool sets the spool messages are queued in until they are published
+func OutboundSpool(s Spool) Option {
+	return func(o *Options) {
+		o.Spool = s
+	}
+}
+
+// MessageScheduler sets the scheduler delayed messages are held in
+func MessageScheduler(s Scheduler) Option {
+	return func(o *Options) {
+		o.Scheduler = s
+	}
+}
+
 func Registry(r registry.Registry) Option {
 	return func(o *Options) {
 		o.Registry = r
@@ -121,6 +178,28 @@ func TLSConfig(t *tls.Config) Option {
 	}
 }
 
+// Wildcard subscribes to every topic matching the topic pattern,
+// such as orders.* or orders.>
+func Wildcard() SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Wildcard = true
+	}
+}
+
+// BatchSize sets the number of messages which flushes a batch
+func BatchSize(n int) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.BatchSize = n
+	}
+}
+
+// BatchWait sets how long a batch waits to fill before it is flushed
+func BatchWait(d time.Duration) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.BatchWait = d
+	}
+}
+
 // SubscribeContext set context
 func SubscribeContext(ctx context.Context) SubscribeOption {
 	return func(o *SubscribeOptions) {
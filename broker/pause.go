@@ -0,0 +1,46 @@
+package broker
+
+import "sync"
+
+// Pauser is implemented by subscribers which can hold back deliveries, letting
+// a consumer which can't keep up apply backpressure
+type Pauser interface {
+	// Pause holds back deliveries to the subscriber until it is resumed
+	Pause() error
+	// Resume delivers messages to the subscriber again
+	Resume() error
+}
+
+// pauseGate holds back deliveries while it is paused
+type pauseGate struct {
+	sync.Mutex
+	// resumed is closed on resume, it is nil while not paused
+	resumed chan bool
+}
+
+func (g *pauseGate) pause() {
+	g.Lock()
+	defer g.Unlock()
+	if g.resumed == nil {
+		g.resumed = make(chan bool)
+	}
+}
+
+func (g *pauseGate) resume() {
+	g.Lock()
+	defer g.Unlock()
+	if g.resumed != nil {
+		close(g.resumed)
+		g.resumed = nil
+	}
+}
+
+// wait blocks while the gate is paused
+func (g *pauseGate) wait() {
+	g.Lock()
+	resumed := g.resumed
+	g.Unlock()
+	if resumed != nil {
+		<-resumed
+	}
+}
@@ -0,0 +1,67 @@
+package broker_test
+
+import (
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/broker"
+)
+
+func TestMemoryPause(t *testing.T) {
+	b := broker.NewMemoryBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer b.Disconnect()
+
+	received := make(chan bool, 1)
+	sub, err := b.Subscribe("test", func(p broker.Event) error {
+		received <- true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+
+	p, ok := sub.(broker.Pauser)
+	if !ok {
+		t.Fatal("Expected the subscriber to be a pauser")
+	}
+	p.Pause()
+
+	published := make(chan error, 1)
+	go func() {
+		published <- b.Publish("test", &broker.Message{Body: []byte("hello")})
+	}()
+
+	select {
+	case <-received:
+		t.Fatal("Unexpected message while paused")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	p.Resume()
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message after resume")
+	}
+	if err := <-published; err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	// unsubscribing releases held deliveries
+	p.Pause()
+	go func() {
+		published <- b.Publish("test", &broker.Message{Body: []byte("hello")})
+	}()
+	time.Sleep(time.Millisecond * 50)
+	sub.Unsubscribe()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for publish after unsubscribe")
+	}
+}
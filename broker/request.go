@@ -0,0 +1,136 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ReplyToHeader is the header carrying the topic a reply should be published to
+	ReplyToHeader = "Micro-Reply-To"
+	// CorrelationIDHeader is the header matching a reply to its request
+	CorrelationIDHeader = "Micro-Correlation-Id"
+	// InboxPrefix is the prefix of the ephemeral topics replies are received on
+	InboxPrefix = "_inbox."
+)
+
+var (
+	// ErrRequestTimeout is returned when no reply is received before the timeout
+	ErrRequestTimeout = errors.New("request timed out waiting for a reply")
+	// ErrNoReplyTo is returned when replying to a message which wasn't sent as a request
+	ErrNoReplyTo = errors.New("message has no reply to topic")
+)
+
+// DefaultRequestTimeout is how long a request waits for a reply unless set by RequestTimeout
+var DefaultRequestTimeout = time.Second * 5
+
+type RequestOptions struct {
+	// Timeout is how long to wait for a reply
+	Timeout time.Duration
+	// Publish options used for the request
+	Publish []PublishOption
+
+	// Other options for implementations of the interface
+	// can be stored in a context
+	Context context.Context
+}
+
+type RequestOption func(*RequestOptions)
+
+// RequestTimeout sets how long to wait for a reply
+func RequestTimeout(d time.Duration) RequestOption {
+	return func(o *RequestOptions) {
+		o.Timeout = d
+	}
+}
+
+// RequestPublish sets the options used to publish the request
+func RequestPublish(opts ...PublishOption) RequestOption {
+	return func(o *RequestOptions) {
+		o.Publish = append(o.Publish, opts...)
+	}
+}
+
+// RequestContext sets the context of the request, the wait ends early when it is done
+func RequestContext(ctx context.Context) RequestOption {
+	return func(o *RequestOptions) {
+		o.Context = ctx
+	}
+}
+
+// Request publishes the message to the topic and waits for the reply. The reply is
+// received on an ephemeral inbox subscription named in the ReplyToHeader, and matched
+// by the CorrelationIDHeader. Responders answer with Reply.
+func Request(b Broker, topic string, msg *Message, opts ...RequestOption) (*Message, error) {
+	options := RequestOptions{
+		Timeout: DefaultRequestTimeout,
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	id := uuid.New().String()
+	inbox := InboxPrefix + uuid.New().String()
+
+	replies := make(chan *Message, 1)
+	sub, err := b.Subscribe(inbox, func(e Event) error {
+		m := e.Message()
+		// replies to other requests are ignored
+		if m.Header[CorrelationIDHeader] != id {
+			return nil
+		}
+		select {
+		case replies <- m:
+		default:
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	// copy the header so the caller's message isn't changed
+	header := make(map[string]string, len(msg.Header)+2)
+	for k, v := range msg.Header {
+		header[k] = v
+	}
+	header[ReplyToHeader] = inbox
+	header[CorrelationIDHeader] = id
+
+	if err := b.Publish(topic, &Message{Header: header, Body: msg.Body}, options.Publish...); err != nil {
+		return nil, err
+	}
+
+	t := time.NewTimer(options.Timeout)
+	defer t.Stop()
+
+	select {
+	case m := <-replies:
+		return m, nil
+	case <-t.C:
+		return nil, ErrRequestTimeout
+	case <-options.Context.Done():
+		return nil, options.Context.Err()
+	}
+}
+
+// Reply publishes the reply to the inbox of the request, carrying over its correlation ID
+func Reply(b Broker, req *Message, reply *Message, opts ...PublishOption) error {
+	inbox := req.Header[ReplyToHeader]
+	if len(inbox) == 0 {
+		return ErrNoReplyTo
+	}
+
+	header := make(map[string]string, len(reply.Header)+1)
+	for k, v := range reply.Header {
+		header[k] = v
+	}
+	header[CorrelationIDHeader] = req.Header[CorrelationIDHeader]
+
+	return b.Publish(inbox, &Message{Header: header, Body: reply.Body}, opts...)
+}
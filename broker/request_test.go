@@ -0,0 +1,63 @@
+package broker_test
+
+import (
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/registry"
+)
+
+func testRequest(t *testing.T, b broker.Broker) {
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error: %v", err)
+	}
+	defer b.Disconnect()
+
+	sub, err := b.Subscribe("greeter", func(p broker.Event) error {
+		req := p.Message()
+		return broker.Reply(b, req, &broker.Message{
+			Header: map[string]string{"Content-Type": "text/plain"},
+			Body:   append([]byte("hello "), req.Body...),
+		})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	msg := &broker.Message{Header: map[string]string{"foo": "bar"}, Body: []byte("john")}
+	rsp, err := broker.Request(b, "greeter", msg, broker.RequestTimeout(time.Second*5))
+	if err != nil {
+		t.Fatalf("Unexpected request error: %v", err)
+	}
+	if string(rsp.Body) != "hello john" {
+		t.Fatalf("Expected reply hello john, got %v", string(rsp.Body))
+	}
+	if rsp.Header["Content-Type"] != "text/plain" {
+		t.Fatalf("Expected the reply header to be kept, got %v", rsp.Header)
+	}
+	if _, ok := msg.Header[broker.ReplyToHeader]; ok {
+		t.Fatal("The request message should not be changed")
+	}
+
+	// nobody answers requests to other topics
+	if _, err := broker.Request(b, "farewell", msg, broker.RequestTimeout(time.Millisecond*100)); err != broker.ErrRequestTimeout {
+		t.Fatalf("Expected %v, got %v", broker.ErrRequestTimeout, err)
+	}
+}
+
+func TestMemoryRequest(t *testing.T) {
+	testRequest(t, broker.NewMemoryBroker())
+}
+
+func TestHTTPRequest(t *testing.T) {
+	testRequest(t, broker.NewBroker(broker.Registry(registry.NewMemoryRegistry())))
+}
+
+func TestReplyWithoutRequest(t *testing.T) {
+	b := broker.NewMemoryBroker()
+	if err := broker.Reply(b, &broker.Message{}, &broker.Message{}); err != broker.ErrNoReplyTo {
+		t.Fatalf("Expected %v, got %v", broker.ErrNoReplyTo, err)
+	}
+}
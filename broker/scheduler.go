@@ -0,0 +1,152 @@
+package broker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go-micro.dev/v4/logger"
+)
+
+var (
+	// schedulerInterval is how often brokers check for scheduled messages which are due
+	schedulerInterval = time.Millisecond * 100
+	// schedulerLease is how long a message returned by Due is held before it is due again
+	schedulerLease = time.Second * 30
+)
+
+// Scheduler holds messages published with a delay until they are due, see PublishDelay and DeliverAt
+type Scheduler interface {
+	// Schedule the message to be published to the topic at the time
+	Schedule(topic string, msg *Message, at time.Time) error
+	// Due leases and returns the messages due at or before the time, earliest first.
+	// A leased message is due again once its lease expires unless it is acked.
+	Due(now time.Time) ([]*Scheduled, error)
+	// Ack removes a leased message once it is published
+	Ack(m *Scheduled) error
+	String() string
+}
+
+// Scheduled is a message waiting to be published
+type Scheduled struct {
+	ID      string    `json:"id"`
+	Topic   string    `json:"topic"`
+	Message *Message  `json:"message"`
+	At      time.Time `json:"at"`
+}
+
+type memoryScheduler struct {
+	sync.Mutex
+	// pending messages ordered by the time they are due
+	pending []*Scheduled
+	// leased messages by ID, due again when the lease expires
+	leased map[string]*lease
+}
+
+type lease struct {
+	msg   *Scheduled
+	until time.Time
+}
+
+// NewMemoryScheduler returns a scheduler which holds messages in memory,
+// they are lost when the process stops
+func NewMemoryScheduler() Scheduler {
+	return &memoryScheduler{
+		leased: make(map[string]*lease),
+	}
+}
+
+func (m *memoryScheduler) Schedule(topic string, msg *Message, at time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+
+	s := &Scheduled{
+		ID:      uuid.New().String(),
+		Topic:   topic,
+		Message: msg,
+		At:      at,
+	}
+
+	// insert after any message due at the same time, keeping publish order
+	i := sort.Search(len(m.pending), func(i int) bool {
+		return m.pending[i].At.After(at)
+	})
+	m.pending = append(m.pending, nil)
+	copy(m.pending[i+1:], m.pending[i:])
+	m.pending[i] = s
+
+	return nil
+}
+
+func (m *memoryScheduler) Due(now time.Time) ([]*Scheduled, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	// messages which weren't acked before their lease expired
+	var due []*Scheduled
+	for _, l := range m.leased {
+		if !l.until.After(now) {
+			due = append(due, l.msg)
+		}
+	}
+
+	i := sort.Search(len(m.pending), func(i int) bool {
+		return m.pending[i].At.After(now)
+	})
+	due = append(due, m.pending[:i]...)
+	m.pending = m.pending[i:]
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].At.Before(due[j].At)
+	})
+	for _, s := range due {
+		m.leased[s.ID] = &lease{msg: s, until: now.Add(schedulerLease)}
+	}
+
+	return due, nil
+}
+
+func (m *memoryScheduler) Ack(s *Scheduled) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.leased, s.ID)
+	return nil
+}
+
+func (m *memoryScheduler) String() string {
+	return "memory"
+}
+
+// dispatch publishes scheduled messages as they become due until exit is closed
+func dispatch(s Scheduler, publish func(topic string, msg *Message) error, exit chan bool) {
+	t := time.NewTicker(schedulerInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-exit:
+			return
+		case now := <-t.C:
+			due, err := s.Due(now)
+			if err != nil {
+				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("[scheduler] failed to get due messages: %v", err)
+				}
+				continue
+			}
+			for _, m := range due {
+				// a message which fails is published again once its lease expires
+				if err := publish(m.Topic, m.Message); err != nil {
+					if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+						logger.Errorf("[scheduler] failed to publish %v to %v: %v", m.ID, m.Topic, err)
+					}
+					continue
+				}
+				if err := s.Ack(m); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("[scheduler] failed to ack %v: %v", m.ID, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package scheduler provides a broker scheduler backed by a store, so delayed
+// messages survive a restart and are sent once the broker connects again
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/store"
+)
+
+// table the scheduled messages are kept in
+const table = "schedule"
+
+// page is the number of keys listed at a time looking for messages due
+const page = 100
+
+// lease is how long a message returned by Due is held before it is due again
+var lease = time.Second * 30
+
+type storeScheduler struct {
+	store store.Store
+
+	// mtx stops two dispatchers in the process taking the same message
+	mtx sync.Mutex
+}
+
+// NewScheduler returns a scheduler which keeps messages in the store
+func NewScheduler(s store.Store) broker.Scheduler {
+	return &storeScheduler{store: s}
+}
+
+// key orders messages by the time they are due
+func key(at time.Time, id string) string {
+	return fmt.Sprintf("%020d/%s", at.UnixNano(), id)
+}
+
+func (s *storeScheduler) Schedule(topic string, msg *broker.Message, at time.Time) error {
+	m := &broker.Scheduled{
+		ID:      uuid.New().String(),
+		Topic:   topic,
+		Message: msg,
+		At:      at,
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Write(&store.Record{
+		Key:   key(at, m.ID),
+		Value: b,
+	}, store.WriteTo("", table))
+}
+
+func (s *storeScheduler) Due(now time.Time) ([]*broker.Scheduled, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var due []*broker.Scheduled
+
+	// page through the keys in the order they are due, up to now
+	var cursor string
+	for {
+		var next string
+		keys, err := s.store.List(store.ListLimit(page), store.ListCursor(cursor),
+			store.ListNextCursor(&next), store.ListFrom("", table))
+		if err != nil {
+			return due, err
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			i := strings.Index(k, "/")
+			if i < 0 {
+				continue
+			}
+			at, err := strconv.ParseInt(k[:i], 10, 64)
+			if err != nil {
+				continue
+			}
+			if at > now.UnixNano() {
+				return due, nil
+			}
+
+			m, err := s.take(k, now)
+			if err != nil {
+				return due, err
+			}
+			if m != nil {
+				due = append(due, m)
+			}
+		}
+
+		if len(next) == 0 {
+			return due, nil
+		}
+		cursor = next
+	}
+}
+
+// take leases the message of the key, it returns nil if the message was
+// taken by another process or is invalid
+func (s *storeScheduler) take(k string, now time.Time) (*broker.Scheduled, error) {
+	recs, err := s.store.Read(k, store.ReadFrom("", table))
+	if err == store.ErrNotFound {
+		// taken by another process
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// invalid messages are dropped rather than blocking those after them
+	m := &broker.Scheduled{}
+	if err := json.Unmarshal(recs[0].Value, m); err != nil {
+		return nil, s.store.Delete(k, store.DeleteFrom("", table))
+	}
+
+	// lease the message by moving it to when it is due again, it is
+	// published at least once even if the process stops before acking it
+	if err := s.lease(k, recs[0], key(now.Add(lease), m.ID)); err == store.ErrConflict {
+		// leased by another process
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// lease moves the record to the key it is due again at. The move only succeeds
+// for the process which read the current version, so a message is leased once.
+func (s *storeScheduler) lease(k string, rec *store.Record, next string) error {
+	err := s.store.Batch([]store.Op{
+		store.DeleteOp(k).IfVersion(rec.Version),
+		store.WriteOp(&store.Record{Key: next, Value: rec.Value}),
+	}, store.BatchTo("", table))
+	if err != store.ErrNotSupported {
+		return err
+	}
+
+	// without batches the store can't be shared by schedulers of several processes
+	if err := s.store.Write(&store.Record{
+		Key:   next,
+		Value: rec.Value,
+	}, store.WriteTo("", table)); err != nil {
+		return err
+	}
+	return s.store.Delete(k, store.DeleteFrom("", table))
+}
+
+func (s *storeScheduler) Ack(m *broker.Scheduled) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	keys, err := s.store.List(store.ListSuffix("/"+m.ID), store.ListFrom("", table))
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := s.store.Delete(k, store.DeleteFrom("", table)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *storeScheduler) String() string {
+	return "store"
+}
@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/store"
+)
+
+func TestScheduler(t *testing.T) {
+	st := store.NewMemoryStore()
+	s := NewScheduler(st)
+	now := time.Now()
+
+	for i, at := range []time.Time{now.Add(time.Minute), now.Add(-time.Second), now.Add(-time.Minute)} {
+		msg := &broker.Message{Header: map[string]string{"i": string(rune('0' + i))}, Body: []byte("hello")}
+		if err := s.Schedule("foo", msg, at); err != nil {
+			t.Fatalf("Unexpected schedule error: %v", err)
+		}
+	}
+
+	// pending messages survive a restart
+	s = NewScheduler(st)
+
+	due, err := s.Due(now)
+	if err != nil {
+		t.Fatalf("Unexpected due error: %v", err)
+	}
+	if len(due) != 2 {
+		t.Fatalf("Expected 2 due messages, got %d", len(due))
+	}
+	if due[0].Message.Header["i"] != "2" || due[1].Message.Header["i"] != "1" {
+		t.Fatalf("Expected the earliest message first, got %v then %v", due[0].Message.Header, due[1].Message.Header)
+	}
+	if due[0].Topic != "foo" || string(due[0].Message.Body) != "hello" {
+		t.Fatalf("Unexpected message %+v", due[0])
+	}
+
+	if due, _ := s.Due(now); len(due) != 0 {
+		t.Fatalf("Due messages should only be returned once, got %d", len(due))
+	}
+
+	// a message which failed to publish isn't acked, it is due again once its
+	// lease expires, even after a restart
+	if err := s.Ack(due[1]); err != nil {
+		t.Fatalf("Unexpected ack error: %v", err)
+	}
+	s = NewScheduler(st)
+
+	due, err = s.Due(now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected due error: %v", err)
+	}
+	if len(due) != 2 || due[0].Message.Header["i"] != "2" || due[1].Message.Header["i"] != "0" {
+		t.Fatalf("Expected the unacked message then the last message to be due, got %d", len(due))
+	}
+	for _, m := range due {
+		if err := s.Ack(m); err != nil {
+			t.Fatalf("Unexpected ack error: %v", err)
+		}
+	}
+	if due, _ := s.Due(now.Add(time.Hour * 2)); len(due) != 0 {
+		t.Fatalf("Acked messages should not be due again, got %d", len(due))
+	}
+}
+
+// slowStore widens the window between a scheduler reading a message and leasing it
+type slowStore struct {
+	store.Store
+}
+
+func (s *slowStore) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
+	recs, err := s.Store.Read(key, opts...)
+	time.Sleep(time.Millisecond)
+	return recs, err
+}
+
+func TestSchedulerSharedStore(t *testing.T) {
+	st := &slowStore{store.NewMemoryStore()}
+	now := time.Now()
+
+	n := 20
+	for i := 0; i < n; i++ {
+		if err := NewScheduler(st).Schedule("foo", &broker.Message{Body: []byte("hello")}, now.Add(-time.Second)); err != nil {
+			t.Fatalf("Unexpected schedule error: %v", err)
+		}
+	}
+
+	// schedulers of two processes take the due messages at the same time
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	leased := make(map[string]int)
+	start := make(chan bool)
+	for _, s := range []broker.Scheduler{NewScheduler(st), NewScheduler(st)} {
+		wg.Add(1)
+		go func(s broker.Scheduler) {
+			defer wg.Done()
+			<-start
+			due, err := s.Due(now)
+			if err != nil {
+				t.Errorf("Unexpected due error: %v", err)
+				return
+			}
+			mtx.Lock()
+			defer mtx.Unlock()
+			for _, m := range due {
+				leased[m.ID]++
+			}
+		}(s)
+	}
+	close(start)
+	wg.Wait()
+
+	if len(leased) != n {
+		t.Fatalf("Expected %d messages to be leased, got %d", n, len(leased))
+	}
+	for id, count := range leased {
+		if count != 1 {
+			t.Fatalf("Expected message %s to be leased once, got %d", id, count)
+		}
+	}
+}
+
+func TestSchedulerPages(t *testing.T) {
+	s := NewScheduler(store.NewMemoryStore())
+	now := time.Now()
+
+	// more messages are due than fit in a page, and more after them aren't
+	for i := 0; i < page*2+10; i++ {
+		at := now.Add(-time.Duration(i+1) * time.Millisecond)
+		if i%2 == 1 {
+			at = now.Add(time.Duration(i+1) * time.Millisecond)
+		}
+		if err := s.Schedule("foo", &broker.Message{Body: []byte("hello")}, at); err != nil {
+			t.Fatalf("Unexpected schedule error: %v", err)
+		}
+	}
+
+	due, err := s.Due(now)
+	if err != nil {
+		t.Fatalf("Unexpected due error: %v", err)
+	}
+	if len(due) != page+5 {
+		t.Fatalf("Expected %d due messages, got %d", page+5, len(due))
+	}
+	for i := 1; i < len(due); i++ {
+		if due[i].At.Before(due[i-1].At) {
+			t.Fatalf("Expected the messages in the order they're due")
+		}
+	}
+}
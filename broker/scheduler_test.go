@@ -0,0 +1,90 @@
+package broker_test
+
+import (
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/broker"
+)
+
+func TestMemoryScheduler(t *testing.T) {
+	s := broker.NewMemoryScheduler()
+	now := time.Now()
+
+	for i, at := range []time.Time{now.Add(time.Minute), now, now.Add(-time.Minute), now} {
+		msg := &broker.Message{Body: []byte{byte('0' + i)}}
+		if err := s.Schedule("foo", msg, at); err != nil {
+			t.Fatalf("Unexpected schedule error: %v", err)
+		}
+	}
+
+	due, err := s.Due(now)
+	if err != nil {
+		t.Fatalf("Unexpected due error: %v", err)
+	}
+
+	// earliest first, in publish order when due at the same time
+	var got string
+	for _, m := range due {
+		got += string(m.Message.Body)
+	}
+	if got != "213" {
+		t.Fatalf("Expected messages 2, 1 and 3 to be due, got %q", got)
+	}
+
+	if due, _ := s.Due(now); len(due) != 0 {
+		t.Fatalf("Due messages should only be returned once, got %d", len(due))
+	}
+
+	// messages which fail to publish aren't acked, they are due again once their lease expires
+	for _, m := range due[1:] {
+		if err := s.Ack(m); err != nil {
+			t.Fatalf("Unexpected ack error: %v", err)
+		}
+	}
+	due, _ = s.Due(now.Add(time.Hour))
+	if len(due) != 2 || string(due[0].Message.Body) != "2" || string(due[1].Message.Body) != "0" {
+		t.Fatalf("Expected the unacked message then the last message to be due, got %d", len(due))
+	}
+}
+
+func TestMemoryBrokerDelay(t *testing.T) {
+	b := broker.NewMemoryBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	defer b.Disconnect()
+
+	received := make(chan time.Time, 2)
+	if _, err := b.Subscribe("delayed", func(p broker.Event) error {
+		received <- time.Now()
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	start := time.Now()
+	if err := b.Publish("delayed", &broker.Message{Body: []byte("later")}, broker.PublishDelay(time.Millisecond*300)); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+	if err := b.Publish("delayed", &broker.Message{Body: []byte("at")}, broker.DeliverAt(start.Add(time.Millisecond*300))); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+
+	select {
+	case <-received:
+		t.Fatal("Delayed message delivered straight away")
+	default:
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case at := <-received:
+			if at.Sub(start) < time.Millisecond*300 {
+				t.Fatalf("Delayed message delivered after %v", at.Sub(start))
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatal("Timed out waiting for delayed message")
+		}
+	}
+}
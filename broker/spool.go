@@ -0,0 +1,270 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/util/backoff"
+)
+
+var (
+	// ErrSpoolFull is returned when a blocking spool stays full until the context is done
+	ErrSpoolFull = errors.New("spool is full")
+)
+
+// Spool queues the encoded messages a broker is yet to publish, or failed to publish,
+// until they can be sent. It is used by the http broker, see OutboundSpool.
+type Spool interface {
+	Options() SpoolOptions
+	// Push adds a message to the end of the topic's queue, applying the drop policy when it is full.
+	// It returns the sequence of the message in the queue, 0 if the message was dropped.
+	Push(ctx context.Context, topic string, msg []byte) (uint64, error)
+	// Pop removes up to n messages from the front of the topic's queue
+	Pop(topic string, n int) ([][]byte, error)
+	// Peek returns up to n messages from the front of the topic's queue without removing them
+	Peek(topic string, n int) ([]*SpoolMessage, error)
+	// Ack removes the message of the sequence returned by Push or Peek once it is sent. It is
+	// a no-op if the message is no longer at the front of the queue, e.g. it was dropped meanwhile.
+	Ack(topic string, seq uint64) error
+	// Stats returns the depth of each queue and the messages dropped from it
+	Stats() SpoolStats
+	String() string
+}
+
+// SpoolMessage is a message queued in a spool
+type SpoolMessage struct {
+	// Seq is the sequence of the message in its queue, set by Push
+	Seq  uint64
+	Body []byte
+}
+
+// SpoolStats are the metrics of a spool
+type SpoolStats struct {
+	// Depth is the number of queued messages by topic
+	Depth map[string]int
+	// Dropped is the number of messages dropped by topic
+	Dropped map[string]uint64
+}
+
+// DropPolicy decides which message is lost when a spool is full
+type DropPolicy int
+
+const (
+	// DropOldest drops the message at the front of the queue to make room
+	DropOldest DropPolicy = iota
+	// DropNewest drops the message being pushed
+	DropNewest
+	// Block waits for room until the context is done
+	Block
+)
+
+func (p DropPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "oldest"
+	case DropNewest:
+		return "newest"
+	case Block:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+type SpoolOptions struct {
+	// Size is the maximum number of messages queued per topic, 0 is unbounded
+	Size int
+	// Policy applied when a queue is full
+	Policy DropPolicy
+	// Backoff returns the delay before retrying a failed publish
+	Backoff func(attempts int) time.Duration
+	// Retries is the maximum number of retries after a failed publish,
+	// 0 retries until the messages are sent or the broker disconnects
+	Retries int
+
+	// Other options for implementations of the interface
+	// can be stored in a context
+	Context context.Context
+}
+
+type SpoolOption func(*SpoolOptions)
+
+// NewSpoolOptions returns the spool options with defaults applied
+func NewSpoolOptions(opts ...SpoolOption) SpoolOptions {
+	options := SpoolOptions{
+		Size:    64,
+		Policy:  DropOldest,
+		Backoff: backoff.Do,
+		Retries: 10,
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// SpoolSize sets the maximum number of messages queued per topic
+func SpoolSize(n int) SpoolOption {
+	return func(o *SpoolOptions) {
+		o.Size = n
+	}
+}
+
+// SpoolPolicy sets the policy applied when a queue is full
+func SpoolPolicy(p DropPolicy) SpoolOption {
+	return func(o *SpoolOptions) {
+		o.Policy = p
+	}
+}
+
+// SpoolBackoff sets the delay before retrying a failed publish
+func SpoolBackoff(fn func(attempts int) time.Duration) SpoolOption {
+	return func(o *SpoolOptions) {
+		o.Backoff = fn
+	}
+}
+
+// SpoolRetries sets the maximum number of retries after a failed publish
+func SpoolRetries(n int) SpoolOption {
+	return func(o *SpoolOptions) {
+		o.Retries = n
+	}
+}
+
+type memorySpool struct {
+	opts SpoolOptions
+
+	sync.Mutex
+	seq     uint64
+	queues  map[string][]*SpoolMessage
+	dropped map[string]uint64
+	// space is closed when messages are popped, waking blocked pushes
+	space chan bool
+}
+
+// NewMemorySpool returns a spool which queues messages in memory
+func NewMemorySpool(opts ...SpoolOption) Spool {
+	return &memorySpool{
+		opts:    NewSpoolOptions(opts...),
+		queues:  make(map[string][]*SpoolMessage),
+		dropped: make(map[string]uint64),
+		space:   make(chan bool),
+	}
+}
+
+func (m *memorySpool) Options() SpoolOptions {
+	return m.opts
+}
+
+func (m *memorySpool) Push(ctx context.Context, topic string, msg []byte) (uint64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	for m.opts.Size > 0 && len(m.queues[topic]) >= m.opts.Size {
+		switch m.opts.Policy {
+		case DropOldest:
+			m.queues[topic] = m.queues[topic][1:]
+			m.dropped[topic]++
+		case DropNewest:
+			m.dropped[topic]++
+			return 0, nil
+		default:
+			space := m.space
+			m.Unlock()
+			select {
+			case <-space:
+				m.Lock()
+			case <-ctx.Done():
+				m.Lock()
+				return 0, ErrSpoolFull
+			}
+		}
+	}
+
+	m.seq++
+	m.queues[topic] = append(m.queues[topic], &SpoolMessage{Seq: m.seq, Body: msg})
+	return m.seq, nil
+}
+
+func (m *memorySpool) Pop(topic string, n int) ([][]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	q := m.queues[topic]
+	if len(q) == 0 {
+		return nil, nil
+	}
+	if n > len(q) {
+		n = len(q)
+	}
+
+	msgs := make([][]byte, n)
+	for i, msg := range q[:n] {
+		msgs[i] = msg.Body
+	}
+	if n == len(q) {
+		delete(m.queues, topic)
+	} else {
+		m.queues[topic] = q[n:]
+	}
+
+	close(m.space)
+	m.space = make(chan bool)
+
+	return msgs, nil
+}
+
+func (m *memorySpool) Peek(topic string, n int) ([]*SpoolMessage, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	q := m.queues[topic]
+	if n > len(q) {
+		n = len(q)
+	}
+	return append([]*SpoolMessage(nil), q[:n]...), nil
+}
+
+func (m *memorySpool) Ack(topic string, seq uint64) error {
+	m.Lock()
+	defer m.Unlock()
+
+	q := m.queues[topic]
+	if len(q) == 0 || q[0].Seq != seq {
+		return nil
+	}
+	if len(q) == 1 {
+		delete(m.queues, topic)
+	} else {
+		m.queues[topic] = q[1:]
+	}
+
+	close(m.space)
+	m.space = make(chan bool)
+
+	return nil
+}
+
+func (m *memorySpool) Stats() SpoolStats {
+	m.Lock()
+	defer m.Unlock()
+
+	stats := SpoolStats{
+		Depth:   make(map[string]int, len(m.queues)),
+		Dropped: make(map[string]uint64, len(m.dropped)),
+	}
+	for topic, q := range m.queues {
+		stats.Depth[topic] = len(q)
+	}
+	for topic, d := range m.dropped {
+		stats.Dropped[topic] = d
+	}
+	return stats
+}
+
+func (m *memorySpool) String() string {
+	return "memory"
+}
@@ -0,0 +1,282 @@
+// Package spool provides a broker spool backed by a store, so messages
+// waiting to be published survive a restart
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/store"
+)
+
+// table the spooled messages are kept in
+const table = "spool"
+
+// queueKey is the suffix of the key of each topic's queue, which can't be
+// mistaken for the key of a message as it isn't a sequence
+const queueKey = "/queue"
+
+// pollInterval is how often a blocked push checks for room made by another process
+var pollInterval = time.Millisecond * 100
+
+// queue is the head and tail of a topic's queue, its messages are the
+// sequences from Head up to but not including Tail
+type queue struct {
+	Head uint64 `json:"head"`
+	Tail uint64 `json:"tail"`
+
+	// version of the record read, so it's only updated if unchanged
+	version uint64
+}
+
+func (q *queue) len() int {
+	return int(q.Tail - q.Head)
+}
+
+type storeSpool struct {
+	opts  broker.SpoolOptions
+	store store.Store
+
+	sync.Mutex
+	dropped map[string]uint64
+	// space is closed when messages are popped, waking blocked pushes
+	space chan bool
+}
+
+// NewSpool returns a spool which keeps messages in the store. The store must
+// support Batch, which keeps the queues consistent between processes.
+func NewSpool(s store.Store, opts ...broker.SpoolOption) broker.Spool {
+	return &storeSpool{
+		opts:    broker.NewSpoolOptions(opts...),
+		store:   s,
+		dropped: make(map[string]uint64),
+		space:   make(chan bool),
+	}
+}
+
+// key is the key of the message of the sequence in the topic's queue
+func key(topic string, seq uint64) string {
+	return fmt.Sprintf("%s/%020d", topic, seq)
+}
+
+// queue reads the head and tail of the topic's queue
+func (s *storeSpool) queue(topic string) (*queue, error) {
+	recs, err := s.store.Read(topic+queueKey, store.ReadFrom("", table))
+	if err == store.ErrNotFound {
+		// sequences start at 1, 0 is returned for messages dropped
+		return &queue{Head: 1, Tail: 1}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	q := &queue{version: recs[0].Version}
+	if err := json.Unmarshal(recs[0].Value, q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// update applies the ops along with moving the topic's queue to q, failing
+// with store.ErrConflict if another process changed the queue since it was read
+func (s *storeSpool) update(topic string, q *queue, ops ...store.Op) error {
+	b, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	ops = append(ops, store.WriteOp(&store.Record{Key: topic + queueKey, Value: b}).IfVersion(q.version))
+	return s.store.Batch(ops, store.BatchTo("", table))
+}
+
+// read returns up to n messages from the head of the queue
+func (s *storeSpool) read(topic string, q *queue, n int) ([]*broker.SpoolMessage, error) {
+	if n > q.len() {
+		n = q.len()
+	}
+
+	var msgs []*broker.SpoolMessage
+	for seq := q.Head; seq < q.Head+uint64(n); seq++ {
+		recs, err := s.store.Read(key(topic, seq), store.ReadFrom("", table))
+		if err == store.ErrNotFound {
+			continue
+		} else if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, &broker.SpoolMessage{Seq: seq, Body: recs[0].Value})
+	}
+
+	return msgs, nil
+}
+
+// remove returns the ops deleting the messages from the head of the queue up to seq
+func remove(topic string, q *queue, seq uint64) []store.Op {
+	var ops []store.Op
+	for ; q.Head < seq; q.Head++ {
+		ops = append(ops, store.DeleteOp(key(topic, q.Head)))
+	}
+	return ops
+}
+
+func (s *storeSpool) Options() broker.SpoolOptions {
+	return s.opts
+}
+
+func (s *storeSpool) Push(ctx context.Context, topic string, msg []byte) (uint64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for {
+		q, err := s.queue(topic)
+		if err != nil {
+			return 0, err
+		}
+
+		var ops []store.Op
+		var dropped int
+		if s.opts.Size > 0 && q.len() >= s.opts.Size {
+			switch s.opts.Policy {
+			case broker.DropOldest:
+				dropped = q.len() - s.opts.Size + 1
+				ops = remove(topic, q, q.Head+uint64(dropped))
+			case broker.DropNewest:
+				s.dropped[topic]++
+				return 0, nil
+			default:
+				space := s.space
+				s.Unlock()
+				select {
+				case <-space:
+				case <-time.After(pollInterval):
+				case <-ctx.Done():
+					s.Lock()
+					return 0, broker.ErrSpoolFull
+				}
+				s.Lock()
+				continue
+			}
+		}
+
+		seq := q.Tail
+		q.Tail++
+		ops = append(ops, store.WriteOp(&store.Record{Key: key(topic, seq), Value: msg}))
+
+		err = s.update(topic, q, ops...)
+		if err == store.ErrConflict {
+			// pushed or popped by another process
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		s.dropped[topic] += uint64(dropped)
+		return seq, nil
+	}
+}
+
+func (s *storeSpool) Pop(topic string, n int) ([][]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for {
+		q, err := s.queue(topic)
+		if err != nil {
+			return nil, err
+		}
+
+		popped, err := s.read(topic, q, n)
+		if err != nil {
+			return nil, err
+		}
+		if len(popped) == 0 {
+			return nil, nil
+		}
+
+		err = s.update(topic, q, remove(topic, q, popped[len(popped)-1].Seq+1)...)
+		if err == store.ErrConflict {
+			// popped by another process
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		close(s.space)
+		s.space = make(chan bool)
+
+		msgs := make([][]byte, len(popped))
+		for i, m := range popped {
+			msgs[i] = m.Body
+		}
+		return msgs, nil
+	}
+}
+
+func (s *storeSpool) Peek(topic string, n int) ([]*broker.SpoolMessage, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	q, err := s.queue(topic)
+	if err != nil {
+		return nil, err
+	}
+	return s.read(topic, q, n)
+}
+
+func (s *storeSpool) Ack(topic string, seq uint64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for {
+		q, err := s.queue(topic)
+		if err != nil || q.len() == 0 || q.Head != seq {
+			return err
+		}
+
+		err = s.update(topic, q, remove(topic, q, seq+1)...)
+		if err == store.ErrConflict {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		close(s.space)
+		s.space = make(chan bool)
+
+		return nil
+	}
+}
+
+func (s *storeSpool) Stats() broker.SpoolStats {
+	s.Lock()
+	defer s.Unlock()
+
+	stats := broker.SpoolStats{
+		Depth:   make(map[string]int),
+		Dropped: make(map[string]uint64, len(s.dropped)),
+	}
+	for topic, d := range s.dropped {
+		stats.Dropped[topic] = d
+	}
+
+	keys, err := s.store.List(store.ListSuffix(queueKey), store.ListFrom("", table))
+	if err != nil {
+		return stats
+	}
+	for _, k := range keys {
+		topic := strings.TrimSuffix(k, queueKey)
+		q, err := s.queue(topic)
+		if err != nil || q.len() == 0 {
+			continue
+		}
+		stats.Depth[topic] = q.len()
+	}
+
+	return stats
+}
+
+func (s *storeSpool) String() string {
+	return "store"
+}
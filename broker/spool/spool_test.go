@@ -0,0 +1,136 @@
+package spool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/store"
+)
+
+func TestSpool(t *testing.T) {
+	st := store.NewMemoryStore()
+	s := NewSpool(st, broker.SpoolSize(2))
+
+	for _, m := range []string{"1", "2", "3"} {
+		if _, err := s.Push(context.Background(), "foo", []byte(m)); err != nil {
+			t.Fatalf("Unexpected push error: %v", err)
+		}
+	}
+	if _, err := s.Push(context.Background(), "foo/bar", []byte("nested")); err != nil {
+		t.Fatalf("Unexpected push error: %v", err)
+	}
+
+	stats := s.Stats()
+	if stats.Depth["foo"] != 2 || stats.Depth["foo/bar"] != 1 {
+		t.Fatalf("Unexpected depth %v", stats.Depth)
+	}
+	if stats.Dropped["foo"] != 1 {
+		t.Fatalf("Expected the oldest message to be dropped, got %v", stats.Dropped)
+	}
+
+	// messages survive a restart
+	s = NewSpool(st, broker.SpoolSize(2))
+	if _, err := s.Push(context.Background(), "foo", []byte("4")); err != nil {
+		t.Fatalf("Unexpected push error: %v", err)
+	}
+
+	msgs, err := s.Pop("foo", 8)
+	if err != nil {
+		t.Fatalf("Unexpected pop error: %v", err)
+	}
+	want := []string{"3", "4"}
+	if len(msgs) != len(want) {
+		t.Fatalf("Expected %d messages, got %q", len(want), msgs)
+	}
+	for i, m := range msgs {
+		if string(m) != want[i] {
+			t.Fatalf("Expected message %s, got %s", want[i], string(m))
+		}
+	}
+
+	msgs, err = s.Pop("foo/bar", 8)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("Expected the nested topic to keep its message, got %q %v", msgs, err)
+	}
+}
+
+func TestSpoolBlock(t *testing.T) {
+	s := NewSpool(store.NewMemoryStore(), broker.SpoolSize(1), broker.SpoolPolicy(broker.Block))
+	if _, err := s.Push(context.Background(), "foo", []byte("1")); err != nil {
+		t.Fatalf("Unexpected push error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, err := s.Push(ctx, "foo", []byte("2")); err != broker.ErrSpoolFull {
+		t.Fatalf("Expected %v, got %v", broker.ErrSpoolFull, err)
+	}
+	if dropped := s.Stats().Dropped["foo"]; dropped != 0 {
+		t.Fatalf("A blocking spool should not drop messages, dropped %d", dropped)
+	}
+}
+
+func TestSpoolPeekAck(t *testing.T) {
+	s := NewSpool(store.NewMemoryStore())
+	for _, m := range []string{"1", "2"} {
+		if _, err := s.Push(context.Background(), "foo", []byte(m)); err != nil {
+			t.Fatalf("Unexpected push error: %v", err)
+		}
+	}
+
+	msgs, err := s.Peek("foo", 2)
+	if err != nil || len(msgs) != 2 || string(msgs[0].Body) != "1" {
+		t.Fatalf("Expected messages 1 and 2, got %v %v", msgs, err)
+	}
+
+	// acking a message which isn't at the front leaves the queue as it is
+	if err := s.Ack("foo", msgs[1].Seq); err != nil {
+		t.Fatalf("Unexpected ack error: %v", err)
+	}
+	if depth := s.Stats().Depth["foo"]; depth != 2 {
+		t.Fatalf("Expected depth 2, got %d", depth)
+	}
+
+	if err := s.Ack("foo", msgs[0].Seq); err != nil {
+		t.Fatalf("Unexpected ack error: %v", err)
+	}
+	popped, _ := s.Pop("foo", 8)
+	if len(popped) != 1 || string(popped[0]) != "2" {
+		t.Fatalf("Expected only message 2, got %q", popped)
+	}
+}
+
+func TestSpoolShared(t *testing.T) {
+	st := store.NewMemoryStore()
+	s1 := NewSpool(st, broker.SpoolSize(3))
+	s2 := NewSpool(st, broker.SpoolSize(3))
+
+	// two processes push to the same queue, the oldest is dropped once it's full
+	var last uint64
+	for i, s := range []broker.Spool{s1, s2, s1, s2} {
+		seq, err := s.Push(context.Background(), "foo", []byte{byte('1' + i)})
+		if err != nil {
+			t.Fatalf("Unexpected push error: %v", err)
+		}
+		if seq <= last {
+			t.Fatalf("Expected a sequence after %d, got %d", last, seq)
+		}
+		last = seq
+	}
+	if depth := s1.Stats().Depth["foo"]; depth != 3 {
+		t.Fatalf("Expected depth 3, got %d", depth)
+	}
+
+	msgs, err := s2.Pop("foo", 8)
+	if err != nil {
+		t.Fatalf("Unexpected pop error: %v", err)
+	}
+	if len(msgs) != 3 || string(msgs[0]) != "2" || string(msgs[2]) != "4" {
+		t.Fatalf("Expected messages 2 to 4, got %q", msgs)
+	}
+	if depth := s1.Stats().Depth["foo"]; depth != 0 {
+		t.Fatalf("Expected empty spool, got depth %d", depth)
+	}
+}
@@ -0,0 +1,149 @@
+package broker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/broker"
+)
+
+func TestMemorySpool(t *testing.T) {
+	testCases := []struct {
+		name    string
+		policy  broker.DropPolicy
+		want    []string
+		dropped uint64
+	}{
+		{name: "DropOldest", policy: broker.DropOldest, want: []string{"2", "3"}, dropped: 1},
+		{name: "DropNewest", policy: broker.DropNewest, want: []string{"1", "2"}, dropped: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := broker.NewMemorySpool(broker.SpoolSize(2), broker.SpoolPolicy(tc.policy))
+			for _, m := range []string{"1", "2", "3"} {
+				if _, err := s.Push(context.Background(), "foo", []byte(m)); err != nil {
+					t.Fatalf("Unexpected push error: %v", err)
+				}
+			}
+
+			stats := s.Stats()
+			if stats.Depth["foo"] != 2 {
+				t.Fatalf("Expected depth 2, got %d", stats.Depth["foo"])
+			}
+			if stats.Dropped["foo"] != tc.dropped {
+				t.Fatalf("Expected %d dropped, got %d", tc.dropped, stats.Dropped["foo"])
+			}
+
+			msgs, err := s.Pop("foo", 8)
+			if err != nil {
+				t.Fatalf("Unexpected pop error: %v", err)
+			}
+			if len(msgs) != len(tc.want) {
+				t.Fatalf("Expected %d messages, got %d", len(tc.want), len(msgs))
+			}
+			for i, m := range msgs {
+				if string(m) != tc.want[i] {
+					t.Fatalf("Expected message %s, got %s", tc.want[i], string(m))
+				}
+			}
+			if depth := s.Stats().Depth["foo"]; depth != 0 {
+				t.Fatalf("Expected empty spool, got depth %d", depth)
+			}
+		})
+	}
+
+	t.Run("Block", func(t *testing.T) {
+		s := broker.NewMemorySpool(broker.SpoolSize(1), broker.SpoolPolicy(broker.Block))
+		if _, err := s.Push(context.Background(), "foo", []byte("1")); err != nil {
+			t.Fatalf("Unexpected push error: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		if _, err := s.Push(ctx, "foo", []byte("2")); err != broker.ErrSpoolFull {
+			t.Fatalf("Expected %v, got %v", broker.ErrSpoolFull, err)
+		}
+
+		// a blocked push completes once there is room
+		done := make(chan error)
+		go func() {
+			_, err := s.Push(context.Background(), "foo", []byte("3"))
+			done <- err
+		}()
+		time.Sleep(time.Millisecond * 50)
+		if _, err := s.Pop("foo", 1); err != nil {
+			t.Fatalf("Unexpected pop error: %v", err)
+		}
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Unexpected push error: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for blocked push")
+		}
+
+		msgs, _ := s.Pop("foo", 8)
+		if len(msgs) != 1 || string(msgs[0]) != "3" {
+			t.Fatalf("Expected only message 3, got %q", msgs)
+		}
+	})
+}
+
+func TestMemorySpoolPeekAck(t *testing.T) {
+	s := broker.NewMemorySpool(broker.SpoolSize(2))
+	for _, m := range []string{"1", "2"} {
+		if _, err := s.Push(context.Background(), "foo", []byte(m)); err != nil {
+			t.Fatalf("Unexpected push error: %v", err)
+		}
+	}
+
+	msgs, err := s.Peek("foo", 8)
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("Expected 2 messages, got %v %v", msgs, err)
+	}
+	if depth := s.Stats().Depth["foo"]; depth != 2 {
+		t.Fatalf("Expected peek to keep the messages, got depth %d", depth)
+	}
+
+	// the peeked message is dropped to make room before it is acked
+	if _, err := s.Push(context.Background(), "foo", []byte("3")); err != nil {
+		t.Fatalf("Unexpected push error: %v", err)
+	}
+	if err := s.Ack("foo", msgs[0].Seq); err != nil {
+		t.Fatalf("Unexpected ack error: %v", err)
+	}
+	if err := s.Ack("foo", msgs[1].Seq); err != nil {
+		t.Fatalf("Unexpected ack error: %v", err)
+	}
+
+	popped, _ := s.Pop("foo", 8)
+	if len(popped) != 1 || string(popped[0]) != "3" {
+		t.Fatalf("Expected only message 3, got %q", popped)
+	}
+}
+
+func TestMemorySpoolAckSameBody(t *testing.T) {
+	s := broker.NewMemorySpool(broker.SpoolSize(1))
+	if _, err := s.Push(context.Background(), "foo", []byte("1")); err != nil {
+		t.Fatalf("Unexpected push error: %v", err)
+	}
+	msgs, err := s.Peek("foo", 1)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %v %v", msgs, err)
+	}
+
+	// the peeked message is dropped for another with the same body, which the ack leaves
+	if _, err := s.Push(context.Background(), "foo", []byte("1")); err != nil {
+		t.Fatalf("Unexpected push error: %v", err)
+	}
+	if err := s.Ack("foo", msgs[0].Seq); err != nil {
+		t.Fatalf("Unexpected ack error: %v", err)
+	}
+	if depth := s.Stats().Depth["foo"]; depth != 1 {
+		t.Fatalf("Expected the message pushed to be kept, got depth %d", depth)
+	}
+}
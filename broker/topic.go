@@ -0,0 +1,47 @@
+package broker
+
+import "strings"
+
+const (
+	// TopicSeparator splits a topic into tokens for wildcard matching
+	TopicSeparator = "."
+	// WildcardToken matches exactly one token of a topic, e.g. orders.* matches orders.created
+	WildcardToken = "*"
+	// WildcardTail matches one or more tokens at the end of a topic, e.g. orders.> matches orders.eu.created
+	WildcardTail = ">"
+)
+
+// IsWildcard reports whether the topic contains a wildcard token
+func IsWildcard(topic string) bool {
+	for _, t := range strings.Split(topic, TopicSeparator) {
+		if t == WildcardToken || t == WildcardTail {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchTopic reports whether the topic matches the pattern. Tokens are separated by dots,
+// * matches a single token and > as the last token matches every remaining token.
+func MatchTopic(pattern, topic string) bool {
+	if pattern == topic {
+		return true
+	}
+
+	p := strings.Split(pattern, TopicSeparator)
+	t := strings.Split(topic, TopicSeparator)
+
+	for i, token := range p {
+		if token == WildcardTail && i == len(p)-1 {
+			return len(t) > i
+		}
+		if i >= len(t) {
+			return false
+		}
+		if token != WildcardToken && token != t[i] {
+			return false
+		}
+	}
+
+	return len(p) == len(t)
+}
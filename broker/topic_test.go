@@ -0,0 +1,36 @@
+package broker_test
+
+import (
+	"testing"
+
+	"go-micro.dev/v4/broker"
+)
+
+func TestMatchTopic(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		topic   string
+		match   bool
+	}{
+		{"orders", "orders", true},
+		{"orders", "orders.created", false},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders", false},
+		{"orders.*", "orders.eu.created", false},
+		{"orders.*.created", "orders.eu.created", true},
+		{"orders.*.created", "orders.eu.paid", false},
+		{"orders.>", "orders.created", true},
+		{"orders.>", "orders.eu.created", true},
+		{"orders.>", "orders", false},
+		{"*.created", "payments.created", true},
+		{">", "orders.created", true},
+		{"orders.>.created", "orders.>.created", true},
+		{"orders.>.created", "orders.eu.created", false},
+	}
+
+	for _, tc := range testCases {
+		if got := broker.MatchTopic(tc.pattern, tc.topic); got != tc.match {
+			t.Errorf("MatchTopic(%q, %q) = %v, expected %v", tc.pattern, tc.topic, got, tc.match)
+		}
+	}
+}
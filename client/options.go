@@ -64,6 +64,9 @@ type CallOptions struct {
 	ServiceToken bool
 	// Duration to cache the response for
 	CacheExpiry time.Duration
+	// HashKey routes calls with the same key to the same node, see
+	// WithHashKey. The Micro-Hash-Key header is used if it is empty.
+	HashKey string
 
 	// Middleware for low level call func
 	CallWrappers []CallWrapper
@@ -76,6 +79,15 @@ type CallOptions struct {
 type PublishOptions struct {
 	// Exchange is the routing exchange for the message
 	Exchange string
+	// MessageID is sent in the Micro-Id header rather than a generated one,
+	// so subscribers can drop duplicates when publishing is retried
+	MessageID string
+	// Reply is decoded from the reply to the message when set,
+	// the message is then sent as a request, see broker.Request
+	Reply interface{}
+	// ReplyTimeout is how long to wait for the reply,
+	// the call options request timeout is used when it is zero
+	ReplyTimeout time.Duration
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -248,6 +260,27 @@ func WithExchange(e string) PublishOption {
 	}
 }
 
+// WithMessageID sets the ID of the message, see PublishOptions.MessageID
+func WithMessageID(id string) PublishOption {
+	return func(o *PublishOptions) {
+		o.MessageID = id
+	}
+}
+
+// WithReply waits for a reply to the message and decodes it into rsp
+func WithReply(rsp interface{}) PublishOption {
+	return func(o *PublishOptions) {
+		o.Reply = rsp
+	}
+}
+
+// WithReplyTimeout sets how long to wait for a reply, see WithReply
+func WithReplyTimeout(d time.Duration) PublishOption {
+	return func(o *PublishOptions) {
+		o.ReplyTimeout = d
+	}
+}
+
 // PublishContext sets the context in publish options
 func PublishContext(ctx context.Context) PublishOption {
 	return func(o *PublishOptions) {
@@ -268,6 +301,14 @@ func WithSelectOption(so ...selector.SelectOption) CallOption {
 	}
 }
 
+// WithHashKey routes calls with the same key, such as a user id, to the same
+// node by consistent hashing, see selector.ConsistentHash
+func WithHashKey(key string) CallOption {
+	return func(o *CallOptions) {
+		o.HashKey = key
+	}
+}
+
 // WithCallWrapper is a CallOption which adds to the existing CallFunc wrappers
 func WithCallWrapper(cw ...CallWrapper) CallOption {
 	return func(o *CallOptions) {
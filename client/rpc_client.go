@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"sync/atomic"
@@ -321,7 +322,7 @@ func (r *rpcClient) Options() Options {
 }
 
 // next returns an iterator for the next nodes to call
-func (r *rpcClient) next(request Request, opts CallOptions) (selector.Next, error) {
+func (r *rpcClient) next(ctx context.Context, request Request, opts CallOptions) (selector.Next, error) {
 	// try get the proxy
 	service, address, _ := net.Proxy(request.Service(), opts.Address)
 
@@ -345,8 +346,19 @@ func (r *rpcClient) next(request Request, opts CallOptions) (selector.Next, erro
 		}, nil
 	}
 
+	sopts := opts.SelectOptions
+
+	// route calls with the same key to the same node
+	key := opts.HashKey
+	if len(key) == 0 {
+		key, _ = metadata.Get(ctx, "Micro-Hash-Key")
+	}
+	if len(key) > 0 {
+		sopts = append(sopts[:len(sopts):len(sopts)], selector.WithHashKey(key))
+	}
+
 	// get next nodes from the selector
-	next, err := r.opts.Selector.Select(service, opts.SelectOptions...)
+	next, err := r.opts.Selector.Select(service, sopts...)
 	if err != nil {
 		if err == selector.ErrNotFound {
 			return nil, errors.InternalServerError("go.micro.client", "service %s: %s", service, err.Error())
@@ -364,7 +376,7 @@ func (r *rpcClient) Call(ctx context.Context, request Request, response interfac
 		opt(&callOpts)
 	}
 
-	next, err := r.next(request, callOpts)
+	next, err := r.next(ctx, request, callOpts)
 	if err != nil {
 		return err
 	}
@@ -422,8 +434,9 @@ func (r *rpcClient) Call(ctx context.Context, request Request, response interfac
 		}
 
 		// make the call
+		done := selector.Track(r.opts.Selector, service, node)
 		err = rcall(ctx, node, request, response, callOpts)
-		r.opts.Selector.Mark(service, node, err)
+		done(err)
 		return err
 	}
 
@@ -475,7 +488,7 @@ func (r *rpcClient) Stream(ctx context.Context, request Request, opts ...CallOpt
 		opt(&callOpts)
 	}
 
-	next, err := r.next(request, callOpts)
+	next, err := r.next(ctx, request, callOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -508,8 +521,9 @@ func (r *rpcClient) Stream(ctx context.Context, request Request, opts ...CallOpt
 			return nil, errors.InternalServerError("go.micro.client", "error getting next %s node: %s", service, err.Error())
 		}
 
+		done := selector.Track(r.opts.Selector, service, node)
 		stream, err := r.stream(ctx, node, request, callOpts)
-		r.opts.Selector.Mark(service, node, err)
+		done(err)
 		return stream, err
 	}
 
@@ -573,7 +587,10 @@ func (r *rpcClient) Publish(ctx context.Context, msg Message, opts ...PublishOpt
 		md = make(map[string]string)
 	}
 
-	id := uuid.New().String()
+	id := options.MessageID
+	if len(id) == 0 {
+		id = uuid.New().String()
+	}
 	md["Content-Type"] = msg.ContentType()
 	md["Micro-Topic"] = msg.Topic()
 	md["Micro-Id"] = id
@@ -623,10 +640,62 @@ func (r *rpcClient) Publish(ctx context.Context, msg Message, opts ...PublishOpt
 		r.once.Store(true)
 	}
 
-	return r.opts.Broker.Publish(topic, &broker.Message{
+	if options.Reply == nil {
+		return r.opts.Broker.Publish(topic, &broker.Message{
+			Header: md,
+			Body:   body,
+		}, broker.PublishContext(options.Context))
+	}
+
+	timeout := options.ReplyTimeout
+	if timeout == 0 {
+		timeout = r.opts.CallOptions.RequestTimeout
+	}
+
+	rsp, err := broker.Request(r.opts.Broker, topic, &broker.Message{
 		Header: md,
 		Body:   body,
-	}, broker.PublishContext(options.Context))
+	},
+		broker.RequestTimeout(timeout),
+		broker.RequestContext(ctx),
+		broker.RequestPublish(broker.PublishContext(options.Context)),
+	)
+	if err == broker.ErrRequestTimeout {
+		return errors.Timeout("go.micro.client", err.Error())
+	} else if err != nil {
+		return errors.InternalServerError("go.micro.client", err.Error())
+	}
+
+	return r.decodeReply(rsp, msg.ContentType(), options.Reply)
+}
+
+// decodeReply decodes the body of a reply using its content type, or that of the request
+func (r *rpcClient) decodeReply(rsp *broker.Message, contentType string, v interface{}) error {
+	if d, ok := v.(*raw.Frame); ok {
+		d.Data = rsp.Body
+		return nil
+	}
+
+	if ct, ok := rsp.Header["Content-Type"]; ok {
+		contentType = ct
+	}
+
+	cf, err := r.newCodec(contentType)
+	if err != nil {
+		return errors.InternalServerError("go.micro.client", err.Error())
+	}
+
+	cc := cf(buf.New(bytes.NewBuffer(rsp.Body)))
+	defer cc.Close()
+
+	if err := cc.ReadHeader(&codec.Message{}, codec.Event); err != nil {
+		return errors.InternalServerError("go.micro.client", err.Error())
+	}
+	if err := cc.ReadBody(v); err != nil {
+		return errors.InternalServerError("go.micro.client", err.Error())
+	}
+
+	return nil
 }
 
 func (r *rpcClient) NewMessage(topic string, message interface{}, opts ...MessageOption) Message {
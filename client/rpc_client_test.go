@@ -2,9 +2,12 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
+	"go-micro.dev/v4/broker"
 	"go-micro.dev/v4/errors"
 	"go-micro.dev/v4/registry"
 	"go-micro.dev/v4/selector"
@@ -158,3 +161,45 @@ func TestCallWrapper(t *testing.T) {
 		t.Fatal("wrapper not called")
 	}
 }
+
+func TestPublishReply(t *testing.T) {
+	b := broker.NewMemoryBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	// a consumer only reachable through the broker
+	_, err := b.Subscribe("greeter", func(e broker.Event) error {
+		var name string
+		if err := json.Unmarshal(e.Message().Body, &name); err != nil {
+			return err
+		}
+		body, _ := json.Marshal("hello " + name)
+		return broker.Reply(b, e.Message(), &broker.Message{
+			Header: map[string]string{"Content-Type": "application/json"},
+			Body:   body,
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient(Broker(b), Registry(newTestRegistry()))
+
+	var rsp string
+	msg := c.NewMessage("greeter", "john", WithMessageContentType("application/json"))
+	if err := c.Publish(context.Background(), msg, WithReply(&rsp)); err != nil {
+		t.Fatal("publish with reply error", err)
+	}
+	if rsp != "hello john" {
+		t.Fatalf("expected reply hello john got %s", rsp)
+	}
+
+	// a request without a responder times out
+	msg = c.NewMessage("farewell", "john", WithMessageContentType("application/json"))
+	err = c.Publish(context.Background(), msg, WithReply(&rsp), WithReplyTimeout(time.Millisecond*100))
+	if err == nil || errors.Parse(err.Error()).Code != 408 {
+		t.Fatalf("expected timeout error got %v", err)
+	}
+}
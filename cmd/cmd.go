@@ -162,6 +162,11 @@ var (
 			EnvVars: []string{"MICRO_SELECTOR"},
 			Usage:   "Selector used to pick nodes for querying",
 		},
+		&cli.StringFlag{
+			Name:    "selector_strategy",
+			EnvVars: []string{"MICRO_SELECTOR_STRATEGY"},
+			Usage:   "Strategy the selector picks nodes with e.g random, roundrobin, least_outstanding, p2c, peak_ewma",
+		},
 		&cli.StringFlag{
 			Name:    "store",
 			EnvVars: []string{"MICRO_STORE"},
@@ -248,6 +253,14 @@ var (
 
 	DefaultSelectors = map[string]func(...selector.Option) selector.Selector{}
 
+	DefaultStrategies = map[string]selector.Strategy{
+		"random":            selector.Random,
+		"roundrobin":        selector.RoundRobin,
+		"least_outstanding": selector.LeastOutstanding,
+		"p2c":               selector.PowerOfTwo,
+		"peak_ewma":         selector.PeakEWMA,
+	}
+
 	DefaultServers = map[string]func(...server.Option) server.Server{}
 
 	DefaultTransports = map[string]func(...transport.Option) transport.Transport{}
@@ -294,6 +307,7 @@ func newCmd(opts ...Option) Cmd {
 		Clients:    DefaultClients,
 		Registries: DefaultRegistries,
 		Selectors:  DefaultSelectors,
+		Strategies: DefaultStrategies,
 		Servers:    DefaultServers,
 		Transports: DefaultTransports,
 		Runtimes:   DefaultRuntimes,
@@ -474,6 +488,18 @@ func (c *cmd) Before(ctx *cli.Context) error {
 		clientOpts = append(clientOpts, client.Selector(*c.opts.Selector))
 	}
 
+	// Set the selector strategy
+	if name := ctx.String("selector_strategy"); len(name) > 0 {
+		s, ok := c.opts.Strategies[name]
+		if !ok {
+			return fmt.Errorf("Selector strategy %s not found", name)
+		}
+
+		if err := (*c.opts.Selector).Init(selector.SetStrategy(s)); err != nil {
+			logger.Fatalf("Error configuring selector strategy: %v", err)
+		}
+	}
+
 	// Set the transport
 	if name := ctx.String("transport"); len(name) > 0 && (*c.opts.Transport).String() != name {
 		t, ok := c.opts.Transports[name]
@@ -45,6 +45,7 @@ type Options struct {
 	Clients    map[string]func(...client.Option) client.Client
 	Registries map[string]func(...registry.Option) registry.Registry
 	Selectors  map[string]func(...selector.Option) selector.Selector
+	Strategies map[string]selector.Strategy
 	Servers    map[string]func(...server.Option) server.Server
 	Transports map[string]func(...transport.Option) transport.Transport
 	Runtimes   map[string]func(...runtime.Option) runtime.Runtime
@@ -234,6 +235,13 @@ func NewConfig(name string, t func(...config.Option) (config.Config, error)) Opt
 	}
 }
 
+// New selector strategy
+func NewStrategy(name string, s selector.Strategy) Option {
+	return func(o *Options) {
+		o.Strategies[name] = s
+	}
+}
+
 // New profile func
 func NewProfile(name string, t func(...profile.Option) profile.Profile) Option {
 	return func(o *Options) {
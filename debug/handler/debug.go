@@ -3,6 +3,7 @@ package handler
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"go-micro.dev/v4/client"
@@ -10,9 +11,36 @@ import (
 	proto "go-micro.dev/v4/debug/proto"
 	"go-micro.dev/v4/debug/stats"
 	"go-micro.dev/v4/debug/trace"
+	"go-micro.dev/v4/registry"
 	"go-micro.dev/v4/server"
 )
 
+func init() {
+	// rpc health checks call the debug handler of the node
+	registry.RegisterProbe(registry.CheckRPC, rpcProbe)
+}
+
+// rpcProbe calls Debug.Health on the node, it is healthy if the status is ok
+func rpcProbe(ctx context.Context, service string, node *registry.Node, check *registry.Check) error {
+	address := check.Target
+	if len(address) == 0 {
+		address = node.Address
+	}
+
+	rsp, err := proto.NewDebugService(service, client.DefaultClient).Health(
+		ctx,
+		&proto.HealthRequest{Service: service},
+		client.WithAddress(address),
+	)
+	if err != nil {
+		return err
+	}
+	if rsp.Status != "ok" {
+		return fmt.Errorf("health check returned %v", rsp.Status)
+	}
+	return nil
+}
+
 // NewHandler returns an instance of the Debug Handler
 func NewHandler(c client.Client) *Debug {
 	return &Debug{
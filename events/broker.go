@@ -0,0 +1,460 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/logger"
+)
+
+const (
+	// IDHeader is the message header holding the ID of an event published through a broker
+	IDHeader = "Micro-Event-Id"
+	// TimestampHeader is the message header holding the timestamp of an event published through a broker
+	TimestampHeader = "Micro-Event-Timestamp"
+	// PartitionKeyHeader is the message header holding the partition key of an event
+	PartitionKeyHeader = "Micro-Partition-Key"
+
+	// brokerAckWait is how long a stream broker waits for a message to be acked before redelivering it
+	brokerAckWait = time.Second * 30
+)
+
+var (
+	// ErrEventNacked is returned to the broker when an event consumed from it is nacked
+	ErrEventNacked = errors.New("Event was nacked")
+	// ErrOffsetNotSupported is returned when consuming from an offset through a broker
+	ErrOffsetNotSupported = errors.New("Broker does not support consuming from an offset")
+	// ErrConsumerStopped is returned to the broker for events received after the consumer stopped
+	ErrConsumerStopped = errors.New("Consumer stopped")
+)
+
+// NewBrokerStream returns a stream which publishes and consumes through the broker. The broker is
+// connected if it isn't already. Acking an event acks the broker event, and nacking or not acking
+// it within the AckWait returns an error to the broker, which may redeliver it.
+func NewBrokerStream(b broker.Broker) (Stream, error) {
+	if err := b.Connect(); err != nil {
+		return nil, errors.Wrap(err, "Error connecting to broker")
+	}
+	return &brokerStream{broker: b}, nil
+}
+
+type brokerStream struct {
+	broker broker.Broker
+}
+
+func (s *brokerStream) Publish(topic string, msg interface{}, opts ...PublishOption) error {
+	// validate the topic
+	if len(topic) == 0 {
+		return ErrMissingTopic
+	}
+
+	// parse the options
+	options := PublishOptions{
+		Timestamp: time.Now(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// encode the message if it's not already encoded
+	var payload []byte
+	if p, ok := msg.([]byte); ok {
+		payload = p
+	} else {
+		p, err := json.Marshal(msg)
+		if err != nil {
+			return ErrEncodingMessage
+		}
+		payload = p
+	}
+
+	header := make(map[string]string, len(options.Metadata)+3)
+	for k, v := range options.Metadata {
+		header[k] = v
+	}
+	header[IDHeader] = uuid.New().String()
+	header[TimestampHeader] = options.Timestamp.Format(time.RFC3339Nano)
+	if len(options.PartitionKey) > 0 {
+		header[PartitionKeyHeader] = options.PartitionKey
+	}
+
+	return s.broker.Publish(topic, &broker.Message{Header: header, Body: payload})
+}
+
+func (s *brokerStream) Consume(topic string, opts ...ConsumeOption) (<-chan Event, error) {
+	// validate the topic
+	if len(topic) == 0 {
+		return nil, ErrMissingTopic
+	}
+
+	// parse the options
+	options := ConsumeOptions{
+		AutoAck: true,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if !options.Offset.IsZero() {
+		return nil, ErrOffsetNotSupported
+	}
+	if !options.AutoAck && options.AckWait == 0 {
+		return nil, errors.New("invalid AckWait passed, should be positive integer")
+	}
+
+	subOpts := []broker.SubscribeOption{broker.DisableAutoAck()}
+	if len(options.Group) > 0 {
+		subOpts = append(subOpts, broker.Queue(options.Group))
+	}
+
+	ch := make(chan Event)
+	// exit is closed when the consumer stops, the handlers still running return
+	// to the broker before ch is closed
+	exit := make(chan bool)
+	var (
+		mtx      sync.RWMutex
+		stopped  bool
+		handlers sync.WaitGroup
+	)
+
+	sub, err := s.broker.Subscribe(topic, func(be broker.Event) error {
+		mtx.RLock()
+		if stopped {
+			mtx.RUnlock()
+			return ErrConsumerStopped
+		}
+		handlers.Add(1)
+		mtx.RUnlock()
+		defer handlers.Done()
+
+		ev := brokerEvent(be)
+		if options.AutoAck {
+			select {
+			case ch <- *ev:
+			case <-exit:
+				return ErrConsumerStopped
+			}
+			return be.Ack()
+		}
+
+		// the handler returns once the event is acked or nacked so the broker can redeliver it
+		acks := make(chan bool, 1)
+		signal := func(ack bool) func() error {
+			return func() error {
+				select {
+				case acks <- ack:
+				default:
+				}
+				return nil
+			}
+		}
+		ev.SetAckFunc(signal(true))
+		ev.SetNackFunc(signal(false))
+		select {
+		case ch <- *ev:
+		case <-exit:
+			return ErrConsumerStopped
+		}
+
+		t := time.NewTimer(options.AckWait)
+		defer t.Stop()
+		select {
+		case ok := <-acks:
+			if !ok {
+				return ErrEventNacked
+			}
+			return be.Ack()
+		case <-t.C:
+			return errors.Errorf("Event not acked within %v", options.AckWait)
+		case <-exit:
+			return ErrConsumerStopped
+		}
+	}, subOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error subscribing to broker")
+	}
+
+	if options.Context != nil {
+		go func() {
+			<-options.Context.Done()
+
+			// release the handlers blocked on the consumer first, brokers may
+			// wait for them to return before unsubscribing
+			mtx.Lock()
+			stopped = true
+			mtx.Unlock()
+			close(exit)
+
+			if err := sub.Unsubscribe(); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Error unsubscribing from %v: %v", topic, err)
+			}
+
+			// close the channel once no handler can send to it
+			handlers.Wait()
+			close(ch)
+		}()
+	}
+
+	return ch, nil
+}
+
+// brokerEvent converts a broker event to an event
+func brokerEvent(be broker.Event) *Event {
+	ev := &Event{Topic: be.Topic()}
+
+	msg := be.Message()
+	if msg == nil {
+		msg = &broker.Message{}
+	}
+	ev.Payload = msg.Body
+
+	md := make(map[string]string, len(msg.Header))
+	for k, v := range msg.Header {
+		switch k {
+		case IDHeader:
+			ev.ID = v
+		case TimestampHeader:
+			ev.Timestamp, _ = time.Parse(time.RFC3339Nano, v)
+		case PartitionKeyHeader:
+			ev.PartitionKey = v
+		default:
+			md[k] = v
+		}
+	}
+	ev.Metadata = md
+
+	// messages not published through a stream have neither
+	if len(ev.ID) == 0 {
+		ev.ID = uuid.New().String()
+	}
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+
+	return ev
+}
+
+// NewStreamBroker returns a broker which publishes and subscribes through the stream, so subscribers
+// of a durable stream can be registered with server.Subscribe and published to with client.Publish.
+// Messages are acked when the handler returns nil, unless auto ack is disabled, and redelivered
+// by the stream when the handler returns an error.
+func NewStreamBroker(s Stream, opts ...broker.Option) broker.Broker {
+	options := broker.Options{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &streamBroker{
+		opts:   options,
+		stream: s,
+		subs:   make(map[*streamSubscriber]bool),
+	}
+}
+
+type streamBroker struct {
+	opts   broker.Options
+	stream Stream
+
+	sync.RWMutex
+	connected bool
+	subs      map[*streamSubscriber]bool
+}
+
+type streamSubscriber struct {
+	topic  string
+	opts   broker.SubscribeOptions
+	cancel context.CancelFunc
+	broker *streamBroker
+}
+
+type streamEvent struct {
+	topic   string
+	message *broker.Message
+	event   Event
+	err     error
+}
+
+func (b *streamBroker) Init(opts ...broker.Option) error {
+	for _, o := range opts {
+		o(&b.opts)
+	}
+	return nil
+}
+
+func (b *streamBroker) Options() broker.Options {
+	return b.opts
+}
+
+func (b *streamBroker) Address() string {
+	return ""
+}
+
+func (b *streamBroker) Connect() error {
+	b.Lock()
+	defer b.Unlock()
+	b.connected = true
+	return nil
+}
+
+// Disconnect unsubscribes every subscriber
+func (b *streamBroker) Disconnect() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.connected {
+		return nil
+	}
+	b.connected = false
+
+	for sub := range b.subs {
+		sub.cancel()
+	}
+	b.subs = make(map[*streamSubscriber]bool)
+
+	return nil
+}
+
+func (b *streamBroker) Publish(topic string, m *broker.Message, opts ...broker.PublishOption) error {
+	b.RLock()
+	connected := b.connected
+	b.RUnlock()
+	if !connected {
+		return errors.New("not connected")
+	}
+
+	var pubOpts []PublishOption
+	if len(m.Header) > 0 {
+		md := make(map[string]string, len(m.Header))
+		for k, v := range m.Header {
+			md[k] = v
+		}
+		pubOpts = append(pubOpts, WithMetadata(md))
+		if key, ok := m.Header[PartitionKeyHeader]; ok {
+			pubOpts = append(pubOpts, WithPartitionKey(key))
+		}
+	}
+
+	return b.stream.Publish(topic, m.Body, pubOpts...)
+}
+
+func (b *streamBroker) Subscribe(topic string, h broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.connected {
+		return nil, errors.New("not connected")
+	}
+
+	options := broker.NewSubscribeOptions(opts...)
+	ctx, cancel := context.WithCancel(b.opts.Context)
+
+	consumeOpts := []ConsumeOption{
+		WithAutoAck(false, brokerAckWait),
+		WithContext(ctx),
+	}
+	if len(options.Queue) > 0 {
+		consumeOpts = append(consumeOpts, WithGroup(options.Queue))
+	}
+
+	ch, err := b.stream.Consume(topic, consumeOpts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	sub := &streamSubscriber{
+		topic:  topic,
+		opts:   options,
+		cancel: cancel,
+		broker: b,
+	}
+	b.subs[sub] = true
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-ch:
+				b.handle(sub, h, ev)
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+// handle passes the event to the handler, acking it if the handler succeeds and nacking it if it fails
+func (b *streamBroker) handle(sub *streamSubscriber, h broker.Handler, ev Event) {
+	header := make(map[string]string, len(ev.Metadata))
+	for k, v := range ev.Metadata {
+		header[k] = v
+	}
+
+	p := &streamEvent{
+		topic:   ev.Topic,
+		message: &broker.Message{Header: header, Body: ev.Payload},
+		event:   ev,
+	}
+
+	if err := h(p); err != nil {
+		p.err = err
+		if eh := b.opts.ErrorHandler; eh != nil {
+			eh(p)
+		} else if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("[events]: subscriber error: %v", err)
+		}
+		if err := ev.Nack(); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("[events]: failed to nack: %v", err)
+		}
+		return
+	}
+
+	if sub.opts.AutoAck {
+		if err := p.Ack(); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+			logger.Errorf("[events]: failed to ack: %v", err)
+		}
+	}
+}
+
+func (b *streamBroker) String() string {
+	return "events"
+}
+
+func (s *streamSubscriber) Options() broker.SubscribeOptions {
+	return s.opts
+}
+
+func (s *streamSubscriber) Topic() string {
+	return s.topic
+}
+
+func (s *streamSubscriber) Unsubscribe() error {
+	s.broker.Lock()
+	delete(s.broker.subs, s)
+	s.broker.Unlock()
+	s.cancel()
+	return nil
+}
+
+func (e *streamEvent) Topic() string {
+	return e.topic
+}
+
+func (e *streamEvent) Message() *broker.Message {
+	return e.message
+}
+
+func (e *streamEvent) Ack() error {
+	return e.event.Ack()
+}
+
+func (e *streamEvent) Error() error {
+	return e.err
+}
@@ -0,0 +1,178 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go-micro.dev/v4/broker"
+)
+
+func TestBrokerStream(t *testing.T) {
+	stream, err := NewBrokerStream(broker.NewMemoryBroker())
+	assert.NoError(t, err, "NewBrokerStream should not return an error")
+
+	t.Run("Publish", func(t *testing.T) {
+		ch, err := stream.Consume("bridge")
+		assert.NoError(t, err, "Unexpected error subscribing")
+
+		ts := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+		go func() {
+			err := stream.Publish("bridge", testPayload{Message: "hello"}, WithMetadata(map[string]string{"foo": "bar"}),
+				WithTimestamp(ts), WithPartitionKey("key"))
+			assert.NoError(t, err, "Unexpected error publishing")
+		}()
+
+		select {
+		case ev := <-ch:
+			var p testPayload
+			assert.NoError(t, ev.Unmarshal(&p))
+			assert.Equal(t, "hello", p.Message)
+			assert.Equal(t, "bridge", ev.Topic)
+			assert.NotEmpty(t, ev.ID, "The event ID should be set")
+			assert.True(t, ts.Equal(ev.Timestamp), "The timestamp should be kept")
+			assert.Equal(t, "key", ev.PartitionKey)
+			assert.Equal(t, map[string]string{"foo": "bar"}, ev.Metadata, "Only the metadata should be in the metadata")
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for event")
+		}
+	})
+
+	t.Run("Nack", func(t *testing.T) {
+		ch, err := stream.Consume("bridgeNack", WithAutoAck(false, time.Second))
+		assert.NoError(t, err, "Unexpected error subscribing")
+
+		go func() {
+			ev := <-ch
+			assert.NoError(t, ev.Nack())
+		}()
+		err = stream.Publish("bridgeNack", testPayload{Message: "hello"})
+		assert.Equal(t, ErrEventNacked, err, "The nack should be returned to the broker")
+
+		go func() {
+			ev := <-ch
+			assert.NoError(t, ev.Ack())
+		}()
+		assert.NoError(t, stream.Publish("bridgeNack", testPayload{Message: "hello"}))
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := stream.Consume("bridgeContext", WithContext(ctx))
+		assert.NoError(t, err, "Unexpected error subscribing")
+
+		// the publish blocks on the consumer until it stops
+		published := make(chan error, 1)
+		go func() {
+			published <- stream.Publish("bridgeContext", testPayload{Message: "hello"})
+		}()
+		time.Sleep(time.Millisecond * 50)
+		cancel()
+
+		select {
+		case err := <-published:
+			assert.Equal(t, ErrConsumerStopped, err, "The broker should be told the event wasn't consumed")
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for the handler to return")
+		}
+		select {
+		case _, ok := <-ch:
+			assert.False(t, ok, "The channel should be closed")
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for the channel to close")
+		}
+	})
+
+	t.Run("Headers", func(t *testing.T) {
+		b := broker.NewMemoryBroker()
+		stream, err := NewBrokerStream(b)
+		assert.NoError(t, err)
+		ch, err := stream.Consume("bridgeHeaders")
+		assert.NoError(t, err, "Unexpected error subscribing")
+
+		// messages not published through a stream have no event headers
+		go b.Publish("bridgeHeaders", &broker.Message{Body: []byte(`{}`)})
+
+		select {
+		case ev := <-ch:
+			assert.NotEmpty(t, ev.ID, "An event ID should be generated")
+			assert.False(t, ev.Timestamp.IsZero(), "A timestamp should be set")
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for event")
+		}
+	})
+
+	t.Run("Offset", func(t *testing.T) {
+		_, err := stream.Consume("bridge", WithOffset(time.Now()))
+		assert.Equal(t, ErrOffsetNotSupported, err)
+	})
+}
+
+func TestStreamBroker(t *testing.T) {
+	stream, err := NewStream()
+	assert.NoError(t, err, "NewStream should not return an error")
+
+	b := NewStreamBroker(stream)
+	assert.NoError(t, b.Connect())
+
+	t.Run("Subscribe", func(t *testing.T) {
+		received := make(chan *broker.Message, 1)
+		sub, err := b.Subscribe("bridged", func(e broker.Event) error {
+			received <- e.Message()
+			return nil
+		})
+		assert.NoError(t, err, "Unexpected error subscribing")
+
+		msg := &broker.Message{Header: map[string]string{"foo": "bar"}, Body: []byte("hello")}
+		assert.NoError(t, b.Publish("bridged", msg))
+
+		select {
+		case m := <-received:
+			assert.Equal(t, msg.Header, m.Header)
+			assert.Equal(t, msg.Body, m.Body)
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for message")
+		}
+
+		// nothing is received once unsubscribed
+		assert.NoError(t, sub.Unsubscribe())
+		time.Sleep(time.Millisecond * 50)
+		assert.NoError(t, b.Publish("bridged", msg))
+		select {
+		case <-received:
+			t.Fatalf("Unexpected message received")
+		case <-time.After(time.Millisecond * 100):
+		}
+	})
+
+	t.Run("Redeliver", func(t *testing.T) {
+		attempts := make(chan int, 2)
+		count := 0
+		_, err := b.Subscribe("bridgedRetry", func(e broker.Event) error {
+			count++
+			attempts <- count
+			if count == 1 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+		assert.NoError(t, err, "Unexpected error subscribing")
+		assert.NoError(t, b.Publish("bridgedRetry", &broker.Message{Body: []byte("hello")}))
+
+		for i := 1; i <= 2; i++ {
+			select {
+			case n := <-attempts:
+				assert.Equal(t, i, n)
+			case <-time.After(time.Second):
+				t.Fatalf("Timed out waiting for delivery %d", i)
+			}
+		}
+	})
+
+	t.Run("Disconnect", func(t *testing.T) {
+		assert.NoError(t, b.Disconnect())
+		assert.Error(t, b.Publish("bridged", &broker.Message{}), "Publishing when disconnected should fail")
+	})
+}
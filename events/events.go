@@ -12,6 +12,9 @@ var (
 	DefaultStream Stream
 	// DefaultStore is the default events store implementation
 	DefaultStore Store
+	// DefaultBackoff is the delay before redelivering an event unless set by WithBackoff,
+	// none so events are redelivered straight away
+	DefaultBackoff BackoffFunc = func(int) time.Duration { return 0 }
 )
 
 var (
@@ -19,6 +22,23 @@ var (
 	ErrMissingTopic = errors.New("Missing topic")
 	// ErrEncodingMessage is returned from publish if there was an error encoding the message option
 	ErrEncodingMessage = errors.New("Error encoding message")
+	// ErrEventNotFound is returned when replaying an event which is not on the dead letter topic
+	ErrEventNotFound = errors.New("Event not found")
+	// ErrNotDeadLetter is returned when replaying an event which was not dead-lettered
+	ErrNotDeadLetter = errors.New("Event was not dead-lettered")
+	// ErrDeadLettersNotSupported is returned when the stream can't list or replay dead letters
+	ErrDeadLettersNotSupported = errors.New("Stream does not support dead letters")
+)
+
+const (
+	// DeadLetterTopicKey is the metadata key of the topic a dead-lettered event was consumed from
+	DeadLetterTopicKey = "Micro-Dead-Letter-Topic"
+	// DeadLetterIDKey is the metadata key of the ID of the original event
+	DeadLetterIDKey = "Micro-Dead-Letter-Id"
+	// DeadLetterReasonKey is the metadata key of the reason the event was dead-lettered
+	DeadLetterReasonKey = "Micro-Dead-Letter-Reason"
+	// DeadLetterAttemptsKey is the metadata key of the number of deliveries attempted
+	DeadLetterAttemptsKey = "Micro-Dead-Letter-Attempts"
 )
 
 // Stream is an event streaming interface
@@ -27,6 +47,15 @@ type Stream interface {
 	Consume(topic string, opts ...ConsumeOption) (<-chan Event, error)
 }
 
+// DeadLetterQueue is implemented by streams which can list and replay the events
+// published to a dead letter topic, see WithDeadLetter
+type DeadLetterQueue interface {
+	// DeadLetters returns the events on a dead letter topic
+	DeadLetters(topic string, opts ...ReadOption) ([]*Event, error)
+	// Replay publishes a dead-lettered event back to the topic it was consumed from
+	Replay(topic, id string) error
+}
+
 // Store is an event store interface
 type Store interface {
 	Read(topic string, opts ...ReadOption) ([]*Event, error)
@@ -48,6 +77,8 @@ type Event struct {
 	Metadata map[string]string
 	// Payload contains the encoded message
 	Payload []byte
+	// PartitionKey the event was published with, see WithPartitionKey
+	PartitionKey string `json:",omitempty"`
 
 	ackFunc  AckFunc
 	nackFunc NackFunc
@@ -90,3 +121,21 @@ func Consume(topic string, opts ...ConsumeOption) (<-chan Event, error) {
 func Read(topic string, opts ...ReadOption) ([]*Event, error) {
 	return DefaultStore.Read(topic, opts...)
 }
+
+// DeadLetters returns the events on a dead letter topic of the default stream
+func DeadLetters(topic string, opts ...ReadOption) ([]*Event, error) {
+	dlq, ok := DefaultStream.(DeadLetterQueue)
+	if !ok {
+		return nil, ErrDeadLettersNotSupported
+	}
+	return dlq.DeadLetters(topic, opts...)
+}
+
+// Replay a dead-lettered event of the default stream back to the topic it was consumed from
+func Replay(topic, id string) error {
+	dlq, ok := DefaultStream.(DeadLetterQueue)
+	if !ok {
+		return ErrDeadLettersNotSupported
+	}
+	return dlq.Replay(topic, id)
+}
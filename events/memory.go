@@ -3,6 +3,9 @@ package events
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,26 +22,200 @@ func NewStream(opts ...Option) (Stream, error) {
 	for _, o := range opts {
 		o(&options)
 	}
-	return &mem{store: store.NewMemoryStore()}, nil
+	if options.Store == nil {
+		options.Store = store.NewMemoryStore()
+	}
+	if options.Partitions <= 0 {
+		options.Partitions = defaultPartitions
+	}
+	return &mem{
+		store:      options.Store,
+		partitions: options.Partitions,
+		offsets:    make(map[string]uint64),
+		groups:     make(map[string]*group),
+	}, nil
 }
 
+const (
+	// offsetTable is the store table the committed offsets of consumer groups are kept in
+	offsetTable = "offsets"
+	// defaultPartitions is the number of partitions of a topic unless set with WithPartitions
+	defaultPartitions = 16
+)
+
 type subscriber struct {
 	Group   string
 	Topic   string
 	Channel chan Event
 
 	sync.RWMutex
-	retryMap   map[string]int
+	acks       map[string]chan bool
 	retryLimit int
 	autoAck    bool
 	ackWait    time.Duration
+	backoff    BackoffFunc
+	deadLetter string
+
+	// group tracks the committed offset when consuming with WithGroup
+	group *group
+
+	// exit is closed when the subscriber stops consuming
+	exit chan bool
+	once sync.Once
 }
 
-type mem struct {
+// group tracks the offset a consumer group has committed on a topic.
+// Events up to and including the committed offset have been acked.
+type group struct {
 	store store.Store
+	key   string
+
+	sync.Mutex
+	members   []*subscriber
+	committed uint64
+	delivered uint64
+	pending   map[uint64]bool
+
+	// partitions of the topic, each owned by one member
+	partitions []*partition
+}
+
+type mem struct {
+	store      store.Store
+	partitions int
 
 	subs []*subscriber
 	sync.RWMutex
+
+	// offsets of the last event published to each topic
+	offsetLock sync.Mutex
+	offsets    map[string]uint64
+
+	groupLock sync.Mutex
+	groups    map[string]*group
+}
+
+// key of the event at offset, padded so keys sort in offset order
+func eventKey(topic string, offset uint64) string {
+	return fmt.Sprintf("%v/%020d", topic, offset)
+}
+
+// offset returns the offset of the last event published to the topic, loading it from the store
+// the first time the topic is seen. The offsetLock must be held.
+func (m *mem) offset(topic string) (uint64, error) {
+	if o, ok := m.offsets[topic]; ok {
+		return o, nil
+	}
+
+	keys, err := m.store.List(store.ListPrefix(topic + "/"))
+	if err != nil {
+		return 0, err
+	}
+
+	var last uint64
+	for _, k := range keys {
+		// keys of other topics nested under this one don't parse
+		o, err := strconv.ParseUint(strings.TrimPrefix(k, topic+"/"), 10, 64)
+		if err != nil {
+			continue
+		}
+		if o > last {
+			last = o
+		}
+	}
+
+	m.offsets[topic] = last
+	return last, nil
+}
+
+// group returns the tracker of a consumer group, loading its committed offset from the store.
+// A group which has never committed starts from the latest event.
+func (m *mem) group(topic, name string) (*group, error) {
+	key := topic + "/" + name
+
+	m.groupLock.Lock()
+	defer m.groupLock.Unlock()
+
+	if g, ok := m.groups[key]; ok {
+		return g, nil
+	}
+
+	g := &group{
+		store:      m.store,
+		key:        key,
+		pending:    make(map[uint64]bool),
+		partitions: make([]*partition, m.partitions),
+	}
+	for i := range g.partitions {
+		g.partitions[i] = &partition{}
+	}
+
+	recs, err := m.store.Read(key, store.ReadFrom("", offsetTable))
+	switch {
+	case err == store.ErrNotFound:
+		m.offsetLock.Lock()
+		latest, err := m.offset(topic)
+		m.offsetLock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		if err := g.commit(latest); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case len(recs) > 0:
+		committed, err := strconv.ParseUint(string(recs[0].Value), 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid committed offset")
+		}
+		g.committed = committed
+	}
+	g.delivered = g.committed
+
+	m.groups[key] = g
+	return g, nil
+}
+
+// commit persists the offset of the group
+func (g *group) commit(offset uint64) error {
+	g.committed = offset
+	return g.store.Write(&store.Record{
+		Key:   g.key,
+		Value: []byte(strconv.FormatUint(offset, 10)),
+	}, store.WriteTo("", offsetTable))
+}
+
+// deliver records that the event at offset was sent to a member of the group
+func (g *group) deliver(offset uint64) {
+	g.Lock()
+	defer g.Unlock()
+	g.pending[offset] = true
+	if offset > g.delivered {
+		g.delivered = offset
+	}
+}
+
+// ack commits the offset up to which every delivered event has been acked
+func (g *group) ack(offset uint64) {
+	g.Lock()
+	defer g.Unlock()
+
+	delete(g.pending, offset)
+
+	next := g.delivered
+	for o := range g.pending {
+		if o-1 < next {
+			next = o - 1
+		}
+	}
+	if next <= g.committed {
+		return
+	}
+
+	if err := g.commit(next); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+		logger.Errorf("Error committing offset %d for %v: %v", next, g.key, err)
+	}
 }
 
 func (m *mem) Publish(topic string, msg interface{}, opts ...PublishOption) error {
@@ -69,11 +246,12 @@ func (m *mem) Publish(topic string, msg interface{}, opts ...PublishOption) erro
 
 	// construct the event
 	event := &Event{
-		ID:        uuid.New().String(),
-		Topic:     topic,
-		Timestamp: options.Timestamp,
-		Metadata:  options.Metadata,
-		Payload:   payload,
+		ID:           uuid.New().String(),
+		Topic:        topic,
+		Timestamp:    options.Timestamp,
+		Metadata:     options.Metadata,
+		Payload:      payload,
+		PartitionKey: options.PartitionKey,
 	}
 
 	// serialize the event to bytes
@@ -82,14 +260,31 @@ func (m *mem) Publish(topic string, msg interface{}, opts ...PublishOption) erro
 		return errors.Wrap(err, "Error encoding event")
 	}
 
-	// write to the store
-	key := fmt.Sprintf("%v/%v", event.Topic, event.ID)
-	if err := m.store.Write(&store.Record{Key: key, Value: bytes}); err != nil {
+	// write to the store at the next offset of the topic
+	m.offsetLock.Lock()
+	offset, err := m.offset(topic)
+	if err != nil {
+		m.offsetLock.Unlock()
+		return errors.Wrap(err, "Error reading topic offset")
+	}
+	offset++
+	if err := m.store.Write(&store.Record{Key: eventKey(topic, offset), Value: bytes}); err != nil {
+		m.offsetLock.Unlock()
 		return errors.Wrap(err, "Error writing event to store")
 	}
+	m.offsets[topic] = offset
 
-	// send to the subscribers async
-	go m.handleEvent(event)
+	// the subscribers registered by the time the event is written receive it live,
+	// any registered after will find it when looking up previous events
+	m.RLock()
+	subs := m.subs
+	m.RUnlock()
+
+	// hand the event to the subscribers before the next offset is published, so
+	// groups see events delivered in offset order and never commit past one which
+	// is yet to be. They are sent async so this doesn't block.
+	m.handleEvent(event, offset, subs)
+	m.offsetLock.Unlock()
 
 	return nil
 }
@@ -102,22 +297,39 @@ func (m *mem) Consume(topic string, opts ...ConsumeOption) (<-chan Event, error)
 
 	// parse the options
 	options := ConsumeOptions{
-		Group:   uuid.New().String(),
 		AutoAck: true,
 	}
 	for _, o := range opts {
 		o(&options)
 	}
-	// TODO RetryLimit
+
+	if options.Backoff == nil {
+		options.Backoff = DefaultBackoff
+	}
+
+	// only named groups commit offsets
+	var g *group
+	if len(options.Group) > 0 {
+		var err error
+		if g, err = m.group(topic, options.Group); err != nil {
+			return nil, errors.Wrap(err, "Error loading consumer group")
+		}
+	} else {
+		options.Group = uuid.New().String()
+	}
 
 	// setup the subscriber
 	sub := &subscriber{
 		Channel:    make(chan Event),
 		Topic:      topic,
 		Group:      options.Group,
-		retryMap:   map[string]int{},
+		acks:       map[string]chan bool{},
 		autoAck:    true,
 		retryLimit: options.GetRetryLimit(),
+		backoff:    options.Backoff,
+		deadLetter: options.DeadLetterTopic,
+		group:      g,
+		exit:       make(chan bool),
 	}
 
 	if !options.AutoAck {
@@ -128,23 +340,71 @@ func (m *mem) Consume(topic string, opts ...ConsumeOption) (<-chan Event, error)
 		sub.ackWait = options.AckWait
 	}
 
-	// register the subscriber
+	// register the subscriber while no events are being published, so every event after
+	// the latest offset is sent to it live and every event up to it can be replayed
+	m.offsetLock.Lock()
+	latest, err := m.offset(topic)
+	if err != nil {
+		m.offsetLock.Unlock()
+		return nil, errors.Wrap(err, "Error reading topic offset")
+	}
 	m.Lock()
 	m.subs = append(m.subs, sub)
 	m.Unlock()
+	m.offsetLock.Unlock()
+
+	// the first member of a group resumes from the committed offset
+	var resume bool
+	var committed uint64
+	if g != nil {
+		var first bool
+		first, committed = g.join(sub)
+		resume = first && committed < latest
+	}
+
+	if options.Context != nil {
+		go func() {
+			<-options.Context.Done()
+			m.unsubscribe(sub)
+		}()
+	}
 
 	// lookup previous events if the start time option was passed
 	if options.Offset.Unix() > 0 {
-		go m.lookupPreviousEvents(sub, options.Offset)
+		go m.lookupPreviousEvents(sub, func(ev *Event, offset uint64) bool {
+			return offset <= latest && ev.Timestamp.Unix() >= options.Offset.Unix()
+		})
+	} else if resume {
+		go m.lookupPreviousEvents(sub, func(ev *Event, offset uint64) bool {
+			return offset > committed && offset <= latest
+		})
 	}
 
 	// return the channel
 	return sub.Channel, nil
 }
 
-// lookupPreviousEvents finds events for a subscriber which occurred before a given time and sends
-// them into the subscribers channel
-func (m *mem) lookupPreviousEvents(sub *subscriber, startTime time.Time) {
+// unsubscribe stops sending events to the subscriber and leaves its group
+func (m *mem) unsubscribe(sub *subscriber) {
+	m.Lock()
+	for i, s := range m.subs {
+		if s == sub {
+			m.subs = append(m.subs[:i:i], m.subs[i+1:]...)
+			break
+		}
+	}
+	m.Unlock()
+
+	// leave the group before exiting so events being delivered move to another member
+	if sub.group != nil {
+		sub.group.leave(sub)
+	}
+	sub.once.Do(func() { close(sub.exit) })
+}
+
+// lookupPreviousEvents finds the events of a subscribers topic which match and sends
+// them into the subscribers channel in the order they were published
+func (m *mem) lookupPreviousEvents(sub *subscriber, match func(ev *Event, offset uint64) bool) {
 	// lookup all events which match the topic (a blank topic will return all results)
 	recs, err := m.store.Read(sub.Topic+"/", store.ReadPrefix())
 	if err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
@@ -154,25 +414,27 @@ func (m *mem) lookupPreviousEvents(sub *subscriber, startTime time.Time) {
 		return
 	}
 
+	sort.Slice(recs, func(i, j int) bool { return recs[i].Key < recs[j].Key })
+
 	// loop through the records and send it to the channel if it matches
 	for _, r := range recs {
+		offset, err := strconv.ParseUint(strings.TrimPrefix(r.Key, sub.Topic+"/"), 10, 64)
+		if err != nil {
+			continue
+		}
 		var ev Event
 		if err := json.Unmarshal(r.Value, &ev); err != nil {
 			continue
 		}
-		if ev.Timestamp.Unix() < startTime.Unix() {
+		if !match(&ev, offset) {
 			continue
 		}
-		sendEvent(&ev, sub)
+		m.sendEvent(&ev, offset, sub)
 	}
 }
 
 // handleEvents sends the event to any registered subscribers.
-func (m *mem) handleEvent(ev *Event) {
-	m.RLock()
-	subs := m.subs
-	m.RUnlock()
-
+func (m *mem) handleEvent(ev *Event, offset uint64, subs []*subscriber) {
 	// filteredSubs is a KV map of the queue name and subscribers. This is used to prevent a message
 	// being sent to two subscribers with the same queue.
 	filteredSubs := map[string]*subscriber{}
@@ -186,59 +448,218 @@ func (m *mem) handleEvent(ev *Event) {
 
 	// send the message to each channel async (since one channel might be blocked)
 	for _, sub := range filteredSubs {
-		sendEvent(ev, sub)
-	}
-}
-
-func sendEvent(ev *Event, sub *subscriber) {
-	go func(s *subscriber) {
-		evCopy := *ev
-		if s.autoAck {
-			s.Channel <- evCopy
-			return
-		}
-		evCopy.SetAckFunc(ackFunc(s, evCopy))
-		evCopy.SetNackFunc(nackFunc(s, evCopy))
-		s.retryMap[evCopy.ID] = 0
-		tick := time.NewTicker(s.ackWait)
-		defer tick.Stop()
-		for range tick.C {
-			s.Lock()
-			count, ok := s.retryMap[evCopy.ID]
-			s.Unlock()
-			if !ok {
-				// success
-				break
+		m.sendEvent(ev, offset, sub)
+	}
+}
+
+func (m *mem) sendEvent(ev *Event, offset uint64, sub *subscriber) {
+	g := sub.group
+	if g == nil {
+		go m.deliver(ev, sub)
+		return
+	}
+
+	g.deliver(offset)
+
+	// events with a partition key are sent in order by the owner of their partition
+	if len(ev.PartitionKey) > 0 {
+		g.enqueue(ev, offset, m.deliver)
+		return
+	}
+
+	go func() {
+		// move on to another member if the subscriber leaves before handling the event
+		for s := sub; s != nil; s = g.member(s) {
+			if m.deliver(ev, s) {
+				g.ack(offset)
+				return
+			}
+		}
+	}()
+}
+
+// deliver sends the event to the subscriber, waiting for it to be acked and redelivering it
+// as configured. It returns false if the subscriber stopped consuming before handling the event.
+func (m *mem) deliver(ev *Event, s *subscriber) bool {
+	evCopy := *ev
+	if s.autoAck {
+		select {
+		case s.Channel <- evCopy:
+			return true
+		case <-s.exit:
+			return false
+		}
+	}
+	evCopy.SetAckFunc(ackFunc(s, evCopy))
+	evCopy.SetNackFunc(nackFunc(s, evCopy))
+
+	// acks receives true when the event is acked and false when it is nacked
+	acks := make(chan bool, 1)
+	s.Lock()
+	s.acks[evCopy.ID] = acks
+	s.Unlock()
+
+	defer func() {
+		s.Lock()
+		delete(s.acks, evCopy.ID)
+		s.Unlock()
+	}()
+
+	for attempts := 1; ; attempts++ {
+		select {
+		case s.Channel <- evCopy:
+		case <-s.exit:
+			return false
+		}
+
+		var reason string
+		select {
+		case ok := <-acks:
+			if ok {
+				return true
 			}
+			reason = "nacked"
+		case <-time.After(s.ackWait):
+			reason = fmt.Sprintf("not acked within %v", s.ackWait)
+		case <-s.exit:
+			return false
+		}
 
-			if s.retryLimit > -1 && count > s.retryLimit {
+		if s.retryLimit > -1 && attempts > s.retryLimit {
+			if len(s.deadLetter) == 0 {
 				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
-					logger.Errorf("Message retry limit reached, discarding: %v %d %d", evCopy.ID, count, s.retryLimit)
+					logger.Errorf("Message retry limit reached, discarding: %v %d %d", evCopy.ID, attempts, s.retryLimit)
 				}
-				s.Lock()
-				delete(s.retryMap, evCopy.ID)
-				s.Unlock()
-				return
+				return true
+			}
+			if err := m.publishDeadLetter(&evCopy, s.deadLetter, attempts, reason); err != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Error publishing %v to dead letter topic %v: %v", evCopy.ID, s.deadLetter, err)
 			}
-			s.Channel <- evCopy
-			s.Lock()
-			s.retryMap[evCopy.ID] = count + 1
-			s.Unlock()
+			return true
+		}
+
+		// wait before redelivering unless the subscriber stops meanwhile
+		t := time.NewTimer(s.backoff(attempts))
+		select {
+		case <-t.C:
+		case <-s.exit:
+			t.Stop()
+			return false
+		}
+	}
+}
+
+// publishDeadLetter publishes an event which reached its retry limit to the dead letter topic,
+// keeping the original metadata and adding the reason it failed
+func (m *mem) publishDeadLetter(ev *Event, topic string, attempts int, reason string) error {
+	md := make(map[string]string, len(ev.Metadata)+4)
+	for k, v := range ev.Metadata {
+		md[k] = v
+	}
+	md[DeadLetterTopicKey] = ev.Topic
+	md[DeadLetterIDKey] = ev.ID
+	md[DeadLetterReasonKey] = reason
+	md[DeadLetterAttemptsKey] = strconv.Itoa(attempts)
+
+	return m.Publish(topic, ev.Payload, WithMetadata(md), WithTimestamp(ev.Timestamp))
+}
+
+// DeadLetters returns the events on a dead letter topic, oldest first unless ReadOrder is set
+func (m *mem) DeadLetters(topic string, opts ...ReadOption) ([]*Event, error) {
+	if len(topic) == 0 {
+		return nil, ErrMissingTopic
+	}
+
+	var options ReadOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	recs, err := m.store.Read(topic+"/", store.ReadPrefix())
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading from store")
+	}
+
+	var evs []*Event
+	for _, r := range recs {
+		if _, err := strconv.ParseUint(strings.TrimPrefix(r.Key, topic+"/"), 10, 64); err != nil {
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal(r.Value, &ev); err != nil {
+			continue
+		}
+		evs = append(evs, &ev)
+	}
+
+	return readEvents(evs, options)
+}
+
+// Replay publishes a dead-lettered event back to the topic it was consumed from and
+// removes it from the dead letter topic
+func (m *mem) Replay(topic, id string) error {
+	if len(topic) == 0 {
+		return ErrMissingTopic
+	}
+
+	recs, err := m.store.Read(topic+"/", store.ReadPrefix())
+	if err != nil {
+		return errors.Wrap(err, "Error reading from store")
+	}
+
+	for _, r := range recs {
+		var ev Event
+		if err := json.Unmarshal(r.Value, &ev); err != nil || ev.ID != id {
+			continue
+		}
+
+		original := ev.Metadata[DeadLetterTopicKey]
+		if len(original) == 0 {
+			return ErrNotDeadLetter
+		}
+
+		md := make(map[string]string, len(ev.Metadata))
+		for k, v := range ev.Metadata {
+			md[k] = v
 		}
-	}(sub)
+		delete(md, DeadLetterTopicKey)
+		delete(md, DeadLetterIDKey)
+		delete(md, DeadLetterReasonKey)
+		delete(md, DeadLetterAttemptsKey)
+
+		if err := m.Publish(original, ev.Payload, WithMetadata(md), WithTimestamp(ev.Timestamp)); err != nil {
+			return err
+		}
+		return m.store.Delete(r.Key)
+	}
+
+	return ErrEventNotFound
 }
 
 func ackFunc(s *subscriber, evCopy Event) func() error {
 	return func() error {
-		s.Lock()
-		delete(s.retryMap, evCopy.ID)
-		s.Unlock()
+		signal(s, evCopy.ID, true)
 		return nil
 	}
 }
 
 func nackFunc(s *subscriber, evCopy Event) func() error {
 	return func() error {
+		signal(s, evCopy.ID, false)
 		return nil
 	}
 }
+
+// signal an ack or nack to the event being delivered, if it still is
+func signal(s *subscriber, id string, ack bool) {
+	s.RLock()
+	acks, ok := s.acks[id]
+	s.RUnlock()
+	if !ok {
+		return
+	}
+	select {
+	case acks <- ack:
+	default:
+	}
+}
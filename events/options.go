@@ -1,11 +1,39 @@
 package events
 
-import "time"
+import (
+	"context"
+	"time"
 
-type Options struct{}
+	"go-micro.dev/v4/store"
+)
 
+// Options contains all the options which can be provided when creating a stream
+type Options struct {
+	// Store the events and consumer group offsets are persisted to. Defaults to a memory store,
+	// a durable store such as the file store allows groups to resume where they left off after a restart.
+	Store store.Store
+	// Partitions is the number of partitions the events of a topic are split into by their
+	// partition key, each partition is consumed by one member of a consumer group at a time
+	Partitions int
+}
+
+// Option sets attributes on Options
 type Option func(o *Options)
 
+// WithStore sets the store events are persisted to
+func WithStore(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// WithPartitions sets the number of partitions of each topic
+func WithPartitions(n int) Option {
+	return func(o *Options) {
+		o.Partitions = n
+	}
+}
+
 type StoreOptions struct {
 	TTL    time.Duration
 	Backup Backup
@@ -19,6 +47,9 @@ type PublishOptions struct {
 	Metadata map[string]string
 	// Timestamp to set for the event, if the timestamp is a zero value, the current time will be used
 	Timestamp time.Time
+	// PartitionKey orders events, those with the same key are consumed in the order they were
+	// published by a single member of a consumer group
+	PartitionKey string
 }
 
 // PublishOption sets attributes on PublishOptions
@@ -31,6 +62,13 @@ func WithMetadata(md map[string]string) PublishOption {
 	}
 }
 
+// WithPartitionKey sets the partition key of the event, for example an order id
+func WithPartitionKey(key string) PublishOption {
+	return func(o *PublishOptions) {
+		o.PartitionKey = key
+	}
+}
+
 // WithTimestamp sets the timestamp field on PublishOptions
 func WithTimestamp(t time.Time) PublishOption {
 	return func(o *PublishOptions) {
@@ -57,8 +95,20 @@ type ConsumeOptions struct {
 	RetryLimit int
 	// CustomRetries indicates whether to use RetryLimit
 	CustomRetries bool
+	// Backoff returns the delay before redelivering an event which was nacked or not acked in time.
+	// DefaultBackoff is used if nil.
+	Backoff BackoffFunc
+	// DeadLetterTopic is the topic events are published to once RetryLimit is reached,
+	// rather than being discarded
+	DeadLetterTopic string
+	// Context stops the consumer when it is done, the partitions it was assigned in its
+	// group are rebalanced to the remaining members
+	Context context.Context
 }
 
+// BackoffFunc returns the delay before the next delivery of an event after the given number of attempts
+type BackoffFunc func(attempts int) time.Duration
+
 // ConsumeOption sets attributes on ConsumeOptions
 type ConsumeOption func(o *ConsumeOptions)
 
@@ -94,6 +144,29 @@ func WithRetryLimit(retries int) ConsumeOption {
 	}
 }
 
+// WithContext stops consuming when the context is done
+func WithContext(ctx context.Context) ConsumeOption {
+	return func(o *ConsumeOptions) {
+		o.Context = ctx
+	}
+}
+
+// WithBackoff sets the delay between redeliveries of an event, for example
+// backoff.Do from util/backoff for exponential backoff
+func WithBackoff(fn BackoffFunc) ConsumeOption {
+	return func(o *ConsumeOptions) {
+		o.Backoff = fn
+	}
+}
+
+// WithDeadLetter sets the topic events are published to once the RetryLimit is reached.
+// The original topic, event ID, attempts and failure reason are added to the metadata.
+func WithDeadLetter(topic string) ConsumeOption {
+	return func(o *ConsumeOptions) {
+		o.DeadLetterTopic = topic
+	}
+}
+
 func (s ConsumeOptions) GetRetryLimit() int {
 	if !s.CustomRetries {
 		return -1
@@ -124,21 +197,105 @@ type ReadOptions struct {
 	Limit uint
 	// Offset the results by this number, useful for paginated queries
 	Offset uint
+	// Cursor continues reading after the page which returned it, in place of Offset
+	Cursor string
+	// Next is set to the cursor of the next page, or empty if there are no more events
+	Next *string
+	// Since only returns events with a timestamp at or after this time
+	Since time.Time
+	// Until only returns events with a timestamp before this time
+	Until time.Time
+	// Metadata only returns events whose metadata contains all of these key/value pairs
+	Metadata map[string]string
+	// Order the events are returned in, oldest first by default
+	Order Order
 }
 
+// Order is the order events are read in, by timestamp
+type Order int
+
+const (
+	// OrderOldest returns the oldest events first
+	OrderOldest Order = iota
+	// OrderNewest returns the newest events first
+	OrderNewest
+)
+
 // ReadOption sets attributes on ReadOptions
 type ReadOption func(o *ReadOptions)
 
 // ReadLimit sets the limit attribute on ReadOptions
 func ReadLimit(l uint) ReadOption {
 	return func(o *ReadOptions) {
-		o.Limit = 1
+		o.Limit = l
 	}
 }
 
 // ReadOffset sets the offset attribute on ReadOptions
 func ReadOffset(l uint) ReadOption {
 	return func(o *ReadOptions) {
-		o.Offset = 1
+		o.Offset = l
+	}
+}
+
+// ReadSince only returns events with a timestamp at or after t
+func ReadSince(t time.Time) ReadOption {
+	return func(o *ReadOptions) {
+		o.Since = t
+	}
+}
+
+// ReadUntil only returns events with a timestamp before t
+func ReadUntil(t time.Time) ReadOption {
+	return func(o *ReadOptions) {
+		o.Until = t
+	}
+}
+
+// ReadMetadata only returns events with the given metadata. It can be passed
+// multiple times, events have to match every key/value pair.
+func ReadMetadata(key, value string) ReadOption {
+	return func(o *ReadOptions) {
+		if o.Metadata == nil {
+			o.Metadata = make(map[string]string)
+		}
+		o.Metadata[key] = value
+	}
+}
+
+// ReadOrder sets the order events are returned in
+func ReadOrder(order Order) ReadOption {
+	return func(o *ReadOptions) {
+		o.Order = order
+	}
+}
+
+// Match reports whether the event passes the time window and metadata filters
+func (o ReadOptions) Match(ev *Event) bool {
+	if !o.Since.IsZero() && ev.Timestamp.Before(o.Since) {
+		return false
+	}
+	if !o.Until.IsZero() && !ev.Timestamp.Before(o.Until) {
+		return false
+	}
+	for k, v := range o.Metadata {
+		if mv, ok := ev.Metadata[k]; !ok || mv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadCursor continues reading after the page which returned the cursor
+func ReadCursor(c string) ReadOption {
+	return func(o *ReadOptions) {
+		o.Cursor = c
+	}
+}
+
+// ReadNextCursor sets next to the cursor of the next page, or empty if there are no more events
+func ReadNextCursor(next *string) ReadOption {
+	return func(o *ReadOptions) {
+		o.Next = next
 	}
 }
@@ -0,0 +1,186 @@
+package events
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// partition of a topic within a consumer group. Its events are queued and sent to
+// the owner one at a time, so events with the same partition key stay in order.
+type partition struct {
+	// owner is the member the partition is assigned to, guarded by the group lock
+	owner *subscriber
+
+	sync.Mutex
+	queue   []queued
+	running bool
+}
+
+type queued struct {
+	ev     *Event
+	offset uint64
+}
+
+// partitionOf returns the partition a key belongs to
+func partitionOf(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// join adds a member to the group and rebalances the partitions. It returns whether
+// the member is the only one in the group and the offset the group committed.
+func (g *group) join(sub *subscriber) (bool, uint64) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.members = append(g.members, sub)
+	g.rebalance()
+
+	return len(g.members) == 1, g.committed
+}
+
+// leave removes a member from the group and rebalances its partitions to the remaining members
+func (g *group) leave(sub *subscriber) {
+	g.Lock()
+	defer g.Unlock()
+
+	for i, s := range g.members {
+		if s == sub {
+			g.members = append(g.members[:i:i], g.members[i+1:]...)
+			break
+		}
+	}
+
+	// events which weren't acked are replayed from the committed offset when a member next joins
+	if len(g.members) == 0 {
+		g.pending = make(map[uint64]bool)
+		g.delivered = g.committed
+	}
+
+	g.rebalance()
+}
+
+// member returns sub if it is still in the group, otherwise any other member
+func (g *group) member(sub *subscriber) *subscriber {
+	g.Lock()
+	defer g.Unlock()
+
+	for _, s := range g.members {
+		if s == sub {
+			return s
+		}
+	}
+	if len(g.members) > 0 {
+		return g.members[0]
+	}
+	return nil
+}
+
+// owner returns the member a partition is assigned to
+func (g *group) owner(p *partition) *subscriber {
+	g.Lock()
+	defer g.Unlock()
+	return p.owner
+}
+
+// rebalance assigns every partition to a member, spreading them evenly while keeping
+// as many partitions as possible with their current owner. The group lock must be held.
+func (g *group) rebalance() {
+	if len(g.members) == 0 {
+		for _, p := range g.partitions {
+			p.owner = nil
+		}
+		return
+	}
+
+	counts := make(map[*subscriber]int, len(g.members))
+	for _, s := range g.members {
+		counts[s] = 0
+	}
+
+	// partitions of members which left are free to be assigned
+	var free []*partition
+	for _, p := range g.partitions {
+		if _, ok := counts[p.owner]; ok {
+			counts[p.owner]++
+			continue
+		}
+		p.owner = nil
+		free = append(free, p)
+	}
+
+	// members holding the most partitions keep the remainder of an uneven split
+	members := append([]*subscriber(nil), g.members...)
+	sort.SliceStable(members, func(i, j int) bool {
+		return counts[members[i]] > counts[members[j]]
+	})
+
+	quota, extra := len(g.partitions)/len(members), len(g.partitions)%len(members)
+	capacity := make(map[*subscriber]int, len(members))
+	for i, s := range members {
+		capacity[s] = quota
+		if i < extra {
+			capacity[s]++
+		}
+	}
+
+	// members over capacity give up partitions for new members
+	for _, p := range g.partitions {
+		if p.owner != nil && counts[p.owner] > capacity[p.owner] {
+			counts[p.owner]--
+			p.owner = nil
+			free = append(free, p)
+		}
+	}
+
+	for _, p := range free {
+		for _, s := range members {
+			if counts[s] < capacity[s] {
+				p.owner = s
+				counts[s]++
+				break
+			}
+		}
+	}
+}
+
+// enqueue queues the event on its partition, starting to send the partition's events
+// to its owner if they aren't being sent already
+func (g *group) enqueue(ev *Event, offset uint64, deliver func(*Event, *subscriber) bool) {
+	p := g.partitions[partitionOf(ev.PartitionKey, len(g.partitions))]
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.queue = append(p.queue, queued{ev: ev, offset: offset})
+	if !p.running {
+		p.running = true
+		go g.drain(p, deliver)
+	}
+}
+
+// drain sends the queued events of the partition in order until the queue is empty
+func (g *group) drain(p *partition, deliver func(*Event, *subscriber) bool) {
+	for {
+		p.Lock()
+		if len(p.queue) == 0 {
+			p.running = false
+			p.Unlock()
+			return
+		}
+		q := p.queue[0]
+		p.queue = p.queue[1:]
+		p.Unlock()
+
+		// the partition moves to another member if its owner leaves before handling the event,
+		// without any members it is replayed when one joins
+		for s := g.owner(p); s != nil; s = g.owner(p) {
+			if deliver(q.ev, s) {
+				g.ack(q.offset)
+				break
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package events
 
 import (
 	"encoding/json"
+	"sort"
 	"time"
 
 	"github.com/pkg/errors"
@@ -11,6 +12,13 @@ import (
 
 const joinKey = "/"
 
+// timeIndex orders the records of a topic by the timestamp of their events,
+// its values are the topic and the timestamp formatted as cursorTime
+const timeIndex = "time"
+
+// readPage is the number of records read from the store at a time
+const readPage = 100
+
 // NewStore returns an initialized events store
 func NewStore(opts ...StoreOption) Store {
 	// parse the options
@@ -25,7 +33,7 @@ func NewStore(opts ...StoreOption) Store {
 	// return the store
 	evs := &evStore{
 		opts:  options,
-		store: store.NewMemoryStore(),
+		store: store.NewMemoryStore(store.Indexes(store.Index{Name: timeIndex, Metadata: timeIndex})),
 	}
 	if options.Backup != nil {
 		go evs.backupLoop()
@@ -54,24 +62,140 @@ func (s *evStore) Read(topic string, opts ...ReadOption) ([]*Event, error) {
 		o(&options)
 	}
 
-	// execute the request
-	recs, err := s.store.Read(topic+joinKey,
-		store.ReadPrefix(),
-		store.ReadLimit(options.Limit),
-		store.ReadOffset(options.Offset),
-	)
-	if err != nil {
-		return nil, errors.Wrap(err, "Error reading from store")
+	order := store.OrderAsc
+	if options.Order == OrderNewest {
+		order = store.OrderDesc
+	}
+
+	// the cursor is the store cursor of the time index after the last event returned
+	cursor := options.Cursor
+	offset := options.Offset
+	if len(cursor) > 0 {
+		offset = 0
 	}
 
-	// unmarshal the result
-	result := make([]*Event, len(recs))
-	for i, r := range recs {
-		var e Event
-		if err := json.Unmarshal(r.Value, &e); err != nil {
-			return nil, errors.Wrap(err, "Invalid event returned from stroe")
+	var result []*Event
+	var last *store.Record
+	var next string
+
+	// page through the time index, filtering the events until the limit is reached
+	// and one more event matches, which tells us there is a next page
+read:
+	for {
+		var more string
+		recs, err := s.store.Read(topic+joinKey,
+			store.ReadIndex(timeIndex),
+			store.ReadPrefix(),
+			store.ReadOrder(order),
+			store.ReadLimit(readPage),
+			store.ReadCursor(cursor),
+			store.ReadNextCursor(&more),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error reading from store")
+		}
+
+		for _, r := range recs {
+			var e Event
+			if err := json.Unmarshal(r.Value, &e); err != nil {
+				return nil, errors.Wrap(err, "Invalid event returned from stroe")
+			}
+			if !options.Match(&e) {
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			if options.Limit > 0 && uint(len(result)) == options.Limit {
+				next = (&store.Cursor{Key: last.Key, Value: last.Metadata[timeIndex]}).String()
+				break read
+			}
+			result = append(result, &e)
+			last = r
 		}
-		result[i] = &e
+
+		if len(more) == 0 {
+			break
+		}
+		cursor = more
+	}
+
+	if options.Next != nil {
+		*options.Next = next
+	}
+
+	return result, nil
+}
+
+// cursorTime formats timestamps in cursors so they compare in time order
+const cursorTime = "2006-01-02T15:04:05.000000000Z"
+
+// readEvents filters, orders and pages the events as set in the read options
+func readEvents(evs []*Event, options ReadOptions) ([]*Event, error) {
+	order := store.OrderAsc
+	if options.Order == OrderNewest {
+		order = store.OrderDesc
+	}
+
+	var after *store.Cursor
+	if len(options.Cursor) > 0 {
+		c, err := store.ParseCursor(options.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+
+	matched := make([]*Event, 0, len(evs))
+	for _, ev := range evs {
+		if options.Match(ev) {
+			matched = append(matched, ev)
+		}
+	}
+
+	// events with the same timestamp are ordered by ID
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.Timestamp.Equal(b.Timestamp) {
+			if order == store.OrderDesc {
+				return a.ID > b.ID
+			}
+			return a.ID < b.ID
+		}
+		if order == store.OrderDesc {
+			return a.Timestamp.After(b.Timestamp)
+		}
+		return a.Timestamp.Before(b.Timestamp)
+	})
+
+	offset := options.Offset
+	if after != nil {
+		offset = 0
+	}
+
+	var result []*Event
+	var next string
+	for i, ev := range matched {
+		ts := ev.Timestamp.UTC().Format(cursorTime)
+		if after != nil && after.Seen(ts, ev.ID, order) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		result = append(result, ev)
+		if options.Limit > 0 && uint(len(result)) == options.Limit {
+			if i < len(matched)-1 {
+				next = (&store.Cursor{Key: ev.ID, Value: ts}).String()
+			}
+			break
+		}
+	}
+
+	if options.Next != nil {
+		*options.Next = next
 	}
 
 	return result, nil
@@ -97,8 +221,11 @@ func (s *evStore) Write(event *Event, opts ...WriteOption) error {
 
 	record := &store.Record{
 		// key is such that reading by prefix indexes by topic and reading by suffix indexes by time
-		Key:    event.Topic + joinKey + event.ID + joinKey + timeSuffix,
-		Value:  bytes,
+		Key:   event.Topic + joinKey + event.ID + joinKey + timeSuffix,
+		Value: bytes,
+		Metadata: map[string]interface{}{
+			timeIndex: event.Topic + joinKey + event.Timestamp.UTC().Format(cursorTime),
+		},
 		Expiry: options.TTL,
 	}
 
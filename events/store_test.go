@@ -2,6 +2,7 @@ package events
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -44,4 +45,139 @@ func TestStore(t *testing.T) {
 		assert.Nilf(t, err, "No error should be returned")
 		assert.Len(t, evs, 1, "The result should include no more than the read limit")
 	})
+
+	// cursors should page through every event once
+	t.Run("ReadTopicCursor", func(t *testing.T) {
+		var next string
+		first, err := store.Read("foo", ReadLimit(1), ReadNextCursor(&next))
+		assert.Nilf(t, err, "No error should be returned")
+		assert.Len(t, first, 1, "The first page should include one event")
+		assert.NotEmpty(t, next, "A cursor should be returned for the next page")
+
+		second, err := store.Read("foo", ReadLimit(1), ReadCursor(next), ReadNextCursor(&next))
+		assert.Nilf(t, err, "No error should be returned")
+		assert.Len(t, second, 1, "The second page should include one event")
+		assert.NotEqual(t, first[0].ID, second[0].ID, "Pages should not overlap")
+		assert.Empty(t, next, "No cursor should be returned after the last page")
+	})
+}
+
+func TestStoreRead(t *testing.T) {
+	store := NewStore()
+
+	start := time.Now().Truncate(time.Second)
+	at := func(i int) time.Time { return start.Add(time.Duration(i) * time.Minute) }
+
+	// events are written out of time order so results can't rely on write order
+	testData := []*Event{
+		{ID: "c", Topic: "orders", Timestamp: at(2), Metadata: map[string]string{"region": "eu", "status": "paid"}},
+		{ID: "a", Topic: "orders", Timestamp: at(0), Metadata: map[string]string{"region": "us", "status": "paid"}},
+		{ID: "e", Topic: "orders", Timestamp: at(4), Metadata: map[string]string{"region": "us"}},
+		{ID: "b", Topic: "orders", Timestamp: at(1), Metadata: map[string]string{"region": "eu"}},
+		{ID: "d", Topic: "orders", Timestamp: at(3), Metadata: map[string]string{"region": "eu", "status": "paid"}},
+		{ID: "x", Topic: "other", Timestamp: at(0)},
+	}
+	for _, ev := range testData {
+		assert.NoError(t, store.Write(ev), "Writing an event should not return an error")
+	}
+
+	tcs := []struct {
+		name string
+		opts []ReadOption
+		ids  []string
+	}{
+		{name: "Default", ids: []string{"a", "b", "c", "d", "e"}},
+		{name: "Newest", opts: []ReadOption{ReadOrder(OrderNewest)}, ids: []string{"e", "d", "c", "b", "a"}},
+		{name: "Limit", opts: []ReadOption{ReadLimit(2)}, ids: []string{"a", "b"}},
+		{name: "Offset", opts: []ReadOption{ReadOffset(3)}, ids: []string{"d", "e"}},
+		{name: "LimitOffset", opts: []ReadOption{ReadLimit(2), ReadOffset(1)}, ids: []string{"b", "c"}},
+		{name: "NewestLimit", opts: []ReadOption{ReadOrder(OrderNewest), ReadLimit(2)}, ids: []string{"e", "d"}},
+		{name: "Since", opts: []ReadOption{ReadSince(at(3))}, ids: []string{"d", "e"}},
+		{name: "Until", opts: []ReadOption{ReadUntil(at(2))}, ids: []string{"a", "b"}},
+		{name: "Window", opts: []ReadOption{ReadSince(at(1)), ReadUntil(at(4))}, ids: []string{"b", "c", "d"}},
+		{name: "Metadata", opts: []ReadOption{ReadMetadata("region", "eu")}, ids: []string{"b", "c", "d"}},
+		{name: "MetadataAll", opts: []ReadOption{ReadMetadata("region", "eu"), ReadMetadata("status", "paid")}, ids: []string{"c", "d"}},
+		{name: "MetadataNone", opts: []ReadOption{ReadMetadata("region", "ap")}, ids: nil},
+		{name: "Combined", opts: []ReadOption{ReadMetadata("status", "paid"), ReadSince(at(1)), ReadOrder(OrderNewest), ReadLimit(1)}, ids: []string{"d"}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			evs, err := store.Read("orders", tc.opts...)
+			assert.NoError(t, err, "No error should be returned")
+
+			var ids []string
+			for _, ev := range evs {
+				ids = append(ids, ev.ID)
+			}
+			assert.Equal(t, tc.ids, ids, "Unexpected events returned")
+		})
+	}
+
+	// cursors should follow the order and filters of the read
+	t.Run("CursorNewest", func(t *testing.T) {
+		var ids []string
+		var next string
+		opts := []ReadOption{ReadOrder(OrderNewest), ReadMetadata("region", "eu"), ReadLimit(2), ReadNextCursor(&next)}
+
+		evs, err := store.Read("orders", opts...)
+		assert.NoError(t, err, "No error should be returned")
+		for next != "" {
+			for _, ev := range evs {
+				ids = append(ids, ev.ID)
+			}
+			evs, err = store.Read("orders", append(opts, ReadCursor(next))...)
+			assert.NoError(t, err, "No error should be returned")
+		}
+		for _, ev := range evs {
+			ids = append(ids, ev.ID)
+		}
+		assert.Equal(t, []string{"d", "c", "b"}, ids, "Pages should cover every matching event once")
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		_, err := store.Read("orders", ReadCursor("not a cursor"))
+		assert.Error(t, err, "An invalid cursor should return an error")
+	})
+}
+
+func TestStoreReadPages(t *testing.T) {
+	store := NewStore()
+
+	// more events than are read from the store at a time, half of them filtered out
+	start := time.Now().Truncate(time.Second)
+	n := readPage*2 + 10
+	var want []string
+	for i := 0; i < n; i++ {
+		region := "eu"
+		if i%2 == 1 {
+			region = "us"
+		}
+		ev := &Event{
+			ID:        uuid.New().String(),
+			Topic:     "orders",
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Metadata:  map[string]string{"region": region},
+		}
+		assert.NoError(t, store.Write(ev), "Writing an event should not return an error")
+		if region == "eu" {
+			want = append(want, ev.ID)
+		}
+	}
+
+	var ids []string
+	var cursor string
+	for pages := 0; pages <= len(want)/30; pages++ {
+		var next string
+		evs, err := store.Read("orders", ReadMetadata("region", "eu"), ReadLimit(30), ReadCursor(cursor), ReadNextCursor(&next))
+		assert.NoError(t, err, "No error should be returned")
+		for _, ev := range evs {
+			ids = append(ids, ev.ID)
+		}
+		if len(next) == 0 {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, want, ids, "Pages should cover every matching event once in order")
 }
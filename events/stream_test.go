@@ -1,12 +1,17 @@
 package events
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"go-micro.dev/v4/store"
 )
 
 type testPayload struct {
@@ -34,6 +39,176 @@ func TestStream(t *testing.T) {
 
 }
 
+func TestStreamResume(t *testing.T) {
+	st := store.NewMemoryStore()
+
+	stream, err := NewStream(WithStore(st))
+	assert.Nilf(t, err, "NewStream should not return an error")
+
+	ch, err := stream.Consume("resume", WithGroup("workers"))
+	assert.NoError(t, err, "Unexpected error subscribing")
+	assert.NoError(t, stream.Publish("resume", testPayload{Message: "message 1"}))
+
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for message")
+	}
+
+	// wait for the offset to be committed
+	time.Sleep(time.Millisecond * 50)
+
+	// restart the stream on the same store and publish while the group is away
+	stream, err = NewStream(WithStore(st))
+	assert.Nilf(t, err, "NewStream should not return an error")
+	assert.NoError(t, stream.Publish("resume", testPayload{Message: "message 2"}))
+	assert.NoError(t, stream.Publish("resume", testPayload{Message: "message 3"}))
+
+	ch, err = stream.Consume("resume", WithGroup("workers"))
+	assert.NoError(t, err, "Unexpected error subscribing")
+
+	received := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case ev := <-ch:
+			var p testPayload
+			assert.NoError(t, ev.Unmarshal(&p))
+			received[p.Message] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for missed messages")
+		}
+	}
+	assert.Equal(t, map[string]bool{"message 2": true, "message 3": true}, received, "The group should resume after its committed offset")
+
+	select {
+	case ev := <-ch:
+		t.Fatalf("Unexpected event received %v", string(ev.Payload))
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestStreamPartitions(t *testing.T) {
+	stream, err := NewStream(WithPartitions(8))
+	assert.Nilf(t, err, "NewStream should not return an error")
+
+	type delivery struct {
+		consumer int
+		key      string
+		seq      int
+	}
+	received := make(chan delivery, 100)
+
+	cancels := make([]context.CancelFunc, 2)
+	for i := range cancels {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels[i] = cancel
+		ch, err := stream.Consume("partitioned", WithGroup("orders"), WithContext(ctx))
+		assert.NoError(t, err, "Unexpected error subscribing")
+		go func(consumer int, ch <-chan Event) {
+			for ev := range ch {
+				var p testPayload
+				assert.NoError(t, ev.Unmarshal(&p))
+				seq, _ := strconv.Atoi(p.Message)
+				received <- delivery{consumer: consumer, key: ev.PartitionKey, seq: seq}
+			}
+		}(i, ch)
+	}
+	defer cancels[1]()
+
+	publish := func(from, to int) {
+		for seq := from; seq < to; seq++ {
+			for k := 0; k < 10; k++ {
+				err := stream.Publish("partitioned", testPayload{Message: strconv.Itoa(seq)}, WithPartitionKey(fmt.Sprintf("key-%d", k)))
+				assert.NoError(t, err, "Unexpected error publishing")
+			}
+		}
+	}
+	receive := func(n int) []delivery {
+		var ds []delivery
+		for i := 0; i < n; i++ {
+			select {
+			case d := <-received:
+				ds = append(ds, d)
+			case <-time.After(time.Second):
+				t.Fatalf("Timed out waiting for events, received %d of %d", i, n)
+			}
+		}
+		return ds
+	}
+
+	// events with the same key go to one member, in the order they were published
+	publish(0, 5)
+	owners := map[string]int{}
+	last := map[string]int{}
+	for _, d := range receive(50) {
+		if o, ok := owners[d.key]; ok {
+			assert.Equal(t, o, d.consumer, "Events with the same key should go to the same member")
+			assert.Greater(t, d.seq, last[d.key], "Events with the same key should be received in order")
+		}
+		owners[d.key] = d.consumer
+		last[d.key] = d.seq
+	}
+	consumers := map[int]bool{}
+	for _, o := range owners {
+		consumers[o] = true
+	}
+	assert.Len(t, consumers, 2, "Keys should be spread over both members")
+
+	// the partitions of a member which leaves move to the remaining member
+	cancels[0]()
+	time.Sleep(time.Millisecond * 50)
+	publish(5, 6)
+	for _, d := range receive(10) {
+		assert.Equal(t, 1, d.consumer, "Events should go to the remaining member")
+		assert.Equal(t, 5, d.seq)
+	}
+}
+
+func TestGroupRebalance(t *testing.T) {
+	g := &group{partitions: make([]*partition, 10)}
+	for i := range g.partitions {
+		g.partitions[i] = &partition{}
+	}
+	owned := func() map[*subscriber][]int {
+		o := map[*subscriber][]int{}
+		for i, p := range g.partitions {
+			o[p.owner] = append(o[p.owner], i)
+		}
+		return o
+	}
+
+	a, b, c := &subscriber{}, &subscriber{}, &subscriber{}
+	g.join(a)
+	assert.Len(t, owned()[a], 10, "A single member should own every partition")
+
+	g.join(b)
+	assert.Len(t, owned()[a], 5)
+	assert.Len(t, owned()[b], 5)
+	before := owned()
+
+	g.join(c)
+	after := owned()
+	assert.Len(t, after[nil], 0, "Every partition should be assigned")
+	for _, s := range []*subscriber{a, b, c} {
+		assert.GreaterOrEqual(t, len(after[s]), 3)
+		assert.LessOrEqual(t, len(after[s]), 4)
+	}
+	// existing members only give up partitions, they don't swap them
+	assert.Subset(t, before[a], after[a], "Partitions should stick to their owner")
+	assert.Subset(t, before[b], after[b], "Partitions should stick to their owner")
+
+	g.leave(a)
+	final := owned()
+	assert.Len(t, final[a], 0, "A member which left should own no partitions")
+	assert.Len(t, final[b], 5)
+	assert.Len(t, final[c], 5)
+	assert.Subset(t, final[c], after[c], "Partitions should stick to their owner")
+
+	g.leave(b)
+	g.leave(c)
+	assert.Len(t, owned()[nil], 10, "Without members no partition should be assigned")
+}
+
 func runTestStream(t *testing.T, stream Stream) {
 	// TestMissingTopic will test the topic validation on publish
 	t.Run("TestMissingTopic", func(t *testing.T) {
@@ -189,6 +364,51 @@ func runTestStream(t *testing.T, stream Stream) {
 
 	})
 
+	t.Run("DeadLetter", func(t *testing.T) {
+		ch, err := stream.Consume("foobarDead", WithAutoAck(false, 5*time.Second), WithRetryLimit(1),
+			WithBackoff(func(int) time.Duration { return 10 * time.Millisecond }), WithDeadLetter("foobarDead.dlq"))
+		assert.NoError(t, err, "Unexpected error subscribing")
+		assert.NoError(t, stream.Publish("foobarDead", map[string]string{"foo": "message 1"}))
+
+		ev := <-ch
+		id := ev.ID
+		ev.Nack()
+		ev = <-ch
+		assert.Equal(t, id, ev.ID, "Nacked message should have been received again")
+		ev.Nack()
+
+		dlq, ok := stream.(DeadLetterQueue)
+		assert.True(t, ok, "Stream should support dead letters")
+
+		var dead []*Event
+		for i := 0; i < 50 && len(dead) == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+			dead, err = dlq.DeadLetters("foobarDead.dlq")
+			assert.NoError(t, err, "Unexpected error listing dead letters")
+		}
+		if assert.Len(t, dead, 1, "Event should have been dead-lettered") {
+			assert.Equal(t, "foobarDead", dead[0].Metadata[DeadLetterTopicKey])
+			assert.Equal(t, id, dead[0].Metadata[DeadLetterIDKey])
+			assert.Equal(t, "2", dead[0].Metadata[DeadLetterAttemptsKey])
+			assert.Equal(t, "nacked", dead[0].Metadata[DeadLetterReasonKey])
+		}
+
+		assert.Equal(t, ErrEventNotFound, dlq.Replay("foobarDead.dlq", "missing"))
+		assert.NoError(t, dlq.Replay("foobarDead.dlq", dead[0].ID), "Unexpected error replaying")
+		select {
+		case ev = <-ch:
+			assert.NotEqual(t, id, ev.ID, "Replayed event should be published as a new event")
+			assert.Empty(t, ev.Metadata[DeadLetterTopicKey], "Dead letter metadata should be removed")
+			assert.NoError(t, ev.Ack())
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for replayed event")
+		}
+
+		dead, err = dlq.DeadLetters("foobarDead.dlq")
+		assert.NoError(t, err, "Unexpected error listing dead letters")
+		assert.Empty(t, dead, "Replayed event should be removed from the dead letter topic")
+	})
+
 	t.Run("InfiniteRetries", func(t *testing.T) {
 		ch, err := stream.Consume("foobarRetriesInf", WithAutoAck(false, 2*time.Second))
 		assert.NoError(t, err, "Unexpected error subscribing")
@@ -239,3 +459,112 @@ func runTestStream(t *testing.T, stream Stream) {
 		wg.Wait()
 	})
 }
+
+// commitStore checks that a group never commits an offset before every event up
+// to it was received
+type commitStore struct {
+	store.Store
+	t     *testing.T
+	topic string
+
+	sync.Mutex
+	received map[string]bool
+}
+
+func (c *commitStore) receive(id string) {
+	c.Lock()
+	c.received[id] = true
+	c.Unlock()
+}
+
+func (c *commitStore) Write(r *store.Record, opts ...store.WriteOption) error {
+	var options store.WriteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Table == offsetTable {
+		committed, _ := strconv.ParseUint(string(r.Value), 10, 64)
+		recs, _ := c.Store.Read(c.topic+"/", store.ReadPrefix())
+
+		c.Lock()
+		for _, rec := range recs {
+			offset, err := strconv.ParseUint(rec.Key[len(c.topic)+1:], 10, 64)
+			if err != nil || offset > committed {
+				continue
+			}
+			var ev Event
+			if err := json.Unmarshal(rec.Value, &ev); err == nil && !c.received[ev.ID] {
+				c.t.Errorf("Offset %d committed before event %d was received", committed, offset)
+			}
+		}
+		c.Unlock()
+	}
+	return c.Store.Write(r, opts...)
+}
+
+func TestGroupCommitOrder(t *testing.T) {
+	st := &commitStore{
+		Store:    store.NewMemoryStore(),
+		t:        t,
+		topic:    "ordered",
+		received: make(map[string]bool),
+	}
+	stream, err := NewStream(WithStore(st))
+	assert.NoError(t, err)
+
+	ch, err := stream.Consume("ordered", WithGroup("workers"), WithAutoAck(false, time.Second))
+	assert.NoError(t, err, "Unexpected error subscribing")
+
+	const publishers, events = 32, 50
+	go func() {
+		for i := 0; i < publishers; i++ {
+			go func() {
+				for j := 0; j < events; j++ {
+					stream.Publish("ordered", testPayload{Message: "message"})
+				}
+			}()
+		}
+	}()
+
+	for i := 0; i < publishers*events; i++ {
+		select {
+		case ev := <-ch:
+			st.receive(ev.ID)
+			ev.Ack()
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Timed out after %d events", i)
+		}
+	}
+}
+
+func TestBackoffStopsWithConsumer(t *testing.T) {
+	stream, err := NewStream()
+	assert.NoError(t, err)
+
+	// the event goes to the last member to join, which waits an hour to retry it
+	other, err := stream.Consume("backoff", WithGroup("workers"), WithAutoAck(false, time.Second))
+	assert.NoError(t, err, "Unexpected error subscribing")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := stream.Consume("backoff", WithGroup("workers"), WithAutoAck(false, time.Second),
+		WithBackoff(func(int) time.Duration { return time.Hour }), WithContext(ctx))
+	assert.NoError(t, err, "Unexpected error subscribing")
+
+	assert.NoError(t, stream.Publish("backoff", testPayload{Message: "message"}))
+	select {
+	case ev := <-ch:
+		assert.NoError(t, ev.Nack())
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for message")
+	}
+
+	// stopping the consumer while it backs off hands the event to the other member
+	time.Sleep(time.Millisecond * 50)
+	cancel()
+	select {
+	case ev := <-other:
+		assert.NoError(t, ev.Ack())
+	case <-time.After(time.Second):
+		t.Fatalf("Event wasn't redelivered once the consumer stopped")
+	}
+}
@@ -257,6 +257,14 @@ func RegisterInterval(t time.Duration) Option {
 	}
 }
 
+// RegisterHealthCheck sets the check the registry runs against the service node,
+// such as a registry.CheckRPC check calling its debug handler
+func RegisterHealthCheck(c *registry.Check) Option {
+	return func(o *Options) {
+		o.Server.Init(server.RegisterHealthCheck(c))
+	}
+}
+
 // WrapClient is a convenience method for wrapping a Client with
 // some middleware component. A list of wrappers can be provided.
 // Wrappers are applied in reverse order so the last is executed first.
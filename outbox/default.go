@@ -0,0 +1,272 @@
+package outbox
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/events"
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/store"
+	"go-micro.dev/v4/util/backoff"
+)
+
+type outbox struct {
+	sync.RWMutex
+	opts Options
+
+	// relayLock stops relays overlapping, which would publish messages twice
+	relayLock sync.Mutex
+
+	running bool
+	exit    chan bool
+	wg      sync.WaitGroup
+}
+
+func newOutbox(opts ...Option) *outbox {
+	o := &outbox{}
+	o.opts = o.options(opts...)
+	return o
+}
+
+func (o *outbox) options(opts ...Option) Options {
+	options := o.opts
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Store == nil {
+		options.Store = store.DefaultStore
+	}
+	if len(options.Prefix) == 0 {
+		options.Prefix = "outbox/"
+	}
+	if options.Interval == 0 {
+		options.Interval = time.Second
+	}
+	if options.BatchSize == 0 {
+		options.BatchSize = 100
+	}
+	if options.Backoff == nil {
+		options.Backoff = backoff.Do
+	}
+	return options
+}
+
+func (o *outbox) Init(opts ...Option) error {
+	o.Lock()
+	defer o.Unlock()
+	o.opts = o.options(opts...)
+	return nil
+}
+
+func (o *outbox) Options() Options {
+	o.RLock()
+	defer o.RUnlock()
+	return o.opts
+}
+
+func (o *outbox) Write(ops []store.Op, msgs ...*Message) error {
+	opts := o.Options()
+
+	batch := make([]store.Op, 0, len(ops)+len(msgs))
+	batch = append(batch, ops...)
+	for _, m := range msgs {
+		op, err := m.Op(opts.Prefix)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, op)
+	}
+
+	return opts.Store.Batch(batch, store.BatchTo(opts.Database, opts.Table))
+}
+
+func (o *outbox) Relay() error {
+	opts := o.Options()
+	if opts.Broker == nil && opts.Stream == nil {
+		return ErrNoPublisher
+	}
+
+	o.relayLock.Lock()
+	defer o.relayLock.Unlock()
+
+	// page through the messages with a cursor, stores may apply a plain limit
+	// before the prefix and the table is shared with other records
+	var cursor string
+	for {
+		var next string
+		recs, err := opts.Store.Read(opts.Prefix, store.ReadPrefix(), store.ReadLimit(opts.BatchSize),
+			store.ReadCursor(cursor), store.ReadNextCursor(&next), store.ReadFrom(opts.Database, opts.Table))
+		if err != nil {
+			return err
+		}
+		sort.Slice(recs, func(i, j int) bool { return recs[i].Key < recs[j].Key })
+
+		for _, r := range recs {
+			m := &Message{}
+			if err := json.Unmarshal(r.Value, m); err != nil {
+				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("[outbox] dropping invalid message %v: %v", r.Key, err)
+				}
+			} else if err := o.publish(opts, m); err != nil {
+				// stop so later messages aren't published before this one
+				return err
+			}
+
+			// a failed delete publishes the message again on the next relay
+			if err := opts.Store.Delete(r.Key, store.DeleteFrom(opts.Database, opts.Table)); err != nil {
+				return err
+			}
+		}
+
+		if len(next) == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// publish the message through the broker or stream
+func (o *outbox) publish(opts Options, m *Message) error {
+	header := make(map[string]string, len(m.Header)+1)
+	for k, v := range m.Header {
+		header[k] = v
+	}
+	header[IDHeader] = m.ID
+
+	if opts.Broker != nil {
+		return opts.Broker.Publish(m.Topic, &broker.Message{Header: header, Body: m.Body})
+	}
+	return opts.Stream.Publish(m.Topic, m.Body, events.WithMetadata(header), events.WithTimestamp(m.Created))
+}
+
+func (o *outbox) Start() error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.running {
+		return nil
+	}
+	if o.opts.Broker == nil && o.opts.Stream == nil {
+		return ErrNoPublisher
+	}
+
+	o.exit = make(chan bool)
+	o.running = true
+
+	// relay as soon as messages are written if the store can be watched
+	wake := make(chan bool, 1)
+	if w, err := o.opts.Store.Watch(o.opts.Prefix, store.WatchFrom(o.opts.Database, o.opts.Table)); err == nil {
+		o.wg.Add(1)
+		go o.watch(w, wake)
+	}
+
+	o.wg.Add(1)
+	go o.run(wake)
+
+	return nil
+}
+
+// watch wakes the relay whenever a message is written
+func (o *outbox) watch(w store.Watcher, wake chan bool) {
+	defer o.wg.Done()
+
+	for {
+		err := o.follow(w, wake)
+		w.Stop()
+		if err != store.ErrWatcherOverflow {
+			return
+		}
+
+		// writes were missed, relay what is pending and watch again
+		select {
+		case wake <- true:
+		default:
+		}
+		w, err = o.opts.Store.Watch(o.opts.Prefix, store.WatchFrom(o.opts.Database, o.opts.Table))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// follow wakes the relay on every write seen by the watcher until it errors
+func (o *outbox) follow(w store.Watcher, wake chan bool) error {
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		select {
+		case <-o.exit:
+			w.Stop()
+		case <-done:
+		}
+	}()
+
+	for {
+		ev, err := w.Next()
+		if err != nil {
+			return err
+		}
+		if ev.Type != store.Put {
+			continue
+		}
+		select {
+		case wake <- true:
+		default:
+		}
+	}
+}
+
+func (o *outbox) run(wake chan bool) {
+	defer o.wg.Done()
+
+	var failures int
+	for {
+		delay := o.Options().Interval
+		// keep backing off after a failure rather than retrying on every write
+		w := wake
+		if failures > 0 {
+			delay = o.Options().Backoff(failures)
+			w = nil
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-o.exit:
+			t.Stop()
+			return
+		case <-w:
+			t.Stop()
+		case <-t.C:
+		}
+
+		if err := o.Relay(); err != nil {
+			failures++
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("[outbox] relay failed %d times: %v", failures, err)
+			}
+			continue
+		}
+		failures = 0
+	}
+}
+
+func (o *outbox) Stop() error {
+	o.Lock()
+	if !o.running {
+		o.Unlock()
+		return nil
+	}
+	o.running = false
+	close(o.exit)
+	o.Unlock()
+
+	o.wg.Wait()
+	return nil
+}
+
+func (o *outbox) String() string {
+	return "store"
+}
@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"time"
+
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/events"
+	"go-micro.dev/v4/store"
+)
+
+// Options of the outbox
+type Options struct {
+	// Store the messages are queued in, defaults to store.DefaultStore. It has to support
+	// batches for messages to be written with the data they describe.
+	Store store.Store
+	// Database and Table the messages and data are written to
+	Database string
+	Table    string
+	// Prefix of the keys messages are queued under, defaults to "outbox/"
+	Prefix string
+	// Broker messages are published to
+	Broker broker.Broker
+	// Stream messages are published to, used if no Broker is set
+	Stream events.Stream
+	// Interval between relays, defaults to one second
+	Interval time.Duration
+	// BatchSize is the number of messages read from the store at a time, defaults to 100
+	BatchSize uint
+	// Backoff returns the delay before relaying again after a publish failed,
+	// defaults to backoff.Do from util/backoff
+	Backoff func(attempts int) time.Duration
+}
+
+// Option sets Options
+type Option func(o *Options)
+
+// Store sets the store messages are queued in
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Table sets the database and table messages and data are written to
+func Table(database, table string) Option {
+	return func(o *Options) {
+		o.Database = database
+		o.Table = table
+	}
+}
+
+// Prefix sets the prefix of the keys messages are queued under
+func Prefix(p string) Option {
+	return func(o *Options) {
+		o.Prefix = p
+	}
+}
+
+// Broker publishes the messages through the broker
+func Broker(b broker.Broker) Option {
+	return func(o *Options) {
+		o.Broker = b
+	}
+}
+
+// Stream publishes the messages to the events stream
+func Stream(s events.Stream) Option {
+	return func(o *Options) {
+		o.Stream = s
+	}
+}
+
+// Interval sets the time between relays
+func Interval(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
+
+// BatchSize sets the number of messages read from the store at a time
+func BatchSize(n uint) Option {
+	return func(o *Options) {
+		o.BatchSize = n
+	}
+}
+
+// Backoff sets the delay before relaying again after a publish failed
+func Backoff(fn func(attempts int) time.Duration) Option {
+	return func(o *Options) {
+		o.Backoff = fn
+	}
+}
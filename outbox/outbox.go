@@ -0,0 +1,86 @@
+// Package outbox publishes messages written to a store in the same batch as the data they describe,
+// so a message is never lost when a process stops between writing its data and publishing.
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go-micro.dev/v4/store"
+)
+
+var (
+	// ErrNoPublisher is returned when the outbox has neither a broker nor a stream to publish to
+	ErrNoPublisher = errors.New("outbox has no broker or stream to publish to")
+	// ErrMissingTopic is returned when writing a message without a topic
+	ErrMissingTopic = errors.New("message has no topic")
+)
+
+// seq orders the messages of the process created in the same nanosecond
+var seq uint64
+
+// IDHeader is the header holding the ID of a message published by the outbox. Messages are
+// delivered at least once, consumers can use the ID to drop duplicates.
+const IDHeader = "Micro-Outbox-Id"
+
+// Outbox queues messages in a store and relays them to a broker or stream
+type Outbox interface {
+	// Init initialises options
+	Init(...Option) error
+	// Options returns the options
+	Options() Options
+	// Write applies the store operations and queues the messages in a single batch
+	Write(ops []store.Op, msgs ...*Message) error
+	// Relay publishes the queued messages in the order they were written,
+	// deleting each once it is published
+	Relay() error
+	// Start relaying messages in the background
+	Start() error
+	// Stop relaying messages
+	Stop() error
+	// String returns the name of the implementation
+	String() string
+}
+
+// Message is queued in the outbox until it is published
+type Message struct {
+	// ID is set when the message is written if it is empty
+	ID     string            `json:"id"`
+	Topic  string            `json:"topic"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   []byte            `json:"body"`
+	// Created is set when the message is written if it is zero
+	Created time.Time `json:"created"`
+}
+
+// Op returns the store operation which queues the message under the prefix,
+// for adding to a batch directly
+func (m *Message) Op(prefix string) (store.Op, error) {
+	if len(m.Topic) == 0 {
+		return store.Op{}, ErrMissingTopic
+	}
+	if len(m.ID) == 0 {
+		m.ID = uuid.New().String()
+	}
+	if m.Created.IsZero() {
+		m.Created = time.Now()
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return store.Op{}, err
+	}
+
+	// keys sort in the order messages were created
+	key := fmt.Sprintf("%s%020d-%020d-%s", prefix, m.Created.UnixNano(), atomic.AddUint64(&seq, 1), m.ID)
+	return store.WriteOp(&store.Record{Key: key, Value: b}), nil
+}
+
+// NewOutbox returns an outbox which queues messages in the store and relays them
+func NewOutbox(opts ...Option) Outbox {
+	return newOutbox(opts...)
+}
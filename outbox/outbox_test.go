@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/events"
+	"go-micro.dev/v4/store"
+)
+
+// failingBroker fails to publish until it is told to succeed
+type failingBroker struct {
+	broker.Broker
+	fail bool
+}
+
+func (b *failingBroker) Publish(topic string, m *broker.Message, opts ...broker.PublishOption) error {
+	if b.fail {
+		return errors.New("unavailable")
+	}
+	return b.Broker.Publish(topic, m, opts...)
+}
+
+func TestOutboxBroker(t *testing.T) {
+	st := store.NewMemoryStore()
+	b := &failingBroker{Broker: broker.NewMemoryBroker(), fail: true}
+	assert.NoError(t, b.Connect())
+
+	received := make(chan *broker.Message, 10)
+	_, err := b.Subscribe("orders", func(e broker.Event) error {
+		received <- e.Message()
+		return nil
+	})
+	assert.NoError(t, err)
+
+	o := NewOutbox(Store(st), Broker(b), Table("shop", "orders"))
+
+	// the data and message are written together
+	err = o.Write([]store.Op{store.WriteOp(&store.Record{Key: "order-1", Value: []byte("{}")})},
+		&Message{Topic: "orders", Header: map[string]string{"type": "created"}, Body: []byte("1")},
+		&Message{Topic: "orders", Body: []byte("2")},
+	)
+	assert.NoError(t, err, "Unexpected error writing")
+
+	recs, err := st.Read("", store.ReadPrefix(), store.ReadFrom("shop", "orders"))
+	assert.NoError(t, err)
+	assert.Len(t, recs, 3, "The record and both messages should be written")
+
+	// nothing is lost while the broker is unavailable
+	assert.Error(t, o.Relay(), "Relaying should fail while the broker fails")
+	recs, err = st.Read("outbox/", store.ReadPrefix(), store.ReadFrom("shop", "orders"))
+	assert.NoError(t, err)
+	assert.Len(t, recs, 2, "Messages should stay queued until they are published")
+
+	b.fail = false
+	assert.NoError(t, o.Relay(), "Unexpected error relaying")
+
+	for _, body := range []string{"1", "2"} {
+		select {
+		case m := <-received:
+			assert.Equal(t, body, string(m.Body), "Messages should be published in order")
+			assert.NotEmpty(t, m.Header[IDHeader], "The message ID should be set")
+			if body == "1" {
+				assert.Equal(t, "created", m.Header["type"])
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for message %v", body)
+		}
+	}
+
+	// published messages are cleaned up, the data is kept
+	recs, err = st.Read("", store.ReadPrefix(), store.ReadFrom("shop", "orders"))
+	assert.NoError(t, err)
+	if assert.Len(t, recs, 1, "Published messages should be deleted") {
+		assert.Equal(t, "order-1", recs[0].Key)
+	}
+}
+
+func TestOutboxStream(t *testing.T) {
+	st := store.NewMemoryStore()
+	stream, err := events.NewStream()
+	assert.NoError(t, err)
+
+	ch, err := stream.Consume("payments")
+	assert.NoError(t, err)
+
+	o := NewOutbox(Store(st), Stream(stream), Interval(time.Hour))
+	assert.NoError(t, o.Start())
+	defer o.Stop()
+
+	// the relay is woken by the write rather than waiting for the interval
+	msg := &Message{Topic: "payments", Body: []byte(`"paid"`)}
+	assert.NoError(t, o.Write(nil, msg))
+
+	select {
+	case ev := <-ch:
+		assert.Equal(t, `"paid"`, string(ev.Payload))
+		assert.Equal(t, msg.ID, ev.Metadata[IDHeader])
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for event")
+	}
+}
+
+func TestOutboxErrors(t *testing.T) {
+	o := NewOutbox(Store(store.NewMemoryStore()))
+	assert.Equal(t, ErrNoPublisher, o.Relay())
+	assert.Equal(t, ErrNoPublisher, o.Start())
+	assert.Equal(t, ErrMissingTopic, o.Write(nil, &Message{Body: []byte("1")}))
+}
+
+func TestMessageOpOrder(t *testing.T) {
+	// messages created in the same nanosecond keep the order they're queued in
+	created := time.Now()
+	var last string
+	for i := 0; i < 100; i++ {
+		op, err := (&Message{Topic: "orders", Created: created}).Op("outbox/")
+		assert.NoError(t, err)
+		assert.Greater(t, op.Record.Key, last, "Keys should sort in the order messages are queued")
+		last = op.Record.Key
+	}
+}
@@ -209,6 +209,11 @@ func (s *sqlStore) List(opts ...store.ListOption) ([]string, error) {
 		o(&options)
 	}
 
+	// cursors are not supported
+	if len(options.Cursor) > 0 || options.Next != nil {
+		return nil, store.ErrNotSupported
+	}
+
 	// create the db if not exists
 	if err := s.createDB(options.Database, options.Table); err != nil {
 		return nil, err
@@ -274,6 +279,11 @@ func (s *sqlStore) Read(key string, opts ...store.ReadOption) ([]*store.Record,
 		o(&options)
 	}
 
+	// indexes and cursors are not supported
+	if len(options.Index) > 0 || len(options.Cursor) > 0 || options.Next != nil {
+		return nil, store.ErrNotSupported
+	}
+
 	// create the db if not exists
 	if err := s.createDB(options.Database, options.Table); err != nil {
 		return nil, err
@@ -469,6 +479,16 @@ func (s *sqlStore) Options() store.Options {
 	return s.options
 }
 
+// Batch is not supported by the cockroach store
+func (s *sqlStore) Batch(ops []store.Op, opts ...store.BatchOption) error {
+	return store.ErrNotSupported
+}
+
+// Watch is not supported by the cockroach store
+func (s *sqlStore) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	return nil, store.ErrNotSupported
+}
+
 func (s *sqlStore) String() string {
 	return "cockroach"
 }
@@ -87,6 +87,12 @@ func (c *ckv) List(opts ...store.ListOption) ([]string, error) {
 	for _, o := range opts {
 		o(&options)
 	}
+
+	// cursors are not supported
+	if len(options.Cursor) > 0 || options.Next != nil {
+		return nil, store.ErrNotSupported
+	}
+
 	if options.Table == "" {
 		options.Table = "/"
 	} else if options.Table[len(options.Table)-1] != '/' {
@@ -107,6 +113,16 @@ func (c *ckv) List(opts ...store.ListOption) ([]string, error) {
 	return keys, nil
 }
 
+// Batch is not supported by the consul store
+func (c *ckv) Batch(ops []store.Op, opts ...store.BatchOption) error {
+	return store.ErrNotSupported
+}
+
+// Watch is not supported by the consul store
+func (c *ckv) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	return nil, store.ErrNotSupported
+}
+
 func (c *ckv) String() string {
 	return "consul"
 }
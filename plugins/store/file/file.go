@@ -2,14 +2,16 @@
 package file
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"go-micro.dev/v4/store"
 	bolt "go.etcd.io/bbolt"
 )
@@ -48,6 +50,12 @@ type fileStore struct {
 type fileHandle struct {
 	key string
 	db  *bolt.DB
+
+	watchLock sync.RWMutex
+	watchers  map[string]*fileWatcher
+	// starts the expiry sweep on the first watch
+	sweepOnce sync.Once
+	exit      chan bool
 }
 
 // record stored by us
@@ -56,20 +64,86 @@ type record struct {
 	Value     []byte
 	Metadata  map[string]interface{}
 	ExpiresAt time.Time
+	Version   uint64
 }
 
 func key(database, table string) string {
 	return database + ":" + table
 }
 
+// storeRecord returns the record as a store.Record
+func (r *record) storeRecord() *store.Record {
+	newRecord := &store.Record{}
+	newRecord.Key = r.Key
+	newRecord.Value = r.Value
+	newRecord.Metadata = make(map[string]interface{})
+	newRecord.Version = r.Version
+
+	for k, v := range r.Metadata {
+		newRecord.Metadata[k] = v
+	}
+
+	if !r.ExpiresAt.IsZero() {
+		newRecord.Expiry = time.Until(r.ExpiresAt)
+	}
+
+	return newRecord
+}
+
 func (m *fileStore) delete(fd *fileHandle, key string) error {
-	return fd.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(dataBucket))
-		if b == nil {
-			return nil
+	var event store.EventType
+	var found bool
+
+	if err := fd.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		event, found, err = m.remove(tx, key)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if found {
+		fd.notify(event, &store.Record{Key: key})
+	}
+
+	return nil
+}
+
+// remove deletes the record and its index entries, returning the event
+// for deleting it and whether there was a record to delete
+func (m *fileStore) remove(tx *bolt.Tx, key string) (store.EventType, bool, error) {
+	b := tx.Bucket([]byte(dataBucket))
+	if b == nil {
+		return store.Delete, false, nil
+	}
+
+	event, found := deleted(b, key)
+	if !found {
+		return event, false, nil
+	}
+
+	if err := m.unindex(tx, b.Get([]byte(key))); err != nil {
+		return event, false, err
+	}
+
+	return event, true, b.Delete([]byte(key))
+}
+
+// deleted returns the event for deleting key and whether there is a record to delete
+func deleted(b *bolt.Bucket, key string) (store.EventType, bool) {
+	value := b.Get([]byte(key))
+	if value == nil {
+		return store.Delete, false
+	}
+
+	storedRecord := &record{}
+	if err := json.Unmarshal(value, storedRecord); err == nil {
+		if !storedRecord.ExpiresAt.IsZero() && storedRecord.ExpiresAt.Before(time.Now()) {
+			return store.Expire, true
 		}
-		return b.Delete([]byte(key))
-	})
+	}
+
+	return store.Delete, true
 }
 
 func (m *fileStore) init(opts ...store.Option) error {
@@ -137,16 +211,28 @@ func (f *fileStore) getDB(database, table string) (*fileHandle, error) {
 		return nil, err
 	}
 	fd = &fileHandle{
-		key: k,
-		db:  db,
+		key:      k,
+		db:       db,
+		watchers: make(map[string]*fileWatcher),
+		exit:     make(chan bool),
+	}
+
+	// the table may have been written without the indexes now declared
+	if len(f.options.Indexes) > 0 {
+		if err := f.reindex(fd); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 	f.handles[k] = fd
 
 	return fd, nil
 }
 
-func (m *fileStore) list(fd *fileHandle, limit, offset uint) []string {
-	var allItems []string
+// list returns the live keys with the prefix and suffix in order, skipping offset
+// keys and returning up to limit of them. A limit of 0 returns them all.
+func (m *fileStore) list(fd *fileHandle, prefix, suffix string, limit, offset uint) []string {
+	var allKeys []string
 
 	fd.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(dataBucket))
@@ -155,48 +241,96 @@ func (m *fileStore) list(fd *fileHandle, limit, offset uint) []string {
 			return nil
 		}
 
-		// @todo very inefficient
-		if err := b.ForEach(func(k, v []byte) error {
-			storedRecord := &record{}
+		// keys are kept in order by bbolt so the prefix is a range scan
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			if !strings.HasSuffix(string(k), suffix) {
+				continue
+			}
 
+			storedRecord := &record{}
 			if err := json.Unmarshal(v, storedRecord); err != nil {
 				return err
 			}
 
 			if !storedRecord.ExpiresAt.IsZero() {
 				if storedRecord.ExpiresAt.Before(time.Now()) {
-					return nil
+					continue
 				}
 			}
 
-			allItems = append(allItems, string(k))
-
-			return nil
-		}); err != nil {
-			return err
+			allKeys = append(allKeys, string(k))
 		}
 
 		return nil
 	})
 
-	allKeys := make([]string, len(allItems))
+	if offset >= uint(len(allKeys)) {
+		return nil
+	}
+	allKeys = allKeys[offset:]
+	if limit > 0 && limit < uint(len(allKeys)) {
+		allKeys = allKeys[:limit]
+	}
 
-	for i, k := range allItems {
-		allKeys[i] = k
+	return allKeys
+}
+
+// page returns the keys after the cursor, up to limit, and the cursor of the next page.
+// Keys are kept in order by bbolt so the cursor seeks straight to the page.
+func (m *fileStore) page(fd *fileHandle, prefix, suffix, cursor string, limit uint) ([]string, string, error) {
+	seek := []byte(prefix)
+	var after string
+	if len(cursor) > 0 {
+		c, err := store.ParseCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = c.Key
+		if after > prefix {
+			seek = []byte(after)
+		}
 	}
 
-	if limit != 0 || offset != 0 {
-		sort.Slice(allKeys, func(i, j int) bool { return allKeys[i] < allKeys[j] })
-		min := func(i, j uint) uint {
-			if i < j {
-				return i
+	var keys []string
+	var next string
+
+	err := fd.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(dataBucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, v := c.Seek(seek); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			key := string(k)
+			if len(cursor) > 0 && key <= after {
+				continue
+			}
+			if !strings.HasSuffix(key, suffix) {
+				continue
+			}
+
+			storedRecord := &record{}
+			if err := json.Unmarshal(v, storedRecord); err != nil {
+				return err
+			}
+			if !storedRecord.ExpiresAt.IsZero() && storedRecord.ExpiresAt.Before(time.Now()) {
+				continue
+			}
+
+			// one more key than the limit tells us there is a next page
+			if limit > 0 && uint(len(keys)) == limit {
+				next = (&store.Cursor{Key: keys[len(keys)-1]}).String()
+				return nil
 			}
-			return j
+			keys = append(keys, key)
 		}
-		return allKeys[offset:min(limit, uint(len(allKeys)))]
-	}
 
-	return allKeys
+		return nil
+	})
+
+	return keys, next, err
 }
 
 func (m *fileStore) get(fd *fileHandle, k string) (*store.Record, error) {
@@ -223,32 +357,42 @@ func (m *fileStore) get(fd *fileHandle, k string) (*store.Record, error) {
 		return nil, err
 	}
 
-	newRecord := &store.Record{}
-	newRecord.Key = storedRecord.Key
-	newRecord.Value = storedRecord.Value
-	newRecord.Metadata = make(map[string]interface{})
-
-	for k, v := range storedRecord.Metadata {
-		newRecord.Metadata[k] = v
+	if !storedRecord.ExpiresAt.IsZero() && storedRecord.ExpiresAt.Before(time.Now()) {
+		return nil, store.ErrNotFound
 	}
 
-	if !storedRecord.ExpiresAt.IsZero() {
-		if storedRecord.ExpiresAt.Before(time.Now()) {
-			return nil, store.ErrNotFound
-		}
-		newRecord.Expiry = time.Until(storedRecord.ExpiresAt)
+	return storedRecord.storeRecord(), nil
+}
+
+func (m *fileStore) set(fd *fileHandle, r *store.Record) error {
+	var item *record
+
+	if err := fd.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		item, err = m.put(tx, r)
+		return err
+	}); err != nil {
+		return err
 	}
 
-	return newRecord, nil
+	fd.notify(store.Put, item.storeRecord())
+	return nil
 }
 
-func (m *fileStore) set(fd *fileHandle, r *store.Record) error {
+// put writes the record and its index entries, bumping the version of any existing record
+func (m *fileStore) put(tx *bolt.Tx, r *store.Record) (*record, error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(dataBucket))
+	if err != nil {
+		return nil, err
+	}
+
 	// copy the incoming record and then
 	// convert the expiry in to a hard timestamp
 	item := &record{}
 	item.Key = r.Key
 	item.Value = r.Value
 	item.Metadata = make(map[string]interface{})
+	item.Version = version(b, r.Key) + 1
 
 	if r.Expiry != 0 {
 		item.ExpiresAt = time.Now().Add(r.Expiry)
@@ -261,23 +405,41 @@ func (m *fileStore) set(fd *fileHandle, r *store.Record) error {
 	// marshal the data
 	data, _ := json.Marshal(item)
 
-	return fd.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(dataBucket))
-		if b == nil {
-			var err error
-			b, err = tx.CreateBucketIfNotExists([]byte(dataBucket))
-			if err != nil {
-				return err
-			}
-		}
-		return b.Put([]byte(r.Key), data)
-	})
+	if err := m.unindex(tx, b.Get([]byte(r.Key))); err != nil {
+		return nil, err
+	}
+
+	if err := b.Put([]byte(r.Key), data); err != nil {
+		return nil, err
+	}
+
+	return item, m.index(tx, item)
+}
+
+// version returns the version of the live record at key, 0 if there is none
+func version(b *bolt.Bucket, key string) uint64 {
+	value := b.Get([]byte(key))
+	if value == nil {
+		return 0
+	}
+
+	storedRecord := &record{}
+	if err := json.Unmarshal(value, storedRecord); err != nil {
+		return 0
+	}
+
+	if !storedRecord.ExpiresAt.IsZero() && storedRecord.ExpiresAt.Before(time.Now()) {
+		return 0
+	}
+
+	return storedRecord.Version
 }
 
 func (f *fileStore) Close() error {
 	f.Lock()
 	defer f.Unlock()
 	for k, v := range f.handles {
+		close(v.exit)
 		v.db.Close()
 		delete(f.handles, k)
 	}
@@ -285,7 +447,20 @@ func (f *fileStore) Close() error {
 }
 
 func (f *fileStore) Init(opts ...store.Option) error {
-	return f.init(opts...)
+	if err := f.init(opts...); err != nil {
+		return err
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	// index the records written before the indexes were declared
+	for _, fd := range f.handles {
+		if err := f.reindex(fd); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (m *fileStore) Delete(key string, opts ...store.DeleteOption) error {
@@ -313,33 +488,44 @@ func (m *fileStore) Read(key string, opts ...store.ReadOption) ([]*store.Record,
 		return nil, err
 	}
 
+	if len(readOpts.Index) > 0 {
+		return m.query(fd, key, readOpts)
+	}
+
 	var keys []string
 
 	// Handle Prefix / suffix
-	// TODO: do range scan here rather than listing all keys
-	if readOpts.Prefix || readOpts.Suffix {
-		// list the keys
-		k := m.list(fd, readOpts.Limit, readOpts.Offset)
-
-		// check for prefix and suffix
-		for _, v := range k {
-			if readOpts.Prefix && !strings.HasPrefix(v, key) {
-				continue
-			}
-			if readOpts.Suffix && !strings.HasSuffix(v, key) {
-				continue
-			}
-			keys = append(keys, v)
+	var prefix, suffix string
+	if readOpts.Prefix {
+		prefix = key
+	}
+	if readOpts.Suffix {
+		suffix = key
+	}
+
+	if !readOpts.Prefix && !readOpts.Suffix {
+		keys = []string{key}
+	} else if len(readOpts.Cursor) > 0 || readOpts.Next != nil {
+		k, next, err := m.page(fd, prefix, suffix, readOpts.Cursor, readOpts.Limit)
+		if err != nil {
+			return nil, err
 		}
+		if readOpts.Next != nil {
+			*readOpts.Next = next
+		}
+		keys = k
 	} else {
-		keys = []string{key}
+		keys = m.list(fd, prefix, suffix, readOpts.Limit, readOpts.Offset)
 	}
 
 	var results []*store.Record
 
 	for _, k := range keys {
 		r, err := m.get(fd, k)
-		if err != nil {
+		if err == store.ErrNotFound && (readOpts.Prefix || readOpts.Suffix) {
+			// expired since the keys were listed
+			continue
+		} else if err != nil {
 			return results, err
 		}
 		results = append(results, r)
@@ -384,6 +570,102 @@ func (m *fileStore) Write(r *store.Record, opts ...store.WriteOption) error {
 	return m.set(fd, r)
 }
 
+func (m *fileStore) Batch(ops []store.Op, opts ...store.BatchOption) error {
+	var batchOpts store.BatchOptions
+	for _, o := range opts {
+		o(&batchOpts)
+	}
+
+	fd, err := m.getDB(batchOpts.Database, batchOpts.Table)
+	if err != nil {
+		return err
+	}
+
+	var events []*store.Event
+
+	// returning an error from the update rolls back the whole batch
+	if err := fd.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(dataBucket))
+		if err != nil {
+			return err
+		}
+
+		// check every version before applying anything
+		for _, op := range ops {
+			if op.Record == nil {
+				return errors.New("batch operation has no record")
+			}
+			if op.Compare && version(b, op.Record.Key) != op.Record.Version {
+				return store.ErrConflict
+			}
+		}
+
+		for _, op := range ops {
+			switch op.Type {
+			case store.OpWrite:
+				item, err := m.put(tx, op.Record)
+				if err != nil {
+					return err
+				}
+				events = append(events, &store.Event{Type: store.Put, Record: item.storeRecord()})
+			case store.OpDelete:
+				event, found, err := m.remove(tx, op.Record.Key)
+				if err != nil {
+					return err
+				}
+				if found {
+					events = append(events, &store.Event{Type: event, Record: &store.Record{Key: op.Record.Key}})
+				}
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		fd.notify(e.Type, e.Record)
+	}
+
+	return nil
+}
+
+func (m *fileStore) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	var watchOpts store.WatchOptions
+	for _, o := range opts {
+		o(&watchOpts)
+	}
+
+	fd, err := m.getDB(watchOpts.Database, watchOpts.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &fileWatcher{
+		id:       uuid.New().String(),
+		prefix:   prefix,
+		events:   make(chan *store.Event, 100),
+		exit:     make(chan bool),
+		overflow: make(chan bool),
+	}
+	w.remove = func() {
+		fd.watchLock.Lock()
+		delete(fd.watchers, w.id)
+		fd.watchLock.Unlock()
+	}
+
+	fd.watchLock.Lock()
+	fd.watchers[w.id] = w
+	fd.watchLock.Unlock()
+
+	fd.sweepOnce.Do(func() {
+		go m.sweep(fd)
+	})
+
+	return w, nil
+}
+
 func (m *fileStore) Options() store.Options {
 	return m.options
 }
@@ -400,30 +682,18 @@ func (m *fileStore) List(opts ...store.ListOption) ([]string, error) {
 		return nil, err
 	}
 
-	// TODO apply prefix/suffix in range query
-	allKeys := m.list(fd, listOptions.Limit, listOptions.Offset)
-
-	if len(listOptions.Prefix) > 0 {
-		var prefixKeys []string
-		for _, k := range allKeys {
-			if strings.HasPrefix(k, listOptions.Prefix) {
-				prefixKeys = append(prefixKeys, k)
-			}
+	if len(listOptions.Cursor) > 0 || listOptions.Next != nil {
+		keys, next, err := m.page(fd, listOptions.Prefix, listOptions.Suffix, listOptions.Cursor, listOptions.Limit)
+		if err != nil {
+			return nil, err
 		}
-		allKeys = prefixKeys
-	}
-
-	if len(listOptions.Suffix) > 0 {
-		var suffixKeys []string
-		for _, k := range allKeys {
-			if strings.HasSuffix(k, listOptions.Suffix) {
-				suffixKeys = append(suffixKeys, k)
-			}
+		if listOptions.Next != nil {
+			*listOptions.Next = next
 		}
-		allKeys = suffixKeys
+		return keys, nil
 	}
 
-	return allKeys, nil
+	return m.list(fd, listOptions.Prefix, listOptions.Suffix, listOptions.Limit, listOptions.Offset), nil
 }
 
 func (m *fileStore) String() string {
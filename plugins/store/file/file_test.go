@@ -10,7 +10,10 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/kr/pretty"
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/outbox"
 	"go-micro.dev/v4/store"
+	"go-micro.dev/v4/store/test"
 )
 
 func cleanup(db string, s store.Store) {
@@ -52,6 +55,139 @@ func TestFileStoreDatabaseTable(t *testing.T) {
 	fileTest(s, t)
 }
 
+func TestFileStoreBatch(t *testing.T) {
+	s := NewStore(store.Table("batch"))
+	defer cleanup(DefaultDatabase, s)
+	test.Batch(t, s)
+}
+
+func TestFileStoreWatch(t *testing.T) {
+	s := NewStore(store.Table("watch"))
+	defer cleanup(DefaultDatabase, s)
+
+	w, err := s.Watch("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	s.Write(&store.Record{Key: "bar", Value: []byte("ignored")})
+	s.Write(&store.Record{Key: "foo", Value: []byte("bar")})
+	s.Write(&store.Record{Key: "foobar", Value: []byte("baz")}, store.WriteTTL(time.Millisecond*100))
+	s.Delete("foo")
+
+	expected := []struct {
+		Type store.EventType
+		Key  string
+	}{
+		{store.Put, "foo"},
+		{store.Put, "foobar"},
+		{store.Delete, "foo"},
+		{store.Expire, "foobar"},
+	}
+
+	for _, e := range expected {
+		ev, err := w.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != e.Type || ev.Record.Key != e.Key {
+			t.Fatalf("Expected %v %s, got %v %s", e.Type, e.Key, ev.Type, ev.Record.Key)
+		}
+	}
+
+	w.Stop()
+	if _, err := w.Next(); err != store.ErrWatcherStopped {
+		t.Fatalf("Expected %v, got %v", store.ErrWatcherStopped, err)
+	}
+}
+
+func TestFileStoreIndex(t *testing.T) {
+	s := NewStore(store.Table("index"))
+	defer cleanup(DefaultDatabase, s)
+	test.Index(t, s)
+}
+
+func TestFileStoreReindex(t *testing.T) {
+	s := NewStore(store.Table("reindex"))
+	if err := s.Write(&store.Record{Key: "alice", Metadata: map[string]interface{}{"team": "red"}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// a table written without the indexes is indexed when opened
+	s = NewStore(store.Table("reindex"), store.Indexes(store.Index{Name: "team", Metadata: "team"}))
+	defer cleanup(DefaultDatabase, s)
+
+	r, err := s.Read("red", store.ReadIndex("team"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := test.Keys(r); k != "alice" {
+		t.Fatalf("Expected alice, got %s", k)
+	}
+}
+
+func TestFileStoreLimitOffset(t *testing.T) {
+	s := NewStore(store.Table("limit"))
+	defer cleanup(DefaultDatabase, s)
+	test.LimitOffset(t, s)
+}
+
+func TestFileStoreCursor(t *testing.T) {
+	s := NewStore(store.Table("cursor"))
+	defer cleanup(DefaultDatabase, s)
+
+	for i := 0; i < 10; i++ {
+		s.Write(&store.Record{Key: fmt.Sprintf("k%d", i)})
+	}
+
+	var keys []string
+	var cursor string
+	for {
+		var next string
+		page, err := s.List(store.ListPrefix("k"), store.ListLimit(3), store.ListCursor(cursor), store.ListNextCursor(&next))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, page...)
+
+		// writes before the cursor must not shift the following pages
+		s.Write(&store.Record{Key: "k00"})
+
+		if len(next) == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	if len(keys) != 10 {
+		t.Fatalf("Expected 10 keys, got %d: %v", len(keys), keys)
+	}
+	for i, k := range keys {
+		if k != fmt.Sprintf("k%d", i) {
+			t.Fatalf("Expected k%d, got %s", i, k)
+		}
+	}
+
+	var next string
+	records, err := s.Read("k", store.ReadPrefix(), store.ReadLimit(5), store.ReadCursor(cursor), store.ReadNextCursor(&next))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Key != "k9" || len(next) != 0 {
+		t.Fatalf("Expected the last record, got %d records and cursor %q", len(records), next)
+	}
+
+	if _, err := s.List(store.ListCursor("not a cursor")); err != store.ErrInvalidCursor {
+		t.Fatalf("Expected %v, got %v", store.ErrInvalidCursor, err)
+	}
+}
+
+// expiry is the unit of the expiry times in fileTest, long enough for the writes
+// and reads between them on a slow disk under the race detector
+const expiry = time.Millisecond * 300
+
 func fileTest(s store.Store, t *testing.T) {
 	if len(os.Getenv("IN_TRAVIS_CI")) == 0 {
 		t.Logf("Options %s %v\n", s.String(), s.Options())
@@ -60,7 +196,7 @@ func fileTest(s store.Store, t *testing.T) {
 	if err := s.Write(&store.Record{
 		Key:    "Hello",
 		Value:  []byte("World"),
-		Expiry: time.Millisecond * 150,
+		Expiry: expiry * 3 / 2,
 	}); err != nil {
 		t.Error(err)
 	}
@@ -80,7 +216,7 @@ func fileTest(s store.Store, t *testing.T) {
 	}
 
 	// wait for expiry
-	time.Sleep(time.Millisecond * 200)
+	time.Sleep(expiry * 2)
 
 	if _, err := s.Read("Hello"); err != store.ErrNotFound {
 		t.Errorf("Expected %# v, got %# v", store.ErrNotFound, err)
@@ -95,7 +231,7 @@ func fileTest(s store.Store, t *testing.T) {
 		&store.Record{
 			Key:    "foobar",
 			Value:  []byte("foobarfoobar"),
-			Expiry: time.Millisecond * 100,
+			Expiry: expiry,
 		},
 	}
 
@@ -115,7 +251,7 @@ func fileTest(s store.Store, t *testing.T) {
 	}
 
 	// wait for the expiry
-	time.Sleep(time.Millisecond * 200)
+	time.Sleep(expiry * 2)
 
 	if results, err := s.Read("foo", store.ReadPrefix()); err != nil {
 		t.Errorf("Couldn't read all \"foo\" keys, got %# v (%s)", spew.Sdump(results), err)
@@ -146,12 +282,12 @@ func fileTest(s store.Store, t *testing.T) {
 			Key:   "barfoo",
 			Value: []byte("barfoobarfoo"),
 
-			Expiry: time.Millisecond * 100,
+			Expiry: expiry,
 		},
 		&store.Record{
 			Key:    "bazbarfoo",
 			Value:  []byte("bazbarfoobazbarfoo"),
-			Expiry: 2 * time.Millisecond * 100,
+			Expiry: 2 * expiry,
 		},
 	}
 	for _, r := range records {
@@ -168,7 +304,7 @@ func fileTest(s store.Store, t *testing.T) {
 		}
 
 	}
-	time.Sleep(time.Millisecond * 100)
+	time.Sleep(expiry)
 	if results, err := s.Read("foo", store.ReadSuffix()); err != nil {
 		t.Errorf("Couldn't read all \"foo\" keys, got %# v (%s)", spew.Sdump(results), err)
 	} else {
@@ -178,7 +314,7 @@ func fileTest(s store.Store, t *testing.T) {
 		}
 
 	}
-	time.Sleep(time.Millisecond * 100)
+	time.Sleep(expiry)
 	if results, err := s.Read("foo", store.ReadSuffix()); err != nil {
 		t.Errorf("Couldn't read all \"foo\" keys, got %# v (%s)", spew.Sdump(results), err)
 	} else {
@@ -202,20 +338,20 @@ func fileTest(s store.Store, t *testing.T) {
 	if err := s.Write(&store.Record{
 		Key:   "foofoobarbar",
 		Value: []byte("something"),
-	}, store.WriteTTL(time.Millisecond*100)); err != nil {
+	}, store.WriteTTL(expiry)); err != nil {
 		t.Error(err)
 	}
 	if err := s.Write(&store.Record{
 		Key:   "foofoo",
 		Value: []byte("something"),
-	}, store.WriteExpiry(time.Now().Add(time.Millisecond*100))); err != nil {
+	}, store.WriteExpiry(time.Now().Add(expiry))); err != nil {
 		t.Error(err)
 	}
 	if err := s.Write(&store.Record{
 		Key:   "barbar",
 		Value: []byte("something"),
 		// TTL has higher precedence than expiry
-	}, store.WriteExpiry(time.Now().Add(time.Hour)), store.WriteTTL(time.Millisecond*100)); err != nil {
+	}, store.WriteExpiry(time.Now().Add(time.Hour)), store.WriteTTL(expiry)); err != nil {
 		t.Error(err)
 	}
 
@@ -227,7 +363,7 @@ func fileTest(s store.Store, t *testing.T) {
 		}
 	}
 
-	time.Sleep(time.Millisecond * 100)
+	time.Sleep(expiry)
 
 	if results, err := s.List(); err != nil {
 		t.Errorf("List failed: %s", err)
@@ -266,3 +402,57 @@ func fileTest(s store.Store, t *testing.T) {
 		}
 	}
 }
+
+func TestFileStoreOutboxRelay(t *testing.T) {
+	s := NewStore(store.Table("outbox"))
+	defer cleanup(DefaultDatabase, s)
+
+	b := broker.NewMemoryBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 10)
+	if _, err := b.Subscribe("orders", func(e broker.Event) error {
+		received <- string(e.Message().Body)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	o := outbox.NewOutbox(outbox.Store(s), outbox.Broker(b), outbox.BatchSize(2))
+
+	// more records sorting before the messages than a batch holds
+	var ops []store.Op
+	for i := 0; i < 5; i++ {
+		ops = append(ops, store.WriteOp(&store.Record{Key: fmt.Sprintf("a-%d", i), Value: []byte("{}")}))
+	}
+	var msgs []*outbox.Message
+	for i := 0; i < 3; i++ {
+		msgs = append(msgs, &outbox.Message{Topic: "orders", Body: []byte(fmt.Sprint(i))})
+	}
+	if err := o.Write(ops, msgs...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.Relay(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case body := <-received:
+			if body != fmt.Sprint(i) {
+				t.Fatalf("Expected message %d, got %s", i, body)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for message %d", i)
+		}
+	}
+
+	keys, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 5 {
+		t.Fatalf("Expected the messages deleted and the records kept, got %v", keys)
+	}
+}
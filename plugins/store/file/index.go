@@ -0,0 +1,237 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"go-micro.dev/v4/store"
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucket holding the entries of an index
+func indexBucket(name string) []byte {
+	return []byte("index:" + name)
+}
+
+// entries are keyed by the type and string form of the indexed value and the
+// record key, so equality and prefix lookups are range scans
+func indexKey(value interface{}, key string) []byte {
+	return []byte(store.IndexKey(value) + "\x00" + key)
+}
+
+// kinds are the type prefixes of index keys, see store.IndexKey
+var kinds = []string{"n:", "b:", "s:"}
+
+// index adds the record to every declared index
+func (m *fileStore) index(tx *bolt.Tx, item *record) error {
+	r := item.storeRecord()
+
+	for _, idx := range m.options.Indexes {
+		v, ok := idx.Value(r)
+		if !ok {
+			continue
+		}
+		b, err := tx.CreateBucketIfNotExists(indexBucket(idx.Name))
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(indexKey(v, item.Key), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reindex rebuilds the indexes of the table from the records stored, so
+// indexes declared after records are written cover them
+func (m *fileStore) reindex(fd *fileHandle) error {
+	return fd.db.Update(func(tx *bolt.Tx) error {
+		var stale [][]byte
+		if err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if bytes.HasPrefix(name, []byte("index:")) {
+				stale = append(stale, append([]byte(nil), name...))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		// buckets can't be deleted while iterating
+		for _, name := range stale {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+
+		b := tx.Bucket([]byte(dataBucket))
+		if b == nil || len(m.options.Indexes) == 0 {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			storedRecord := &record{}
+			if err := json.Unmarshal(v, storedRecord); err != nil {
+				return nil
+			}
+			return m.index(tx, storedRecord)
+		})
+	})
+}
+
+// unindex removes the stored record from every declared index
+func (m *fileStore) unindex(tx *bolt.Tx, value []byte) error {
+	if value == nil || len(m.options.Indexes) == 0 {
+		return nil
+	}
+
+	storedRecord := &record{}
+	if err := json.Unmarshal(value, storedRecord); err != nil {
+		return nil
+	}
+	r := storedRecord.storeRecord()
+
+	for _, idx := range m.options.Indexes {
+		v, ok := idx.Value(r)
+		if !ok {
+			continue
+		}
+		b := tx.Bucket(indexBucket(idx.Name))
+		if b == nil {
+			continue
+		}
+		if err := b.Delete(indexKey(v, storedRecord.Key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// query reads the records matching key through an index
+func (m *fileStore) query(fd *fileHandle, key string, opts store.ReadOptions) ([]*store.Record, error) {
+	declared := false
+	for _, idx := range m.options.Indexes {
+		if idx.Name == opts.Index {
+			declared = true
+			break
+		}
+	}
+	if !declared {
+		return nil, store.ErrIndexNotFound
+	}
+
+	type entry struct {
+		value interface{}
+		key   string
+	}
+
+	var entries []entry
+
+	if err := fd.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(indexBucket(opts.Index))
+		if b == nil {
+			return nil
+		}
+
+		var seeks []string
+		switch {
+		case opts.Prefix:
+			for _, kind := range kinds {
+				seeks = append(seeks, kind+key)
+			}
+		case opts.Suffix:
+			// suffix matches have to scan the whole index
+			seeks = []string{""}
+		default:
+			for _, k := range store.MatchKeys(key) {
+				seeks = append(seeks, k+"\x00")
+			}
+		}
+
+		c := b.Cursor()
+		for _, seek := range seeks {
+			for k, v := c.Seek([]byte(seek)); k != nil && bytes.HasPrefix(k, []byte(seek)); k, v = c.Next() {
+				i := bytes.IndexByte(k, 0)
+				if i < 2 {
+					continue
+				}
+				// the string form of the value follows its type
+				if opts.Suffix && !strings.HasSuffix(string(k[2:i]), key) {
+					continue
+				}
+				var value interface{}
+				if err := json.Unmarshal(v, &value); err != nil {
+					return err
+				}
+				entries = append(entries, entry{value: value, key: string(k[i+1:])})
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		c := store.CompareValues(entries[i].value, entries[j].value)
+		if c == 0 {
+			c = strings.Compare(entries[i].key, entries[j].key)
+		}
+		if opts.Order == store.OrderDesc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	var after *store.Cursor
+	if len(opts.Cursor) > 0 {
+		c, err := store.ParseCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+
+	var results []*store.Record
+	var next string
+	offset := opts.Offset
+	if after != nil {
+		offset = 0
+	}
+
+	for i, e := range entries {
+		if after != nil && after.Seen(e.value, e.key, opts.Order) {
+			continue
+		}
+		r, err := m.get(fd, e.key)
+		if err == store.ErrNotFound {
+			// expired but not yet removed
+			continue
+		} else if err != nil {
+			return results, err
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		results = append(results, r)
+		if opts.Limit > 0 && uint(len(results)) == opts.Limit {
+			if i < len(entries)-1 {
+				next = (&store.Cursor{Key: e.key, Value: e.value}).String()
+			}
+			break
+		}
+	}
+
+	if opts.Next != nil {
+		*opts.Next = next
+	}
+
+	return results, nil
+}
@@ -0,0 +1,140 @@
+package file
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/store"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// interval at which expired records are removed while watched
+	expiryInterval = time.Second
+)
+
+type fileWatcher struct {
+	id     string
+	prefix string
+	events chan *store.Event
+	exit   chan bool
+	// overflow is closed once an event is lost
+	overflow chan bool
+	// lost closes overflow once, as writes and expiries can both overflow the watcher
+	lost sync.Once
+	// remove deregisters the watcher from the handle
+	remove func()
+	once   sync.Once
+}
+
+// send queues the event without blocking the store, a watcher too far behind overflows
+func (f *fileWatcher) send(e *store.Event) {
+	select {
+	case <-f.overflow:
+		return
+	default:
+	}
+	select {
+	case f.events <- e:
+	default:
+		f.lost.Do(func() {
+			close(f.overflow)
+		})
+	}
+}
+
+func (f *fileWatcher) Next() (*store.Event, error) {
+	select {
+	case <-f.exit:
+		return nil, store.ErrWatcherStopped
+	case <-f.overflow:
+		return nil, store.ErrWatcherOverflow
+	default:
+	}
+
+	select {
+	case e := <-f.events:
+		return e, nil
+	case <-f.overflow:
+		return nil, store.ErrWatcherOverflow
+	case <-f.exit:
+		return nil, store.ErrWatcherStopped
+	}
+}
+
+func (f *fileWatcher) Stop() {
+	f.once.Do(func() {
+		close(f.exit)
+		f.remove()
+	})
+}
+
+// notify sends the event to every watcher of the record key, it never blocks
+func (fd *fileHandle) notify(t store.EventType, r *store.Record) {
+	e := &store.Event{
+		Type:      t,
+		Record:    r,
+		Timestamp: time.Now(),
+	}
+
+	fd.watchLock.RLock()
+	defer fd.watchLock.RUnlock()
+
+	for _, w := range fd.watchers {
+		if strings.HasPrefix(r.Key, w.prefix) {
+			w.send(e)
+		}
+	}
+}
+
+// sweep removes expired records and notifies watchers until the handle is closed
+func (m *fileStore) sweep(fd *fileHandle) {
+	t := time.NewTicker(expiryInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-fd.exit:
+			return
+		case <-t.C:
+		}
+
+		var expired []string
+
+		if err := fd.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket([]byte(dataBucket))
+			if b == nil {
+				return nil
+			}
+
+			if err := b.ForEach(func(k, v []byte) error {
+				storedRecord := &record{}
+				if err := json.Unmarshal(v, storedRecord); err != nil {
+					return nil
+				}
+				if !storedRecord.ExpiresAt.IsZero() && storedRecord.ExpiresAt.Before(time.Now()) {
+					expired = append(expired, string(k))
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			// keys can't be deleted while iterating
+			for _, k := range expired {
+				if _, _, err := m.remove(tx, k); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			continue
+		}
+
+		for _, k := range expired {
+			fd.notify(store.Expire, &store.Record{Key: k})
+		}
+	}
+}
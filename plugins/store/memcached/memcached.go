@@ -153,6 +153,16 @@ func (m *mkv) List(opts ...store.ListOption) ([]string, error) {
 	return keys, nil
 }
 
+// Batch is not supported by the memcached store
+func (m *mkv) Batch(ops []store.Op, opts ...store.BatchOption) error {
+	return store.ErrNotSupported
+}
+
+// Watch is not supported by the memcached store
+func (m *mkv) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	return nil, store.ErrNotSupported
+}
+
 func (m *mkv) String() string {
 	return "memcached"
 }
@@ -160,6 +160,16 @@ func (m *memoryStore) Init(opts ...store.Option) error {
 	return nil
 }
 
+// Batch is not supported by the memory store
+func (m *memoryStore) Batch(ops []store.Op, opts ...store.BatchOption) error {
+	return store.ErrNotSupported
+}
+
+// Watch is not supported by the memory store
+func (m *memoryStore) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	return nil, store.ErrNotSupported
+}
+
 func (m *memoryStore) String() string {
 	return "memory"
 }
@@ -170,6 +180,11 @@ func (m *memoryStore) Read(key string, opts ...store.ReadOption) ([]*store.Recor
 		o(&readOpts)
 	}
 
+	// indexes and cursors are not supported
+	if len(readOpts.Index) > 0 || len(readOpts.Cursor) > 0 || readOpts.Next != nil {
+		return nil, store.ErrNotSupported
+	}
+
 	prefix := m.prefix(readOpts.Database, readOpts.Table)
 
 	var keys []string
@@ -266,6 +281,11 @@ func (m *memoryStore) List(opts ...store.ListOption) ([]string, error) {
 		o(&listOptions)
 	}
 
+	// cursors are not supported
+	if len(listOptions.Cursor) > 0 || listOptions.Next != nil {
+		return nil, store.ErrNotSupported
+	}
+
 	prefix := m.prefix(listOptions.Database, listOptions.Table)
 	keys := m.list(prefix, listOptions.Limit, listOptions.Offset)
 
@@ -90,6 +90,11 @@ func (s *sqlStore) Read(key string, opts ...store.ReadOption) ([]*store.Record,
 		o(&options)
 	}
 
+	// indexes and cursors are not supported
+	if len(options.Index) > 0 || len(options.Cursor) > 0 || options.Next != nil {
+		return nil, store.ErrNotSupported
+	}
+
 	// TODO: make use of options.Prefix using WHERE key LIKE = ?
 
 	var records []*store.Record
@@ -212,6 +217,16 @@ func (s *sqlStore) configure() error {
 	return s.initDB()
 }
 
+// Batch is not supported by the mysql store
+func (s *sqlStore) Batch(ops []store.Op, opts ...store.BatchOption) error {
+	return store.ErrNotSupported
+}
+
+// Watch is not supported by the mysql store
+func (s *sqlStore) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	return nil, store.ErrNotSupported
+}
+
 func (s *sqlStore) String() string {
 	return "mysql"
 }
@@ -35,6 +35,11 @@ func (r *rkv) Read(key string, opts ...store.ReadOption) ([]*store.Record, error
 		o(&options)
 	}
 
+	// indexes and cursors are not supported
+	if len(options.Index) > 0 || len(options.Cursor) > 0 || options.Next != nil {
+		return nil, store.ErrNotSupported
+	}
+
 	var keys []string
 
 	rkey := fmt.Sprintf("%s%s", options.Table, key)
@@ -116,6 +121,11 @@ func (r *rkv) List(opts ...store.ListOption) ([]string, error) {
 		o(&options)
 	}
 
+	// cursors are not supported
+	if len(options.Cursor) > 0 || options.Next != nil {
+		return nil, store.ErrNotSupported
+	}
+
 	keys, err := r.Client.Keys(r.ctx, "*").Result()
 	if err != nil {
 		return nil, err
@@ -128,6 +138,16 @@ func (r *rkv) Options() store.Options {
 	return r.options
 }
 
+// Batch is not supported by the redis store
+func (r *rkv) Batch(ops []store.Op, opts ...store.BatchOption) error {
+	return store.ErrNotSupported
+}
+
+// Watch is not supported by the redis store
+func (r *rkv) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	return nil, store.ErrNotSupported
+}
+
 func (r *rkv) String() string {
 	return "redis"
 }
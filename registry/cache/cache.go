@@ -32,11 +32,13 @@ type cache struct {
 	registry.Registry
 	opts Options
 
-	// registry cache
+	// registry cache, keyed by domain and service name
 	sync.RWMutex
 	cache   map[string][]*registry.Service
 	ttls    map[string]time.Time
 	watched map[string]bool
+	// revision of the last result watched, to resume the watch from
+	revision uint64
 
 	// used to stop the cache
 	exit chan bool
@@ -114,14 +116,29 @@ func (c *cache) del(service string) {
 	delete(c.ttls, service)
 }
 
-func (c *cache) get(service string) ([]*registry.Service, error) {
+// key returns the cache key of the service in the domain
+func key(domain, service string) string {
+	return domain + "/" + service
+}
+
+// domain returns the domain of a service returned by the registry
+func domain(s *registry.Service) string {
+	if d, ok := s.Metadata[registry.DomainKey]; ok && len(d) > 0 {
+		return d
+	}
+	return registry.DefaultDomain
+}
+
+func (c *cache) get(domain, service string) ([]*registry.Service, error) {
+	k := key(domain, service)
+
 	// read lock
 	c.RLock()
 
 	// check the cache first
-	services := c.cache[service]
+	services := c.cache[k]
 	// get cache ttl
-	ttl := c.ttls[service]
+	ttl := c.ttls[k]
 	// make a copy
 	cp := util.Copy(services)
 
@@ -135,8 +152,8 @@ func (c *cache) get(service string) ([]*registry.Service, error) {
 	// get does the actual request for a service and cache it
 	get := func(service string, cached []*registry.Service) ([]*registry.Service, error) {
 		// ask the registry
-		val, err, _ := c.sg.Do(service, func() (interface{}, error) {
-			return c.Registry.GetService(service)
+		val, err, _ := c.sg.Do(k, func() (interface{}, error) {
+			return c.Registry.GetService(service, registry.GetDomain(domain))
 		})
 		services, _ := val.([]*registry.Service)
 		if err != nil {
@@ -159,14 +176,14 @@ func (c *cache) get(service string) ([]*registry.Service, error) {
 
 		// cache results
 		c.Lock()
-		c.set(service, util.Copy(services))
+		c.set(k, util.Copy(services))
 		c.Unlock()
 
 		return services, nil
 	}
 
 	// watch service if not watched
-	_, ok := c.watched[service]
+	_, ok := c.watched[k]
 
 	// unlock the read lock
 	c.RUnlock()
@@ -176,11 +193,11 @@ func (c *cache) get(service string) ([]*registry.Service, error) {
 		c.Lock()
 
 		// set to watched
-		c.watched[service] = true
+		c.watched[k] = true
 
 		// only kick it off if not running
 		if !c.running {
-			go c.run(service)
+			go c.run(domain, service)
 		}
 
 		c.Unlock()
@@ -203,12 +220,18 @@ func (c *cache) update(res *registry.Result) {
 	c.Lock()
 	defer c.Unlock()
 
+	if res.Revision > c.revision {
+		c.revision = res.Revision
+	}
+
+	k := key(domain(res.Service), res.Service.Name)
+
 	// only save watched services
-	if _, ok := c.watched[res.Service.Name]; !ok {
+	if _, ok := c.watched[k]; !ok {
 		return
 	}
 
-	services, ok := c.cache[res.Service.Name]
+	services, ok := c.cache[k]
 	if !ok {
 		// we're not going to cache anything
 		// unless there was already a lookup
@@ -218,7 +241,7 @@ func (c *cache) update(res *registry.Result) {
 	if len(res.Service.Nodes) == 0 {
 		switch res.Action {
 		case "delete":
-			c.del(res.Service.Name)
+			c.del(k)
 		}
 		return
 	}
@@ -236,7 +259,7 @@ func (c *cache) update(res *registry.Result) {
 	switch res.Action {
 	case "create", "update":
 		if service == nil {
-			c.set(res.Service.Name, append(services, res.Service))
+			c.set(k, append(services, res.Service))
 			return
 		}
 
@@ -255,7 +278,7 @@ func (c *cache) update(res *registry.Result) {
 		}
 
 		services[index] = res.Service
-		c.set(res.Service.Name, services)
+		c.set(k, services)
 	case "delete":
 		if service == nil {
 			return
@@ -281,7 +304,7 @@ func (c *cache) update(res *registry.Result) {
 		if len(nodes) > 0 {
 			service.Nodes = nodes
 			services[index] = service
-			c.set(service.Name, services)
+			c.set(k, services)
 			return
 		}
 
@@ -290,7 +313,7 @@ func (c *cache) update(res *registry.Result) {
 		// only have one thing to delete
 		// nuke the thing
 		if len(services) == 1 {
-			c.del(service.Name)
+			c.del(k)
 			return
 		}
 
@@ -304,19 +327,19 @@ func (c *cache) update(res *registry.Result) {
 		}
 
 		// save
-		c.set(service.Name, srvs)
+		c.set(k, srvs)
 	case "override":
 		if service == nil {
 			return
 		}
 
-		c.del(service.Name)
+		c.del(k)
 	}
 }
 
 // run starts the cache watcher loop
 // it creates a new watcher if there's a problem
-func (c *cache) run(service string) {
+func (c *cache) run(domain, service string) {
 	c.Lock()
 	c.running = true
 	c.Unlock()
@@ -341,8 +364,32 @@ func (c *cache) run(service string) {
 		j := rand.Int63n(100)
 		time.Sleep(time.Duration(j) * time.Millisecond)
 
+		// resume from the last result seen, or start from what's registered
+		opts := []registry.WatchOption{
+			registry.WatchService(service),
+			registry.WatchDomain(domain),
+		}
+		c.RLock()
+		rev := c.revision
+		c.RUnlock()
+		if rev > 0 {
+			opts = append(opts, registry.WatchRevision(rev))
+		} else {
+			opts = append(opts, registry.WatchSnapshot())
+		}
+
 		// create new watcher
-		w, err := c.Registry.Watch(registry.WatchService(service))
+		w, err := c.Registry.Watch(opts...)
+		if err == registry.ErrRevisionCompacted {
+			// changes were missed, look the service up again
+			c.Lock()
+			k := key(domain, service)
+			delete(c.cache, k)
+			delete(c.ttls, k)
+			c.revision = 0
+			c.Unlock()
+			continue
+		}
 		if err != nil {
 			if c.quit() {
 				return
@@ -432,8 +479,23 @@ func (c *cache) watch(w registry.Watcher) error {
 }
 
 func (c *cache) GetService(service string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	var options registry.GetOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// queries across domains aren't cached
+	if options.Domain == registry.WildcardDomain {
+		return c.Registry.GetService(service, opts...)
+	}
+
+	domain := options.Domain
+	if len(domain) == 0 {
+		domain = registry.DefaultDomain
+	}
+
 	// get the service
-	services, err := c.get(service)
+	services, err := c.get(domain, service)
 	if err != nil {
 		return nil, err
 	}
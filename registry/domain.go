@@ -0,0 +1,27 @@
+package registry
+
+import "errors"
+
+// ErrWildcardDomain is returned registering a service in the wildcard domain
+var ErrWildcardDomain = errors.New("can't register in the wildcard domain")
+
+// getDomain returns the domain of the options, DefaultDomain if it isn't set
+func getDomain(d string) string {
+	if len(d) == 0 {
+		return DefaultDomain
+	}
+	return d
+}
+
+// matchDomain reports whether a service of the domain is in the domain of the options
+func matchDomain(want, domain string) bool {
+	want = getDomain(want)
+	return want == WildcardDomain || want == getDomain(domain)
+}
+
+// withDomain returns a copy of the service with its domain set in its metadata
+func withDomain(s *Service, domain string) *Service {
+	cp := copyService(s)
+	cp.Metadata[DomainKey] = domain
+	return cp
+}
@@ -0,0 +1,419 @@
+// Package file is a registry keeping services in a directory shared by the
+// processes of a host, letting them discover each other without multicast
+// or a registry daemon
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+)
+
+var (
+	// DefaultDir is the directory services are kept in unless set by Dir or the registry address
+	DefaultDir = filepath.Join(os.TempDir(), "micro", "registry")
+	// DefaultWatchInterval is how often watchers rescan the directory unless set by WatchInterval
+	DefaultWatchInterval = time.Second
+)
+
+const (
+	// noVersion is the directory of the nodes of services without a version,
+	// a version of the same name is escaped by versionDir
+	noVersion = "_"
+	// removeAfter is how long after expiring a node's file is removed, long enough
+	// that the node isn't registered again while the file is being removed
+	removeAfter = time.Minute
+)
+
+// entry is the file of a registered node, at dir/domain/service/version/node.json
+type entry struct {
+	Name      string               `json:"name"`
+	Version   string               `json:"version"`
+	Metadata  map[string]string    `json:"metadata"`
+	Endpoints []*registry.Endpoint `json:"endpoints"`
+	Node      *registry.Node       `json:"node"`
+	// Expires is when the node expires unless registered again, zero if it doesn't
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+func (e *entry) expired() bool {
+	return !e.Expires.IsZero() && time.Now().After(e.Expires)
+}
+
+// stale reports whether the node expired long enough ago for its file to be removed
+func (e *entry) stale() bool {
+	return !e.Expires.IsZero() && time.Since(e.Expires) > removeAfter
+}
+
+// readEntry returns the node of the file
+func readEntry(path string) (*entry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var e *entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+	if e == nil || e.Node == nil {
+		return nil, errors.New("registry file has no node")
+	}
+	return e, nil
+}
+
+type fileRegistry struct {
+	opts     registry.Options
+	dir      string
+	interval time.Duration
+}
+
+// NewRegistry returns a registry keeping services in a directory. Nodes
+// registered with a TTL expire unless registered again, and watchers are
+// told of changes through file notifications.
+func NewRegistry(opts ...registry.Option) registry.Registry {
+	options := registry.Options{
+		Context: context.Background(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	r := &fileRegistry{opts: options}
+	r.configure()
+
+	return r
+}
+
+func (r *fileRegistry) configure() {
+	r.dir = DefaultDir
+	if len(r.opts.Addrs) > 0 && len(r.opts.Addrs[0]) > 0 {
+		r.dir = r.opts.Addrs[0]
+	}
+	if d, ok := r.opts.Context.Value(dirKey{}).(string); ok && len(d) > 0 {
+		r.dir = d
+	}
+
+	r.interval = DefaultWatchInterval
+	if d, ok := r.opts.Context.Value(watchIntervalKey{}).(time.Duration); ok && d > 0 {
+		r.interval = d
+	}
+}
+
+func getDomain(d string) string {
+	if len(d) == 0 {
+		return registry.DefaultDomain
+	}
+	return d
+}
+
+// servicePath is the directory of the service in the domain
+func (r *fileRegistry) servicePath(domain, name string) string {
+	return filepath.Join(r.dir, url.PathEscape(domain), url.PathEscape(name))
+}
+
+// versionDir is the name of the directory of the service version
+func versionDir(version string) string {
+	switch version {
+	case "":
+		return noVersion
+	case noVersion:
+		return "%5F"
+	}
+	return url.PathEscape(version)
+}
+
+// nodePath is the file of the node of the service version
+func (r *fileRegistry) nodePath(domain, name, version, id string) string {
+	return filepath.Join(r.servicePath(domain, name), versionDir(version), url.PathEscape(id)+".json")
+}
+
+// listDir returns the unescaped names of the directories in dir
+func listDir(dir string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+		name, err := url.PathUnescape(f.Name())
+		if err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// domains returns the domains to look in, every domain for the wildcard domain
+func (r *fileRegistry) domains(domain string) ([]string, error) {
+	domain = getDomain(domain)
+	if domain != registry.WildcardDomain {
+		return []string{domain}, nil
+	}
+	return listDir(r.dir)
+}
+
+// writeFile replaces the file at once so readers never see part of it
+func writeFile(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// readService returns the versions of the service in the domain with live nodes,
+// removing the files of nodes which expired a while ago
+func (r *fileRegistry) readService(domain, name string) ([]*registry.Service, error) {
+	dir := r.servicePath(domain, name)
+
+	dirs, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var services []*registry.Service
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		version := ""
+		if d.Name() != noVersion {
+			if version, err = url.PathUnescape(d.Name()); err != nil {
+				continue
+			}
+		}
+
+		vdir := filepath.Join(dir, d.Name())
+		files, err := os.ReadDir(vdir)
+		if err != nil {
+			continue
+		}
+
+		var service *registry.Service
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") || strings.HasPrefix(f.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(vdir, f.Name())
+
+			e, err := readEntry(path)
+			if err != nil {
+				continue
+			}
+
+			if e.expired() {
+				// read the file again before removing it, the node may have been registered again
+				if e.stale() {
+					if e, err := readEntry(path); err == nil && e.stale() {
+						if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+							logger.Debugf("Registry TTL expired for node %s of service %s", e.Node.Id, e.Name)
+						}
+						os.Remove(path)
+					}
+				}
+				continue
+			}
+
+			if service == nil {
+				metadata := make(map[string]string, len(e.Metadata)+1)
+				for k, v := range e.Metadata {
+					metadata[k] = v
+				}
+				metadata[registry.DomainKey] = domain
+
+				service = &registry.Service{
+					Name:      name,
+					Version:   version,
+					Metadata:  metadata,
+					Endpoints: e.Endpoints,
+				}
+			}
+			service.Nodes = append(service.Nodes, e.Node)
+		}
+
+		if service != nil {
+			services = append(services, service)
+		}
+	}
+
+	return services, nil
+}
+
+func (r *fileRegistry) Init(opts ...registry.Option) error {
+	for _, o := range opts {
+		o(&r.opts)
+	}
+	r.configure()
+	return nil
+}
+
+func (r *fileRegistry) Options() registry.Options {
+	return r.opts
+}
+
+func (r *fileRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	var options registry.RegisterOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	domain := getDomain(options.Domain)
+	if domain == registry.WildcardDomain {
+		return registry.ErrWildcardDomain
+	}
+
+	dir := filepath.Join(r.servicePath(domain, s.Name), versionDir(s.Version))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var expires time.Time
+	if options.TTL > 0 {
+		expires = time.Now().Add(options.TTL)
+	}
+
+	for _, n := range s.Nodes {
+		e := &entry{
+			Name:      s.Name,
+			Version:   s.Version,
+			Metadata:  s.Metadata,
+			Endpoints: s.Endpoints,
+			Node:      n,
+			Expires:   expires,
+		}
+		if err := writeFile(r.nodePath(domain, s.Name, s.Version, n.Id), e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *fileRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	var options registry.DeregisterOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	domain := getDomain(options.Domain)
+
+	for _, n := range s.Nodes {
+		err := os.Remove(r.nodePath(domain, s.Name, s.Version, n.Id))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// remove the directories left empty, which fails while they aren't
+	dir := r.servicePath(domain, s.Name)
+	os.Remove(filepath.Join(dir, versionDir(s.Version)))
+	os.Remove(dir)
+
+	return nil
+}
+
+func (r *fileRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	var options registry.GetOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	domains, err := r.domains(options.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []*registry.Service
+	for _, domain := range domains {
+		srvs, err := r.readService(domain, name)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, srvs...)
+	}
+
+	if len(services) == 0 {
+		return nil, registry.ErrNotFound
+	}
+
+	return services, nil
+}
+
+func (r *fileRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	var options registry.ListOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	domains, err := r.domains(options.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []*registry.Service
+	for _, domain := range domains {
+		names, err := listDir(filepath.Join(r.dir, url.PathEscape(domain)))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			srvs, err := r.readService(domain, name)
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, srvs...)
+		}
+	}
+
+	return services, nil
+}
+
+func (r *fileRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	var wo registry.WatchOptions
+	for _, o := range opts {
+		o(&wo)
+	}
+	return newWatcher(r, wo)
+}
+
+func (r *fileRegistry) String() string {
+	return "file"
+}
@@ -0,0 +1,218 @@
+package file
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/registry"
+)
+
+var testService = &registry.Service{
+	Name:    "test.file",
+	Version: "1.0.0",
+	Metadata: map[string]string{
+		"foo": "bar",
+	},
+	Nodes: []*registry.Node{
+		{
+			Id:      "test.file-1",
+			Address: "127.0.0.1:9001",
+		},
+		{
+			Id:      "test.file-2",
+			Address: "127.0.0.1:9002",
+		},
+	},
+}
+
+func TestFileRegistry(t *testing.T) {
+	dir := t.TempDir()
+
+	// two processes sharing the directory
+	r1 := NewRegistry(Dir(dir))
+	r2 := NewRegistry(registry.Addrs(dir))
+
+	if err := r1.Register(testService); err != nil {
+		t.Fatalf("Unexpected register error: %v", err)
+	}
+
+	services, err := r2.GetService("test.file")
+	if err != nil {
+		t.Fatalf("Unexpected error getting service: %v", err)
+	}
+	if len(services) != 1 || len(services[0].Nodes) != 2 {
+		t.Fatalf("Expected 1 service with 2 nodes, got %+v", services)
+	}
+	if services[0].Metadata["foo"] != "bar" {
+		t.Fatalf("Expected the service metadata, got %v", services[0].Metadata)
+	}
+
+	if _, err := r2.GetService("test.file", registry.GetDomain("staging")); err != registry.ErrNotFound {
+		t.Fatalf("Expected error: %v, got: %v", registry.ErrNotFound, err)
+	}
+
+	list, err := r2.ListServices(registry.ListDomain(registry.WildcardDomain))
+	if err != nil {
+		t.Fatalf("Unexpected error listing services: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("Expected 1 service, got %d", len(list))
+	}
+
+	if err := r1.Deregister(testService); err != nil {
+		t.Fatalf("Unexpected deregister error: %v", err)
+	}
+	if _, err := r2.GetService("test.file"); err != registry.ErrNotFound {
+		t.Fatalf("Expected error: %v, got: %v", registry.ErrNotFound, err)
+	}
+}
+
+func TestFileRegistryTTL(t *testing.T) {
+	r := NewRegistry(Dir(t.TempDir()))
+
+	if err := r.Register(testService, registry.RegisterTTL(time.Millisecond*50)); err != nil {
+		t.Fatalf("Unexpected register error: %v", err)
+	}
+	if _, err := r.GetService("test.file"); err != nil {
+		t.Fatalf("Unexpected error getting service: %v", err)
+	}
+
+	time.Sleep(time.Millisecond * 100)
+
+	if _, err := r.GetService("test.file"); err != registry.ErrNotFound {
+		t.Fatalf("Expected error: %v, got: %v", registry.ErrNotFound, err)
+	}
+}
+
+func TestFileRegistryWatch(t *testing.T) {
+	dir := t.TempDir()
+	r1 := NewRegistry(Dir(dir), WatchInterval(time.Millisecond*50))
+	r2 := NewRegistry(Dir(dir))
+
+	w, err := r1.Watch(registry.WatchService("test.file"))
+	if err != nil {
+		t.Fatalf("Unexpected watch error: %v", err)
+	}
+	defer w.Stop()
+
+	results := make(chan *registry.Result, 10)
+	go func() {
+		for {
+			r, err := w.Next()
+			if err != nil {
+				close(results)
+				return
+			}
+			results <- r
+		}
+	}()
+
+	// apply the results until the nodes watched are as expected, as nodes
+	// written one after the other may be seen in more than one result
+	nodes := make(map[string]bool)
+	wait := func(want int) {
+		timeout := time.After(time.Second * 2)
+		for len(nodes) != want {
+			select {
+			case r, ok := <-results:
+				if !ok {
+					t.Fatal("Watcher stopped")
+				}
+				for _, n := range r.Service.Nodes {
+					if r.Action == "delete" {
+						delete(nodes, n.Id)
+					} else {
+						nodes[n.Id] = true
+					}
+				}
+			case <-timeout:
+				t.Fatalf("Timed out waiting for %d nodes, got %d", want, len(nodes))
+			}
+		}
+	}
+
+	if err := r2.Register(testService, registry.RegisterTTL(time.Millisecond*300)); err != nil {
+		t.Fatalf("Unexpected register error: %v", err)
+	}
+	wait(2)
+
+	// one node is deregistered, then the other expires
+	if err := r2.Deregister(&registry.Service{
+		Name:    testService.Name,
+		Version: testService.Version,
+		Nodes:   testService.Nodes[:1],
+	}); err != nil {
+		t.Fatalf("Unexpected deregister error: %v", err)
+	}
+	wait(1)
+	wait(0)
+}
+
+func TestFileRegistryNoVersion(t *testing.T) {
+	r := NewRegistry(Dir(t.TempDir()))
+
+	for _, version := range []string{"", "_"} {
+		if err := r.Register(&registry.Service{
+			Name:    "test.file",
+			Version: version,
+			Nodes:   []*registry.Node{{Id: "node-" + version, Address: "127.0.0.1:9001"}},
+		}); err != nil {
+			t.Fatalf("Unexpected register error: %v", err)
+		}
+	}
+
+	services, err := r.GetService("test.file")
+	if err != nil {
+		t.Fatalf("Unexpected error getting service: %v", err)
+	}
+	versions := make(map[string]string)
+	for _, s := range services {
+		if len(s.Nodes) != 1 {
+			t.Fatalf("Expected 1 node of version %q, got %d", s.Version, len(s.Nodes))
+		}
+		versions[s.Version] = s.Nodes[0].Id
+	}
+	if len(versions) != 2 || versions[""] != "node-" || versions["_"] != "node-_" {
+		t.Fatalf("Expected the versions \"\" and \"_\", got %v", versions)
+	}
+}
+
+func TestFileRegistryExpiredRemoval(t *testing.T) {
+	reg := NewRegistry(Dir(t.TempDir()))
+	r := reg.(*fileRegistry)
+
+	node := testService.Nodes[0]
+	path := r.nodePath(registry.DefaultDomain, testService.Name, testService.Version, node.Id)
+	if err := reg.Register(&registry.Service{
+		Name:    testService.Name,
+		Version: testService.Version,
+		Nodes:   []*registry.Node{node},
+	}); err != nil {
+		t.Fatalf("Unexpected register error: %v", err)
+	}
+
+	// a node which just expired may be registered again, its file is kept
+	e := &entry{Name: testService.Name, Version: testService.Version, Node: node, Expires: time.Now().Add(-time.Second)}
+	if err := writeFile(path, e); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.GetService(testService.Name); err != registry.ErrNotFound {
+		t.Fatalf("Expected error: %v, got: %v", registry.ErrNotFound, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected the file of the node to be kept, got %v", err)
+	}
+
+	// one which expired long ago is removed
+	e.Expires = time.Now().Add(-removeAfter * 2)
+	if err := writeFile(path, e); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.GetService(testService.Name); err != registry.ErrNotFound {
+		t.Fatalf("Expected error: %v, got: %v", registry.ErrNotFound, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected the file of the node to be removed, got %v", err)
+	}
+}
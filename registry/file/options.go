@@ -0,0 +1,34 @@
+package file
+
+import (
+	"context"
+	"time"
+
+	"go-micro.dev/v4/registry"
+)
+
+type dirKey struct{}
+
+type watchIntervalKey struct{}
+
+// Dir sets the directory the services are kept in, processes sharing it
+// discover each other. It defaults to the first registry address, then to
+// a directory in the system temp dir.
+func Dir(dir string) registry.Option {
+	return setOption(dirKey{}, dir)
+}
+
+// WatchInterval sets how often watchers rescan the directory for expired
+// nodes and changes the file notifications missed
+func WatchInterval(d time.Duration) registry.Option {
+	return setOption(watchIntervalKey{}, d)
+}
+
+func setOption(k, v interface{}) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
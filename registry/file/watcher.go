@@ -0,0 +1,268 @@
+package file
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go-micro.dev/v4/registry"
+)
+
+// watcher diffs the services of the directory when notified of a change to
+// the files, or on an interval to catch nodes expiring
+type watcher struct {
+	r  *fileRegistry
+	wo registry.WatchOptions
+
+	fw     *fsnotify.Watcher
+	ticker *time.Ticker
+	exit   chan bool
+	once   sync.Once
+
+	// known versions of the services by service key and version
+	known map[string]map[string]*registry.Service
+	// results waiting for Next
+	results []*registry.Result
+}
+
+func newWatcher(r *fileRegistry, wo registry.WatchOptions) (*watcher, error) {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &watcher{
+		r:      r,
+		wo:     wo,
+		fw:     fw,
+		ticker: time.NewTicker(r.interval),
+		exit:   make(chan bool),
+		known:  make(map[string]map[string]*registry.Service),
+	}
+
+	// watch the directories before reading them to not miss a change in between
+	w.add(r.dir)
+	w.rescan()
+
+	// only changes after the watch starts are sent unless a snapshot is asked for
+	if !wo.Snapshot {
+		w.results = nil
+	}
+
+	return w, nil
+}
+
+func serviceKey(domain, name string) string {
+	return domain + "/" + name
+}
+
+// add watches the directory and those below it, down to the version directories
+func (w *watcher) add(dir string) {
+	depth := 0
+	if rel, err := filepath.Rel(w.r.dir, dir); err == nil && rel != "." {
+		depth = len(strings.Split(rel, string(filepath.Separator)))
+	}
+	if depth > 3 {
+		return
+	}
+
+	w.fw.Add(dir)
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			w.add(filepath.Join(dir, f.Name()))
+		}
+	}
+}
+
+// matches reports whether the service of the domain is one the watcher asked for
+func (w *watcher) matches(domain, name string) bool {
+	if len(w.wo.Service) > 0 && w.wo.Service != name {
+		return false
+	}
+	want := getDomain(w.wo.Domain)
+	return want == registry.WildcardDomain || want == domain
+}
+
+// changed refreshes the service of the changed file, or everything
+// for a change outside of a service directory
+func (w *watcher) changed(path string) {
+	rel, err := filepath.Rel(w.r.dir, path)
+	if err != nil {
+		return
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) < 2 {
+		w.rescan()
+		return
+	}
+
+	domain, err := url.PathUnescape(parts[0])
+	if err != nil {
+		return
+	}
+	name, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return
+	}
+	w.refresh(domain, name)
+}
+
+// rescan refreshes every service the watcher asked for
+func (w *watcher) rescan() {
+	seen := make(map[string]bool)
+
+	domains, _ := w.r.domains(w.wo.Domain)
+	for _, domain := range domains {
+		names, _ := listDir(filepath.Join(w.r.dir, url.PathEscape(domain)))
+		for _, name := range names {
+			seen[serviceKey(domain, name)] = true
+			w.refresh(domain, name)
+		}
+	}
+
+	// services whose directories are gone
+	for key, versions := range w.known {
+		if seen[key] {
+			continue
+		}
+		for _, s := range versions {
+			w.refresh(s.Metadata[registry.DomainKey], s.Name)
+			break
+		}
+	}
+}
+
+// refresh reads the service and queues the changes since it was last read
+func (w *watcher) refresh(domain, name string) {
+	if !w.matches(domain, name) {
+		return
+	}
+
+	key := serviceKey(domain, name)
+	services, _ := w.r.readService(domain, name)
+
+	current := make(map[string]*registry.Service, len(services))
+	for _, s := range services {
+		current[s.Version] = s
+	}
+	known := w.known[key]
+
+	for version, s := range current {
+		old, ok := known[version]
+		if !ok {
+			w.results = append(w.results, &registry.Result{Action: "create", Service: s})
+			continue
+		}
+
+		// nodes gone from the version
+		var removed []*registry.Node
+		for _, n := range old.Nodes {
+			if !hasNode(s.Nodes, n.Id) {
+				removed = append(removed, n)
+			}
+		}
+		if len(removed) > 0 {
+			w.results = append(w.results, &registry.Result{Action: "delete", Service: withNodes(old, removed)})
+		}
+
+		// nodes added or changed, or the version itself changed
+		var updated bool
+		for _, n := range s.Nodes {
+			if i := indexNode(old.Nodes, n.Id); i < 0 || !reflect.DeepEqual(old.Nodes[i], n) {
+				updated = true
+				break
+			}
+		}
+		if updated || !reflect.DeepEqual(old.Metadata, s.Metadata) || !reflect.DeepEqual(old.Endpoints, s.Endpoints) {
+			w.results = append(w.results, &registry.Result{Action: "update", Service: s})
+		}
+	}
+
+	// versions without nodes left
+	for version, old := range known {
+		if _, ok := current[version]; !ok {
+			w.results = append(w.results, &registry.Result{Action: "delete", Service: old})
+		}
+	}
+
+	if len(current) == 0 {
+		delete(w.known, key)
+	} else {
+		w.known[key] = current
+	}
+}
+
+func indexNode(nodes []*registry.Node, id string) int {
+	for i, n := range nodes {
+		if n.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasNode(nodes []*registry.Node, id string) bool {
+	return indexNode(nodes, id) >= 0
+}
+
+// withNodes returns a copy of the service with the nodes
+func withNodes(s *registry.Service, nodes []*registry.Node) *registry.Service {
+	cp := *s
+	cp.Nodes = nodes
+	return &cp
+}
+
+func (w *watcher) Next() (*registry.Result, error) {
+	for {
+		if len(w.results) > 0 {
+			r := w.results[0]
+			w.results = w.results[1:]
+			return r, nil
+		}
+
+		select {
+		case ev, ok := <-w.fw.Events:
+			if !ok {
+				return nil, registry.ErrWatcherStopped
+			}
+			// watch new directories, they may already have nodes
+			if ev.Op&fsnotify.Create == fsnotify.Create {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					w.add(ev.Name)
+				}
+			}
+			w.changed(ev.Name)
+		case err, ok := <-w.fw.Errors:
+			if !ok {
+				return nil, registry.ErrWatcherStopped
+			}
+			return nil, err
+		case <-w.ticker.C:
+			w.rescan()
+		case <-w.exit:
+			return nil, registry.ErrWatcherStopped
+		}
+	}
+}
+
+func (w *watcher) Stop() {
+	w.once.Do(func() {
+		close(w.exit)
+		w.ticker.Stop()
+		w.fw.Close()
+	})
+}
@@ -0,0 +1,347 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/logger"
+)
+
+const (
+	// HealthKey is the node metadata key set to Unhealthy while a node fails its check
+	HealthKey = "health"
+	// Unhealthy marks a node failing its check, selectors skip it
+	Unhealthy = "unhealthy"
+
+	// CheckHTTP expects a 2xx response to a GET of the target url
+	CheckHTTP = "http"
+	// CheckTCP expects to connect to the target address
+	CheckTCP = "tcp"
+	// CheckRPC calls Debug.Health on the node, its probe is added by the debug handler
+	CheckRPC = "rpc"
+)
+
+var (
+	// DefaultCheckInterval is how often a node is checked unless set in its check
+	DefaultCheckInterval = time.Second * 10
+	// DefaultCheckTimeout is how long a probe may take unless set in its check
+	DefaultCheckTimeout = time.Second * 5
+	// DefaultCheckThreshold is the consecutive failures marking a node unhealthy unless set in its check
+	DefaultCheckThreshold = 3
+)
+
+// Check is the health check of a node, run by the registry the node is registered with
+type Check struct {
+	// Type of the probe, such as http, tcp or rpc
+	Type string `json:"type"`
+	// Target is the url of http checks, tcp and rpc checks default to the node address
+	Target string `json:"target,omitempty"`
+	// Interval between checks
+	Interval time.Duration `json:"interval,omitempty"`
+	// Timeout of a probe
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Threshold is the consecutive failures marking the node unhealthy
+	Threshold int `json:"threshold,omitempty"`
+	// DeregisterAfter deregisters a node unhealthy for longer, 0 keeps it
+	DeregisterAfter time.Duration `json:"deregister_after,omitempty"`
+}
+
+// Probe checks the health of a node of the service, returning an error if it is unhealthy
+type Probe func(ctx context.Context, service string, node *Node, check *Check) error
+
+var (
+	probeMtx sync.RWMutex
+	probes   = map[string]Probe{
+		CheckHTTP: httpProbe,
+		CheckTCP:  tcpProbe,
+	}
+)
+
+// RegisterProbe sets the probe run for checks of the type
+func RegisterProbe(typ string, p Probe) {
+	probeMtx.Lock()
+	defer probeMtx.Unlock()
+	probes[typ] = p
+}
+
+func httpProbe(ctx context.Context, service string, node *Node, check *Check) error {
+	target := check.Target
+	if len(target) == 0 {
+		target = "http://" + node.Address
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("health check returned %v", rsp.Status)
+	}
+	return nil
+}
+
+func tcpProbe(ctx context.Context, service string, node *Node, check *Check) error {
+	target := check.Target
+	if len(target) == 0 {
+		target = node.Address
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// copyNode returns a copy of the node and its metadata
+func copyNode(n *Node) *Node {
+	metadata := make(map[string]string, len(n.Metadata))
+	for k, v := range n.Metadata {
+		metadata[k] = v
+	}
+	return &Node{
+		Id:       n.Id,
+		Address:  n.Address,
+		Metadata: metadata,
+		Check:    n.Check,
+	}
+}
+
+// nodeHealth is the state of a node being checked
+type nodeHealth struct {
+	domain string
+	// service of the node, without its nodes
+	service *Service
+	node    *Node
+	check   Check
+	exit    chan bool
+
+	fails int
+	// unhealthy is when the node became unhealthy, zero while healthy
+	unhealthy time.Time
+	// seen is when the node was last added, nodes not seen expire
+	seen time.Time
+}
+
+// checker runs the checks of nodes, reporting changes to their health
+type checker struct {
+	// changed is called with the service of a node which became healthy or unhealthy
+	changed func(domain string, s *Service)
+	// expiry stops checking nodes which haven't been added for longer, 0 never expires
+	expiry time.Duration
+
+	sync.RWMutex
+	nodes map[string]*nodeHealth
+}
+
+func newChecker(changed func(domain string, s *Service), expiry time.Duration) *checker {
+	return &checker{
+		changed: changed,
+		expiry:  expiry,
+		nodes:   make(map[string]*nodeHealth),
+	}
+}
+
+func checkKey(domain, service, id string) string {
+	return domain + "/" + service + "/" + id
+}
+
+// add starts checking the nodes of the service in the domain which have a check
+func (c *checker) add(domain string, s *Service) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, n := range s.Nodes {
+		if n.Check == nil {
+			continue
+		}
+
+		key := checkKey(domain, s.Name, n.Id)
+		if h, ok := c.nodes[key]; ok {
+			h.seen = time.Now()
+			// keep checking unless the check changed
+			if h.check == *n.Check && h.node.Address == n.Address {
+				continue
+			}
+			close(h.exit)
+		}
+
+		check := *n.Check
+		if check.Interval <= 0 {
+			check.Interval = DefaultCheckInterval
+		}
+		if check.Timeout <= 0 {
+			check.Timeout = DefaultCheckTimeout
+		}
+		if check.Threshold <= 0 {
+			check.Threshold = DefaultCheckThreshold
+		}
+
+		h := &nodeHealth{
+			domain: domain,
+			service: &Service{
+				Name:      s.Name,
+				Version:   s.Version,
+				Metadata:  s.Metadata,
+				Endpoints: s.Endpoints,
+			},
+			node:  copyNode(n),
+			check: *n.Check,
+			exit:  make(chan bool),
+			seen:  time.Now(),
+		}
+		c.nodes[key] = h
+
+		go c.run(h, check)
+	}
+}
+
+// remove stops checking the node
+func (c *checker) remove(domain, service, id string) {
+	c.Lock()
+	defer c.Unlock()
+
+	key := checkKey(domain, service, id)
+	if h, ok := c.nodes[key]; ok {
+		close(h.exit)
+		delete(c.nodes, key)
+	}
+}
+
+// unhealthy returns when the node became unhealthy, zero if it is healthy or not checked
+func (c *checker) unhealthy(domain, service, id string) time.Time {
+	c.RLock()
+	defer c.RUnlock()
+
+	if h, ok := c.nodes[checkKey(domain, service, id)]; ok {
+		return h.unhealthy
+	}
+	return time.Time{}
+}
+
+// mark sets the health of the nodes in the metadata of the service in the domain
+func (c *checker) mark(domain string, s *Service) {
+	for _, n := range s.Nodes {
+		if c.unhealthy(domain, s.Name, n.Id).IsZero() {
+			if n.Metadata[HealthKey] == Unhealthy {
+				delete(n.Metadata, HealthKey)
+			}
+			continue
+		}
+		if n.Metadata == nil {
+			n.Metadata = make(map[string]string)
+		}
+		n.Metadata[HealthKey] = Unhealthy
+	}
+}
+
+// stop stops every check
+func (c *checker) stop() {
+	c.Lock()
+	defer c.Unlock()
+
+	for key, h := range c.nodes {
+		close(h.exit)
+		delete(c.nodes, key)
+	}
+}
+
+func (c *checker) run(h *nodeHealth, check Check) {
+	t := time.NewTicker(check.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-h.exit:
+			return
+		case <-t.C:
+		}
+
+		probeMtx.RLock()
+		probe, ok := probes[check.Type]
+		probeMtx.RUnlock()
+
+		var err error
+		if ok {
+			ctx, cancel := context.WithTimeout(context.Background(), check.Timeout)
+			err = probe(ctx, h.service.Name, h.node, &check)
+			cancel()
+		} else if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+			logger.Debugf("Registry node %s of service %s has a check of unknown type %v, skipping it", h.node.Id, h.service.Name, check.Type)
+		}
+
+		c.Lock()
+		// stopped while probing
+		select {
+		case <-h.exit:
+			c.Unlock()
+			return
+		default:
+		}
+
+		var changed bool
+		switch {
+		case !ok:
+			// the probe of the type isn't registered in this process, e.g. CheckRPC
+			// without importing debug/handler, so the node's health is left as it is
+		case err != nil:
+			h.fails++
+			if h.fails >= check.Threshold && h.unhealthy.IsZero() {
+				h.unhealthy = time.Now()
+				changed = true
+				if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+					logger.Debugf("Registry node %s of service %s is unhealthy: %v", h.node.Id, h.service.Name, err)
+				}
+			}
+		default:
+			h.fails = 0
+			if !h.unhealthy.IsZero() {
+				h.unhealthy = time.Time{}
+				changed = true
+				if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+					logger.Debugf("Registry node %s of service %s is healthy", h.node.Id, h.service.Name)
+				}
+			}
+		}
+
+		// stop checking nodes which are no longer seen
+		expired := c.expiry > 0 && time.Since(h.seen) > c.expiry
+		if expired {
+			close(h.exit)
+			delete(c.nodes, checkKey(h.domain, h.service.Name, h.node.Id))
+		}
+
+		var service *Service
+		if changed && !expired && c.changed != nil {
+			service = &Service{
+				Name:      h.service.Name,
+				Version:   h.service.Version,
+				Metadata:  h.service.Metadata,
+				Endpoints: h.service.Endpoints,
+				Nodes:     []*Node{copyNode(h.node)},
+			}
+			if !h.unhealthy.IsZero() {
+				service.Nodes[0].Metadata[HealthKey] = Unhealthy
+			}
+		}
+		c.Unlock()
+
+		if service != nil {
+			c.changed(h.domain, service)
+		}
+		if expired {
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package registry
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMemoryRegistryHealthCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	m := NewMemoryRegistry()
+	w, err := m.Watch(WatchService("checked"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	service := &Service{
+		Name:    "checked",
+		Version: "1.0.0",
+		Nodes: []*Node{
+			{
+				Id:      "checked-1",
+				Address: l.Addr().String(),
+				Check: &Check{
+					Type:            CheckTCP,
+					Interval:        time.Millisecond * 20,
+					Threshold:       2,
+					DeregisterAfter: time.Millisecond * 100,
+				},
+			},
+		},
+	}
+	if err := m.Register(service); err != nil {
+		t.Fatal(err)
+	}
+
+	health := func() string {
+		services, err := m.GetService("checked")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range services {
+			for _, n := range s.Nodes {
+				if n.Metadata[HealthKey] == Unhealthy {
+					return Unhealthy
+				}
+				return "healthy"
+			}
+		}
+		return ""
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	if h := health(); h != "healthy" {
+		t.Fatalf("Expected a healthy node, got %q", h)
+	}
+
+	// the node fails its check once the listener is gone
+	l.Close()
+
+	deadline := time.After(time.Second)
+	for {
+		var r *Result
+		rch := make(chan *Result, 1)
+		go func() {
+			r, _ := w.Next()
+			rch <- r
+		}()
+		select {
+		case r = <-rch:
+		case <-deadline:
+			t.Fatal("Timed out waiting for the node to be marked unhealthy")
+		}
+		if r != nil && r.Action == "update" && len(r.Service.Nodes) > 0 &&
+			r.Service.Nodes[0].Metadata[HealthKey] == Unhealthy {
+			break
+		}
+	}
+
+	if h := health(); h != Unhealthy {
+		t.Fatalf("Expected an unhealthy node, got %q", h)
+	}
+
+	// and is deregistered once unhealthy for longer than DeregisterAfter
+	time.Sleep(ttlPruneTime * 2)
+	if h := health(); h != "" {
+		t.Fatalf("Expected the node to be deregistered, got %q", h)
+	}
+}
+
+func TestMemoryRegistryUnknownCheck(t *testing.T) {
+	m := NewMemoryRegistry()
+	if err := m.Register(&Service{
+		Name:    "unknown",
+		Version: "1.0.0",
+		Nodes: []*Node{
+			{
+				Id:      "unknown-1",
+				Address: "127.0.0.1:1",
+				Check: &Check{
+					Type:      "unregistered",
+					Interval:  time.Millisecond * 10,
+					Threshold: 1,
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a check without a probe in this process is skipped rather than failed
+	time.Sleep(time.Millisecond * 100)
+	services, err := m.GetService("unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || len(services[0].Nodes) != 1 {
+		t.Fatalf("Expected the node to be registered, got %v", services)
+	}
+	if h := services[0].Nodes[0].Metadata[HealthKey]; h == Unhealthy {
+		t.Fatal("Expected the node not to be marked unhealthy")
+	}
+}
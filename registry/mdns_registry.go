@@ -23,6 +23,9 @@ import (
 var (
 	// use a .micro domain rather than .local
 	mdnsDomain = "micro"
+
+	// stop checking the health of nodes not seen for this long
+	mdnsCheckExpiry = time.Minute * 5
 )
 
 type mdnsTxt struct {
@@ -30,6 +33,8 @@ type mdnsTxt struct {
 	Version   string
 	Endpoints []*Endpoint
 	Metadata  map[string]string
+	Check     *Check `json:",omitempty"`
+	Domain    string `json:",omitempty"`
 }
 
 type mdnsEntry struct {
@@ -39,11 +44,15 @@ type mdnsEntry struct {
 
 type mdnsRegistry struct {
 	opts Options
-	// the mdns domain
+	// the mdns domain of the default registry domain
 	domain string
 
 	sync.Mutex
-	services map[string][]*mdnsEntry
+	// services registered by domain and name
+	services map[string]map[string][]*mdnsEntry
+	// zones advertises the domains services are registered in to wildcard
+	// queries of other processes, keyed by domain
+	zones map[string]*mdns.Server
 
 	mtx sync.RWMutex
 
@@ -52,6 +61,9 @@ type mdnsRegistry struct {
 
 	// listener
 	listener chan *mdns.ServiceEntry
+
+	// checker runs the health checks of discovered nodes
+	checker *checker
 }
 
 type mdnsWatcher struct {
@@ -59,8 +71,8 @@ type mdnsWatcher struct {
 	wo   WatchOptions
 	ch   chan *mdns.ServiceEntry
 	exit chan struct{}
-	// the mdns domain
-	domain string
+	// health changes of nodes
+	results chan *Result
 	// the registry
 	registry *mdnsRegistry
 }
@@ -145,12 +157,157 @@ func newRegistry(opts ...Option) Registry {
 		domain = d
 	}
 
-	return &mdnsRegistry{
+	m := &mdnsRegistry{
 		opts:     options,
 		domain:   domain,
-		services: make(map[string][]*mdnsEntry),
+		services: make(map[string]map[string][]*mdnsEntry),
+		zones:    make(map[string]*mdns.Server),
 		watchers: make(map[string]*mdnsWatcher),
 	}
+	m.checker = newChecker(m.healthChanged, mdnsCheckExpiry)
+
+	return m
+}
+
+// zone returns the mdns domain of the registry domain
+func (m *mdnsRegistry) zone(domain string) string {
+	if domain == DefaultDomain {
+		return m.domain
+	}
+	return domain
+}
+
+// domains returns the domains a wildcard query looks in, the default domain, those
+// services were registered in by this process and those advertised by others
+func (m *mdnsRegistry) domains(domain string) ([]string, error) {
+	domain = getDomain(domain)
+	if domain != WildcardDomain {
+		return []string{domain}, nil
+	}
+
+	seen := map[string]bool{DefaultDomain: true}
+	domains := []string{DefaultDomain}
+
+	m.Lock()
+	for d := range m.services {
+		if !seen[d] {
+			seen[d] = true
+			domains = append(domains, d)
+		}
+	}
+	m.Unlock()
+
+	advertised, err := m.listZones()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range advertised {
+		if !seen[d] {
+			seen[d] = true
+			domains = append(domains, d)
+		}
+	}
+
+	return domains, nil
+}
+
+// advertise announces the domain in the default zone, the registry lock must be held
+func (m *mdnsRegistry) advertise(domain string) error {
+	if domain == DefaultDomain || m.zones[domain] != nil {
+		return nil
+	}
+
+	s, err := mdns.NewMDNSService(
+		domain,
+		"_domains",
+		m.domain+".",
+		"",
+		9999,
+		[]net.IP{net.ParseIP("0.0.0.0")},
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	srv, err := mdns.NewServer(&mdns.Config{Zone: &mdns.DNSSDService{MDNSService: s}})
+	if err != nil {
+		return err
+	}
+
+	m.zones[domain] = srv
+	return nil
+}
+
+// listZones queries the domains advertised in the default zone
+func (m *mdnsRegistry) listZones() ([]string, error) {
+	entries := make(chan *mdns.ServiceEntry, 10)
+	done := make(chan bool)
+
+	p := mdns.DefaultParams("_domains")
+	// set context with timeout
+	var cancel context.CancelFunc
+	p.Context, cancel = context.WithTimeout(context.Background(), m.opts.Timeout)
+	defer cancel()
+	// set entries channel
+	p.Entries = entries
+	// set domain
+	p.Domain = m.domain
+
+	var domains []string
+	seen := make(map[string]bool)
+
+	go func() {
+		for {
+			select {
+			case e := <-entries:
+				if e.TTL == 0 {
+					continue
+				}
+				suffix := "." + p.Service + "." + p.Domain + "."
+				if !strings.HasSuffix(e.Name, suffix) {
+					continue
+				}
+				d := strings.TrimSuffix(e.Name, suffix)
+				if !seen[d] {
+					seen[d] = true
+					domains = append(domains, d)
+				}
+			case <-p.Context.Done():
+				close(done)
+				return
+			}
+		}
+	}()
+
+	// execute query
+	if err := mdns.Query(p); err != nil {
+		return nil, err
+	}
+
+	// wait till done
+	<-done
+
+	return domains, nil
+}
+
+// healthChanged lets watchers know the health of a node changed
+func (m *mdnsRegistry) healthChanged(domain string, s *Service) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	for _, w := range m.watchers {
+		if len(w.wo.Service) > 0 && w.wo.Service != s.Name {
+			continue
+		}
+		if !matchDomain(w.wo.Domain, domain) {
+			continue
+		}
+		select {
+		case w.results <- &Result{Action: "update", Service: s}:
+		default:
+		}
+	}
 }
 
 func (m *mdnsRegistry) Init(opts ...Option) error {
@@ -168,13 +325,32 @@ func (m *mdnsRegistry) Register(service *Service, opts ...RegisterOption) error
 	m.Lock()
 	defer m.Unlock()
 
-	entries, ok := m.services[service.Name]
+	var options RegisterOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	domain := getDomain(options.Domain)
+	if domain == WildcardDomain {
+		return ErrWildcardDomain
+	}
+	zone := m.zone(domain)
+
+	if _, ok := m.services[domain]; !ok {
+		// let other processes know to look in the domain
+		if err := m.advertise(domain); err != nil {
+			return err
+		}
+		m.services[domain] = make(map[string][]*mdnsEntry)
+	}
+
+	entries, ok := m.services[domain][service.Name]
 	// first entry, create wildcard used for list queries
 	if !ok {
 		s, err := mdns.NewMDNSService(
 			service.Name,
 			"_services",
-			m.domain+".",
+			zone+".",
 			"",
 			9999,
 			[]net.IP{net.ParseIP("0.0.0.0")},
@@ -220,6 +396,8 @@ func (m *mdnsRegistry) Register(service *Service, opts ...RegisterOption) error
 			Version:   service.Version,
 			Endpoints: service.Endpoints,
 			Metadata:  node.Metadata,
+			Check:     node.Check,
+			Domain:    domain,
 		})
 
 		if err != nil {
@@ -241,7 +419,7 @@ func (m *mdnsRegistry) Register(service *Service, opts ...RegisterOption) error
 		s, err := mdns.NewMDNSService(
 			node.Id,
 			service.Name,
-			m.domain+".",
+			zone+".",
 			"",
 			port,
 			[]net.IP{net.ParseIP(host)},
@@ -264,7 +442,7 @@ func (m *mdnsRegistry) Register(service *Service, opts ...RegisterOption) error
 	}
 
 	// save
-	m.services[service.Name] = entries
+	m.services[domain][service.Name] = entries
 
 	return gerr
 }
@@ -273,10 +451,21 @@ func (m *mdnsRegistry) Deregister(service *Service, opts ...DeregisterOption) er
 	m.Lock()
 	defer m.Unlock()
 
+	var options DeregisterOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	domain := getDomain(options.Domain)
+	services, ok := m.services[domain]
+	if !ok {
+		return nil
+	}
+
 	var newEntries []*mdnsEntry
 
 	// loop existing entries, check if any match, shutdown those that do
-	for _, entry := range m.services[service.Name] {
+	for _, entry := range services[service.Name] {
 		var remove bool
 
 		for _, node := range service.Nodes {
@@ -296,15 +485,47 @@ func (m *mdnsRegistry) Deregister(service *Service, opts ...DeregisterOption) er
 	// last entry is the wildcard for list queries. Remove it.
 	if len(newEntries) == 1 && newEntries[0].id == "*" {
 		newEntries[0].node.Shutdown()
-		delete(m.services, service.Name)
+		delete(services, service.Name)
 	} else {
-		m.services[service.Name] = newEntries
+		services[service.Name] = newEntries
+	}
+
+	if len(services) == 0 {
+		delete(m.services, domain)
+		if srv, ok := m.zones[domain]; ok {
+			srv.Shutdown()
+			delete(m.zones, domain)
+		}
 	}
 
 	return nil
 }
 
 func (m *mdnsRegistry) GetService(service string, opts ...GetOption) ([]*Service, error) {
+	var options GetOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var services []*Service
+	domains, err := m.domains(options.Domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, domain := range domains {
+		srvs, err := m.getService(service, domain)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, srvs...)
+	}
+
+	return services, nil
+}
+
+// getService queries the nodes of the service in the domain
+func (m *mdnsRegistry) getService(service, domain string) ([]*Service, error) {
+	zone := m.zone(domain)
 	serviceMap := make(map[string]*Service)
 	entries := make(chan *mdns.ServiceEntry, 10)
 	done := make(chan bool)
@@ -317,7 +538,7 @@ func (m *mdnsRegistry) GetService(service string, opts ...GetOption) ([]*Service
 	// set entries channel
 	p.Entries = entries
 	// set the domain
-	p.Domain = m.domain
+	p.Domain = zone
 
 	go func() {
 		for {
@@ -327,7 +548,7 @@ func (m *mdnsRegistry) GetService(service string, opts ...GetOption) ([]*Service
 				if p.Service == "_services" {
 					continue
 				}
-				if p.Domain != m.domain {
+				if p.Domain != zone {
 					continue
 				}
 				if e.TTL == 0 {
@@ -339,7 +560,7 @@ func (m *mdnsRegistry) GetService(service string, opts ...GetOption) ([]*Service
 					continue
 				}
 
-				if txt.Service != service {
+				if txt.Service != service || getDomain(txt.Domain) != domain {
 					continue
 				}
 
@@ -348,6 +569,7 @@ func (m *mdnsRegistry) GetService(service string, opts ...GetOption) ([]*Service
 					s = &Service{
 						Name:      txt.Service,
 						Version:   txt.Version,
+						Metadata:  map[string]string{DomainKey: domain},
 						Endpoints: txt.Endpoints,
 					}
 				}
@@ -368,6 +590,7 @@ func (m *mdnsRegistry) GetService(service string, opts ...GetOption) ([]*Service
 					Id:       strings.TrimSuffix(e.Name, "."+p.Service+"."+p.Domain+"."),
 					Address:  net.JoinHostPort(addr, fmt.Sprint(e.Port)),
 					Metadata: txt.Metadata,
+					Check:    txt.Check,
 				})
 
 				serviceMap[txt.Version] = s
@@ -390,6 +613,9 @@ func (m *mdnsRegistry) GetService(service string, opts ...GetOption) ([]*Service
 	services := make([]*Service, 0, len(serviceMap))
 
 	for _, service := range serviceMap {
+		// check the health of the nodes found
+		m.checker.add(domain, service)
+		m.checker.mark(domain, service)
 		services = append(services, service)
 	}
 
@@ -397,6 +623,29 @@ func (m *mdnsRegistry) GetService(service string, opts ...GetOption) ([]*Service
 }
 
 func (m *mdnsRegistry) ListServices(opts ...ListOption) ([]*Service, error) {
+	var options ListOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var services []*Service
+	domains, err := m.domains(options.Domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, domain := range domains {
+		srvs, err := m.listServices(domain)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, srvs...)
+	}
+
+	return services, nil
+}
+
+// listServices queries the names of the services in the domain
+func (m *mdnsRegistry) listServices(domain string) ([]*Service, error) {
 	serviceMap := make(map[string]bool)
 	entries := make(chan *mdns.ServiceEntry, 10)
 	done := make(chan bool)
@@ -409,7 +658,7 @@ func (m *mdnsRegistry) ListServices(opts ...ListOption) ([]*Service, error) {
 	// set entries channel
 	p.Entries = entries
 	// set domain
-	p.Domain = m.domain
+	p.Domain = m.zone(domain)
 
 	var services []*Service
 
@@ -426,7 +675,10 @@ func (m *mdnsRegistry) ListServices(opts ...ListOption) ([]*Service, error) {
 				name := strings.TrimSuffix(e.Name, "."+p.Service+"."+p.Domain+".")
 				if !serviceMap[name] {
 					serviceMap[name] = true
-					services = append(services, &Service{Name: name})
+					services = append(services, &Service{
+						Name:     name,
+						Metadata: map[string]string{DomainKey: domain},
+					})
 				}
 			case <-p.Context.Done():
 				close(done)
@@ -456,8 +708,8 @@ func (m *mdnsRegistry) Watch(opts ...WatchOption) (Watcher, error) {
 		id:       uuid.New().String(),
 		wo:       wo,
 		ch:       make(chan *mdns.ServiceEntry, 32),
+		results:  make(chan *Result, 32),
 		exit:     make(chan struct{}),
-		domain:   m.domain,
 		registry: m,
 	}
 
@@ -563,6 +815,11 @@ func (m *mdnsWatcher) Next() (*Result, error) {
 			if len(m.wo.Service) > 0 && txt.Service != m.wo.Service {
 				continue
 			}
+			// wo.Domain: Only keep services of the domain
+			domain := getDomain(txt.Domain)
+			if !matchDomain(m.wo.Domain, domain) {
+				continue
+			}
 			var action string
 			if e.TTL == 0 {
 				action = "delete"
@@ -573,11 +830,12 @@ func (m *mdnsWatcher) Next() (*Result, error) {
 			service := &Service{
 				Name:      txt.Service,
 				Version:   txt.Version,
+				Metadata:  map[string]string{DomainKey: domain},
 				Endpoints: txt.Endpoints,
 			}
 
 			// skip anything without the domain we care about
-			suffix := fmt.Sprintf(".%s.%s.", service.Name, m.domain)
+			suffix := fmt.Sprintf(".%s.%s.", service.Name, m.registry.zone(domain))
 			if !strings.HasSuffix(e.Name, suffix) {
 				continue
 			}
@@ -595,12 +853,22 @@ func (m *mdnsWatcher) Next() (*Result, error) {
 				Id:       strings.TrimSuffix(e.Name, suffix),
 				Address:  net.JoinHostPort(addr, fmt.Sprint(e.Port)),
 				Metadata: txt.Metadata,
+				Check:    txt.Check,
 			})
 
+			if action == "delete" {
+				m.registry.checker.remove(domain, service.Name, service.Nodes[0].Id)
+			} else {
+				m.registry.checker.add(domain, service)
+				m.registry.checker.mark(domain, service)
+			}
+
 			return &Result{
 				Action:  action,
 				Service: service,
 			}, nil
+		case r := <-m.results:
+			return r, nil
 		case <-m.exit:
 			return nil, ErrWatcherStopped
 		}
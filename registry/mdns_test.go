@@ -339,3 +339,53 @@ func TestWatcher(t *testing.T) {
 		}
 	}
 }
+
+func TestMDNSWildcardDomain(t *testing.T) {
+	// skip test in travis because of sendto: operation not permitted error
+	if travis := os.Getenv("TRAVIS"); travis == "true" {
+		t.Skip()
+	}
+
+	service := &Service{
+		Name:    "test-domains",
+		Version: "1.0.0",
+		Nodes: []*Node{
+			{
+				Id:      "test-domains-1",
+				Address: "10.0.0.1:10001",
+			},
+		},
+	}
+
+	// registered by another process
+	r1 := NewRegistry()
+	if err := r1.Register(service, RegisterDomain("staging")); err != nil {
+		t.Fatal(err)
+	}
+	defer r1.Deregister(service, DeregisterDomain("staging"))
+
+	// which this one finds looking across every domain
+	r2 := NewRegistry()
+	services, err := r2.ListServices(ListDomain(WildcardDomain))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found bool
+	for _, s := range services {
+		if s.Name == service.Name && s.Metadata[DomainKey] == "staging" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Expected %s in the staging domain, got %v", service.Name, services)
+	}
+
+	s, err := r2.GetService(service.Name, GetDomain(WildcardDomain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 1 || len(s[0].Nodes) != 1 {
+		t.Fatalf("Expected the service in the staging domain, got %v", s)
+	}
+}
@@ -10,8 +10,10 @@ import (
 )
 
 var (
-	sendEventTime = 10 * time.Millisecond
-	ttlPruneTime  = time.Second
+	ttlPruneTime = time.Second
+	// watchHistory is the number of results kept to resume watches,
+	// and the results a watcher may fall behind by
+	watchHistory = 1024
 )
 
 type node struct {
@@ -28,12 +30,24 @@ type record struct {
 	Endpoints []*Endpoint
 }
 
+// services are the records of a domain by name and version
+type services map[string]map[string]*record
+
 type memRegistry struct {
 	options Options
 
 	sync.RWMutex
-	records  map[string]map[string]*record
+	// records are the services of each domain
+	records  map[string]services
 	watchers map[string]*memWatcher
+
+	// revision of the last result
+	revision uint64
+	// history of the last results, to resume watches from
+	history []*Result
+
+	// checker runs the health checks of registered nodes
+	checker *checker
 }
 
 func NewMemoryRegistry(opts ...Option) Registry {
@@ -47,14 +61,15 @@ func NewMemoryRegistry(opts ...Option) Registry {
 
 	records := getServiceRecords(options.Context)
 	if records == nil {
-		records = make(map[string]map[string]*record)
+		records = make(services)
 	}
 
 	reg := &memRegistry{
 		options:  options,
-		records:  records,
+		records:  map[string]services{DefaultDomain: records},
 		watchers: make(map[string]*memWatcher),
 	}
+	reg.checker = newChecker(reg.healthChanged, 0)
 
 	go reg.ttlPrune()
 
@@ -69,15 +84,10 @@ func (m *memRegistry) ttlPrune() {
 		select {
 		case <-prune.C:
 			m.Lock()
-			for name, records := range m.records {
-				for version, record := range records {
-					for id, n := range record.Nodes {
-						if n.TTL != 0 && time.Since(n.LastSeen) > n.TTL {
-							if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-								logger.Debugf("Registry TTL expired for node %s of service %s", n.Id, name)
-							}
-							delete(m.records[name][version].Nodes, id)
-						}
+			for domain, records := range m.records {
+				for _, versions := range records {
+					for _, record := range versions {
+						m.prune(domain, record)
 					}
 				}
 			}
@@ -86,25 +96,68 @@ func (m *memRegistry) ttlPrune() {
 	}
 }
 
-func (m *memRegistry) sendEvent(r *Result) {
-	m.RLock()
-	watchers := make([]*memWatcher, 0, len(m.watchers))
-	for _, w := range m.watchers {
-		watchers = append(watchers, w)
+// prune removes the expired and long unhealthy nodes of the record, it is called locked
+func (m *memRegistry) prune(domain string, r *record) {
+	for id, n := range r.Nodes {
+		if n.TTL != 0 && time.Since(n.LastSeen) > n.TTL {
+			if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+				logger.Debugf("Registry TTL expired for node %s of service %s", n.Id, r.Name)
+			}
+			delete(r.Nodes, id)
+			m.checker.remove(domain, r.Name, id)
+			continue
+		}
+
+		// deregister nodes unhealthy for too long
+		if n.Check == nil || n.Check.DeregisterAfter <= 0 {
+			continue
+		}
+		if since := m.checker.unhealthy(domain, r.Name, id); !since.IsZero() && time.Since(since) > n.Check.DeregisterAfter {
+			if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+				logger.Debugf("Registry deregistered unhealthy node %s of service %s", n.Id, r.Name)
+			}
+			delete(r.Nodes, id)
+			m.checker.remove(domain, r.Name, id)
+			m.publish(&Result{Action: "delete", Service: &Service{
+				Name:     r.Name,
+				Version:  r.Version,
+				Metadata: map[string]string{DomainKey: domain},
+				Nodes:    []*Node{copyNode(n.Node)},
+			}})
+		}
 	}
-	m.RUnlock()
+}
 
-	for _, w := range watchers {
+// publish numbers the result and queues it for the watchers, it is called locked
+func (m *memRegistry) publish(r *Result) {
+	m.revision++
+	r.Revision = m.revision
+
+	m.history = append(m.history, r)
+	if len(m.history) > watchHistory {
+		m.history = m.history[len(m.history)-watchHistory:]
+	}
+
+	for id, w := range m.watchers {
 		select {
 		case <-w.exit:
-			m.Lock()
-			delete(m.watchers, w.id)
-			m.Unlock()
+			delete(m.watchers, id)
 		default:
-			select {
-			case w.res <- r:
-			case <-time.After(sendEventTime):
-			}
+			w.push(r)
+		}
+	}
+}
+
+// healthChanged lets watchers know the health of a node changed
+func (m *memRegistry) healthChanged(domain string, s *Service) {
+	m.Lock()
+	defer m.Unlock()
+
+	if record, ok := m.records[domain][s.Name][s.Version]; ok {
+		if _, ok := record.Nodes[s.Nodes[0].Id]; ok {
+			service := recordToService(record, domain)
+			m.checker.mark(domain, service)
+			m.publish(&Result{Action: "update", Service: service})
 		}
 	}
 }
@@ -118,17 +171,23 @@ func (m *memRegistry) Init(opts ...Option) error {
 	m.Lock()
 	defer m.Unlock()
 
+	// preloaded services are added to the default domain
+	if _, ok := m.records[DefaultDomain]; !ok {
+		m.records[DefaultDomain] = make(services)
+	}
+	current := m.records[DefaultDomain]
+
 	records := getServiceRecords(m.options.Context)
 	for name, record := range records {
 		// add a whole new service including all of its versions
-		if _, ok := m.records[name]; !ok {
-			m.records[name] = record
+		if _, ok := current[name]; !ok {
+			current[name] = record
 			continue
 		}
 		// add the versions of the service we dont track yet
 		for version, r := range record {
-			if _, ok := m.records[name][version]; !ok {
-				m.records[name][version] = r
+			if _, ok := current[name][version]; !ok {
+				current[name][version] = r
 				continue
 			}
 		}
@@ -150,56 +209,61 @@ func (m *memRegistry) Register(s *Service, opts ...RegisterOption) error {
 		o(&options)
 	}
 
+	domain := getDomain(options.Domain)
+	if domain == WildcardDomain {
+		return ErrWildcardDomain
+	}
+
 	r := serviceToRecord(s, options.TTL)
 
-	if _, ok := m.records[s.Name]; !ok {
-		m.records[s.Name] = make(map[string]*record)
+	// check the health of the nodes
+	m.checker.add(domain, s)
+
+	if _, ok := m.records[domain]; !ok {
+		m.records[domain] = make(services)
 	}
+	records := m.records[domain]
 
-	if _, ok := m.records[s.Name][s.Version]; !ok {
-		m.records[s.Name][s.Version] = r
+	if _, ok := records[s.Name]; !ok {
+		records[s.Name] = make(map[string]*record)
+	}
+
+	if _, ok := records[s.Name][s.Version]; !ok {
+		records[s.Name][s.Version] = r
 		if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-			logger.Debugf("Registry added new service: %s, version: %s", s.Name, s.Version)
+			logger.Debugf("Registry added new service: %s, version: %s, domain: %s", s.Name, s.Version, domain)
 		}
-		go m.sendEvent(&Result{Action: "update", Service: s})
+		m.publish(&Result{Action: "update", Service: withDomain(s, domain)})
 		return nil
 	}
 
 	addedNodes := false
 	for _, n := range s.Nodes {
-		if _, ok := m.records[s.Name][s.Version].Nodes[n.Id]; !ok {
+		if _, ok := records[s.Name][s.Version].Nodes[n.Id]; !ok {
 			addedNodes = true
-			metadata := make(map[string]string)
-			for k, v := range n.Metadata {
-				metadata[k] = v
-				m.records[s.Name][s.Version].Nodes[n.Id] = &node{
-					Node: &Node{
-						Id:       n.Id,
-						Address:  n.Address,
-						Metadata: metadata,
-					},
-					TTL:      options.TTL,
-					LastSeen: time.Now(),
-				}
+			records[s.Name][s.Version].Nodes[n.Id] = &node{
+				Node:     copyNode(n),
+				TTL:      options.TTL,
+				LastSeen: time.Now(),
 			}
 		}
 	}
 
 	if addedNodes {
 		if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-			logger.Debugf("Registry added new node to service: %s, version: %s", s.Name, s.Version)
+			logger.Debugf("Registry added new node to service: %s, version: %s, domain: %s", s.Name, s.Version, domain)
 		}
-		go m.sendEvent(&Result{Action: "update", Service: s})
+		m.publish(&Result{Action: "update", Service: withDomain(s, domain)})
 		return nil
 	}
 
 	// refresh TTL and timestamp
 	for _, n := range s.Nodes {
 		if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-			logger.Debugf("Updated registration for service: %s, version: %s", s.Name, s.Version)
+			logger.Debugf("Updated registration for service: %s, version: %s, domain: %s", s.Name, s.Version, domain)
 		}
-		m.records[s.Name][s.Version].Nodes[n.Id].TTL = options.TTL
-		m.records[s.Name][s.Version].Nodes[n.Id].LastSeen = time.Now()
+		records[s.Name][s.Version].Nodes[n.Id].TTL = options.TTL
+		records[s.Name][s.Version].Nodes[n.Id].LastSeen = time.Now()
 	}
 
 	return nil
@@ -209,30 +273,46 @@ func (m *memRegistry) Deregister(s *Service, opts ...DeregisterOption) error {
 	m.Lock()
 	defer m.Unlock()
 
-	if _, ok := m.records[s.Name]; ok {
-		if _, ok := m.records[s.Name][s.Version]; ok {
+	var options DeregisterOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	domain := getDomain(options.Domain)
+	records, ok := m.records[domain]
+	if !ok {
+		return nil
+	}
+
+	if _, ok := records[s.Name]; ok {
+		if _, ok := records[s.Name][s.Version]; ok {
 			for _, n := range s.Nodes {
-				if _, ok := m.records[s.Name][s.Version].Nodes[n.Id]; ok {
+				if _, ok := records[s.Name][s.Version].Nodes[n.Id]; ok {
 					if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-						logger.Debugf("Registry removed node from service: %s, version: %s", s.Name, s.Version)
+						logger.Debugf("Registry removed node from service: %s, version: %s, domain: %s", s.Name, s.Version, domain)
 					}
-					delete(m.records[s.Name][s.Version].Nodes, n.Id)
+					delete(records[s.Name][s.Version].Nodes, n.Id)
+					m.checker.remove(domain, s.Name, n.Id)
 				}
 			}
-			if len(m.records[s.Name][s.Version].Nodes) == 0 {
-				delete(m.records[s.Name], s.Version)
+			if len(records[s.Name][s.Version].Nodes) == 0 {
+				delete(records[s.Name], s.Version)
 				if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-					logger.Debugf("Registry removed service: %s, version: %s", s.Name, s.Version)
+					logger.Debugf("Registry removed service: %s, version: %s, domain: %s", s.Name, s.Version, domain)
 				}
 			}
 		}
-		if len(m.records[s.Name]) == 0 {
-			delete(m.records, s.Name)
+		if len(records[s.Name]) == 0 {
+			delete(records, s.Name)
 			if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-				logger.Debugf("Registry removed service: %s", s.Name)
+				logger.Debugf("Registry removed service: %s, domain: %s", s.Name, domain)
 			}
 		}
-		go m.sendEvent(&Result{Action: "delete", Service: s})
+		// keep the default domain so preloaded services can be added
+		if len(records) == 0 && domain != DefaultDomain {
+			delete(m.records, domain)
+		}
+		m.publish(&Result{Action: "delete", Service: withDomain(s, domain)})
 	}
 
 	return nil
@@ -242,16 +322,25 @@ func (m *memRegistry) GetService(name string, opts ...GetOption) ([]*Service, er
 	m.RLock()
 	defer m.RUnlock()
 
-	records, ok := m.records[name]
-	if !ok {
-		return nil, ErrNotFound
+	var options GetOptions
+	for _, o := range opts {
+		o(&options)
 	}
 
-	services := make([]*Service, len(m.records[name]))
-	i := 0
-	for _, record := range records {
-		services[i] = recordToService(record)
-		i++
+	var services []*Service
+	for domain, records := range m.records {
+		if !matchDomain(options.Domain, domain) {
+			continue
+		}
+		for _, record := range records[name] {
+			service := recordToService(record, domain)
+			m.checker.mark(domain, service)
+			services = append(services, service)
+		}
+	}
+
+	if len(services) == 0 {
+		return nil, ErrNotFound
 	}
 
 	return services, nil
@@ -261,10 +350,22 @@ func (m *memRegistry) ListServices(opts ...ListOption) ([]*Service, error) {
 	m.RLock()
 	defer m.RUnlock()
 
+	var options ListOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
 	var services []*Service
-	for _, records := range m.records {
-		for _, record := range records {
-			services = append(services, recordToService(record))
+	for domain, records := range m.records {
+		if !matchDomain(options.Domain, domain) {
+			continue
+		}
+		for _, versions := range records {
+			for _, record := range versions {
+				service := recordToService(record, domain)
+				m.checker.mark(domain, service)
+				services = append(services, service)
+			}
 		}
 	}
 
@@ -278,15 +379,44 @@ func (m *memRegistry) Watch(opts ...WatchOption) (Watcher, error) {
 	}
 
 	w := &memWatcher{
-		exit: make(chan bool),
-		res:  make(chan *Result),
-		id:   uuid.New().String(),
-		wo:   wo,
+		exit:   make(chan bool),
+		notify: make(chan bool, 1),
+		id:     uuid.New().String(),
+		wo:     wo,
 	}
 
 	m.Lock()
+	defer m.Unlock()
+
+	switch {
+	case wo.Snapshot:
+		// the services registered now, then the changes after
+		for domain, records := range m.records {
+			for _, versions := range records {
+				for _, record := range versions {
+					service := recordToService(record, domain)
+					m.checker.mark(domain, service)
+					w.load(&Result{Action: "create", Service: service, Revision: m.revision})
+				}
+			}
+		}
+	case wo.Revision > 0:
+		// replay the changes since the revision, unless some are gone
+		// or the revision is of a registry since restarted
+		if wo.Revision > m.revision {
+			return nil, ErrRevisionCompacted
+		}
+		if len(m.history) > 0 && m.history[0].Revision > wo.Revision+1 {
+			return nil, ErrRevisionCompacted
+		}
+		for _, r := range m.history {
+			if r.Revision > wo.Revision {
+				w.load(r)
+			}
+		}
+	}
+
 	m.watchers[w.id] = w
-	m.Unlock()
 
 	return w, nil
 }
@@ -242,3 +242,192 @@ func TestMemoryRegistryTTLConcurrent(t *testing.T) {
 		}
 	}
 }
+
+func TestMemoryRegistryDomains(t *testing.T) {
+	m := NewMemoryRegistry()
+
+	w, err := m.Watch(WatchDomain("staging"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	for _, domain := range []string{"", "staging"} {
+		for _, service := range testData["foo"] {
+			if err := m.Register(service, RegisterDomain(domain)); err != nil {
+				t.Fatalf("Unexpected register error: %v", err)
+			}
+		}
+	}
+	if err := m.Register(testData["bar"][0], RegisterDomain("staging")); err != nil {
+		t.Fatalf("Unexpected register error: %v", err)
+	}
+	if err := m.Register(testData["bar"][0], RegisterDomain(WildcardDomain)); err != ErrWildcardDomain {
+		t.Fatalf("Expected error: %v, got: %v", ErrWildcardDomain, err)
+	}
+
+	// the watcher only sees the staging domain
+	for i := 0; i < len(testData["foo"])+1; i++ {
+		res, err := w.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := res.Service.Metadata[DomainKey]; d != "staging" {
+			t.Fatalf("Expected an event of the staging domain, got %q", d)
+		}
+	}
+
+	testGet := func(name string, count int, opts ...GetOption) {
+		services, err := m.GetService(name, opts...)
+		if count == 0 {
+			if err != ErrNotFound {
+				t.Fatalf("Expected error: %v, got: %v", ErrNotFound, err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error getting service %s: %v", name, err)
+		}
+		if len(services) != count {
+			t.Fatalf("Expected %d services for %s, got %d", count, name, len(services))
+		}
+	}
+
+	foo := len(testData["foo"])
+	testGet("foo", foo)
+	testGet("foo", foo, GetDomain("staging"))
+	testGet("foo", foo*2, GetDomain(WildcardDomain))
+	testGet("bar", 0)
+	testGet("bar", 1, GetDomain("staging"))
+	testGet("foo", 0, GetDomain("production"))
+
+	testList := func(count int, opts ...ListOption) {
+		services, err := m.ListServices(opts...)
+		if err != nil {
+			t.Fatalf("Unexpected error listing services: %v", err)
+		}
+		if len(services) != count {
+			t.Fatalf("Expected %d services, got %d", count, len(services))
+		}
+	}
+
+	testList(foo)
+	testList(foo+1, ListDomain("staging"))
+	testList(foo*2+1, ListDomain(WildcardDomain))
+
+	// deregistering from one domain leaves the other
+	for _, service := range testData["foo"] {
+		if err := m.Deregister(service, DeregisterDomain("staging")); err != nil {
+			t.Fatalf("Unexpected deregister error: %v", err)
+		}
+	}
+	testGet("foo", 0, GetDomain("staging"))
+	testGet("foo", foo)
+}
+
+func TestMemoryRegistryWatchRevision(t *testing.T) {
+	m := NewMemoryRegistry()
+
+	next := func(w Watcher) *Result {
+		res, err := w.Next()
+		if err != nil {
+			t.Fatalf("Unexpected watch error: %v", err)
+		}
+		return res
+	}
+
+	w, err := m.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foo := testData["foo"]
+	for _, service := range foo {
+		if err := m.Register(service); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var last uint64
+	for range foo {
+		res := next(w)
+		if res.Revision <= last {
+			t.Fatalf("Expected a revision after %d, got %d", last, res.Revision)
+		}
+		last = res.Revision
+	}
+	w.Stop()
+
+	// changes made while not watching are replayed
+	if err := m.Deregister(foo[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register(testData["bar"][0]); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = m.Watch(WatchRevision(last))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := next(w); res.Action != "delete" || res.Service.Name != "foo" || res.Revision != last+1 {
+		t.Fatalf("Expected the delete of foo at %d, got %s of %s at %d", last+1, res.Action, res.Service.Name, res.Revision)
+	}
+	if res := next(w); res.Service.Name != "bar" || res.Revision != last+2 {
+		t.Fatalf("Expected the update of bar at %d, got %s of %s at %d", last+2, res.Action, res.Service.Name, res.Revision)
+	}
+	w.Stop()
+
+	// a snapshot starts with the services registered
+	w, err = m.Watch(WatchSnapshot(), WatchService("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range foo[1:] {
+		if res := next(w); res.Action != "create" || res.Service.Name != "foo" {
+			t.Fatalf("Expected a create of foo, got %s of %s", res.Action, res.Service.Name)
+		}
+	}
+	w.Stop()
+
+	// a revision the registry doesn't have can't be resumed
+	if _, err := m.Watch(WatchRevision(last + 100)); err != ErrRevisionCompacted {
+		t.Fatalf("Expected error: %v, got: %v", ErrRevisionCompacted, err)
+	}
+}
+
+func TestMemoryRegistryWatchLargeSnapshot(t *testing.T) {
+	m := NewMemoryRegistry()
+
+	n := watchHistory + 100
+	for i := 0; i < n; i++ {
+		if err := m.Register(&Service{
+			Name:    "foo",
+			Version: fmt.Sprintf("%d", i),
+			Nodes:   []*Node{{Id: fmt.Sprintf("foo-%d", i), Address: "127.0.0.1:9000"}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the snapshot doesn't overflow the watcher however many services there are
+	w, err := m.Watch(WatchSnapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	for i := 0; i < n; i++ {
+		if _, err := w.Next(); err != nil {
+			t.Fatalf("Unexpected watch error after %d results: %v", i, err)
+		}
+	}
+
+	// changes after it are still queued
+	if err := m.Register(&Service{Name: "bar", Nodes: []*Node{{Id: "bar-1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := w.Next(); err != nil || res.Service.Name != "bar" {
+		t.Fatalf("Expected the create of bar, got %v %v", res, err)
+	}
+}
@@ -33,11 +33,12 @@ func serviceToRecord(s *Service, ttl time.Duration) *record {
 	}
 }
 
-func recordToService(r *record) *Service {
-	metadata := make(map[string]string, len(r.Metadata))
+func recordToService(r *record, domain string) *Service {
+	metadata := make(map[string]string, len(r.Metadata)+1)
 	for k, v := range r.Metadata {
 		metadata[k] = v
 	}
+	metadata[DomainKey] = domain
 
 	endpoints := make([]*Endpoint, len(r.Endpoints))
 	for i, e := range r.Endpoints {
@@ -75,6 +76,7 @@ func recordToService(r *record) *Service {
 			Id:       n.Id,
 			Address:  n.Address,
 			Metadata: metadata,
+			Check:    n.Check,
 		}
 		i++
 	}
@@ -87,3 +89,27 @@ func recordToService(r *record) *Service {
 		Nodes:     nodes,
 	}
 }
+
+// copyService returns a copy of the service, its metadata and nodes
+func copyService(s *Service) *Service {
+	metadata := make(map[string]string, len(s.Metadata))
+	for k, v := range s.Metadata {
+		metadata[k] = v
+	}
+
+	nodes := make([]*Node, len(s.Nodes))
+	for i, n := range s.Nodes {
+		nodes[i] = copyNode(n)
+	}
+
+	endpoints := make([]*Endpoint, len(s.Endpoints))
+	copy(endpoints, s.Endpoints)
+
+	return &Service{
+		Name:      s.Name,
+		Version:   s.Version,
+		Metadata:  metadata,
+		Endpoints: endpoints,
+		Nodes:     nodes,
+	}
+}
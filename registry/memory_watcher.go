@@ -2,23 +2,93 @@ package registry
 
 import (
 	"errors"
+	"sync"
 )
 
 type memWatcher struct {
 	id   string
 	wo   WatchOptions
-	res  chan *Result
 	exit chan bool
+
+	sync.Mutex
+	// results waiting for Next, in revision order
+	queue []*Result
+	// loaded is the number of results at the front of the queue the watch
+	// started with, which don't count towards the queue limit
+	loaded int
+	// notify wakes Next once a result is queued
+	notify chan bool
+	// overflow is set once the queue is full
+	overflow bool
+}
+
+// matches reports whether the result is one the watcher asked for
+func (m *memWatcher) matches(r *Result) bool {
+	if len(m.wo.Service) > 0 && m.wo.Service != r.Service.Name {
+		return false
+	}
+	return matchDomain(m.wo.Domain, r.Service.Metadata[DomainKey])
+}
+
+// load queues a result of the snapshot or history the watch starts with
+func (m *memWatcher) load(r *Result) {
+	if !m.matches(r) {
+		return
+	}
+
+	m.Lock()
+	m.queue = append(m.queue, r)
+	m.loaded++
+	m.Unlock()
+}
+
+// push queues the result, a watcher too far behind overflows
+func (m *memWatcher) push(r *Result) {
+	if !m.matches(r) {
+		return
+	}
+
+	m.Lock()
+	if len(m.queue)-m.loaded >= watchHistory {
+		m.overflow = true
+		m.queue = nil
+		m.loaded = 0
+	} else if !m.overflow {
+		m.queue = append(m.queue, r)
+	}
+	m.Unlock()
+
+	select {
+	case m.notify <- true:
+	default:
+	}
 }
 
 func (m *memWatcher) Next() (*Result, error) {
 	for {
-		select {
-		case r := <-m.res:
-			if len(m.wo.Service) > 0 && m.wo.Service != r.Service.Name {
-				continue
+		m.Lock()
+		if m.overflow {
+			m.Unlock()
+			return nil, ErrWatcherOverflow
+		}
+		if len(m.queue) > 0 {
+			r := m.queue[0]
+			m.queue = m.queue[1:]
+			if m.loaded > 0 {
+				m.loaded--
 			}
-			return r, nil
+			m.Unlock()
+			// results are shared by watchers and the history
+			return &Result{
+				Action:   r.Action,
+				Service:  copyService(r.Service),
+				Revision: r.Revision,
+			}, nil
+		}
+		m.Unlock()
+
+		select {
+		case <-m.notify:
 		case <-m.exit:
 			return nil, errors.New("watcher stopped")
 		}
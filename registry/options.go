@@ -18,6 +18,8 @@ type Options struct {
 
 type RegisterOptions struct {
 	TTL time.Duration
+	// Domain the service is registered in, DefaultDomain if empty
+	Domain string
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -27,20 +29,32 @@ type WatchOptions struct {
 	// 指定要监视那个服务
 	// 如果为空的化，监视所有服务
 	Service string
+	// Domain to watch, DefaultDomain if empty or WildcardDomain for every domain
+	Domain string
+	// Revision resumes the watch after the result of the revision
+	Revision uint64
+	// Snapshot sends the registered services as create results before any change
+	Snapshot bool
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
 }
 
 type DeregisterOptions struct {
+	// Domain the service was registered in, DefaultDomain if empty
+	Domain  string
 	Context context.Context
 }
 
 type GetOptions struct {
+	// Domain to look in, DefaultDomain if empty or WildcardDomain for every domain
+	Domain  string
 	Context context.Context
 }
 
 type ListOptions struct {
+	// Domain to list, DefaultDomain if empty or WildcardDomain for every domain
+	Domain  string
 	Context context.Context
 }
 
@@ -83,6 +97,13 @@ func RegisterContext(ctx context.Context) RegisterOption {
 	}
 }
 
+// RegisterDomain registers the service in the domain
+func RegisterDomain(d string) RegisterOption {
+	return func(o *RegisterOptions) {
+		o.Domain = d
+	}
+}
+
 // Watch a service
 func WatchService(name string) WatchOption {
 	return func(o *WatchOptions) {
@@ -96,24 +117,69 @@ func WatchContext(ctx context.Context) WatchOption {
 	}
 }
 
+// WatchDomain watches the services of the domain, WildcardDomain watches every domain
+func WatchDomain(d string) WatchOption {
+	return func(o *WatchOptions) {
+		o.Domain = d
+	}
+}
+
+// WatchRevision resumes a watch after the result of the revision, replaying
+// the changes missed since. Watch returns ErrRevisionCompacted if the registry
+// no longer has them.
+func WatchRevision(rev uint64) WatchOption {
+	return func(o *WatchOptions) {
+		o.Revision = rev
+	}
+}
+
+// WatchSnapshot sends the services registered when the watch starts as
+// create results before any change
+func WatchSnapshot() WatchOption {
+	return func(o *WatchOptions) {
+		o.Snapshot = true
+	}
+}
+
 func DeregisterContext(ctx context.Context) DeregisterOption {
 	return func(o *DeregisterOptions) {
 		o.Context = ctx
 	}
 }
 
+// DeregisterDomain deregisters the service from the domain
+func DeregisterDomain(d string) DeregisterOption {
+	return func(o *DeregisterOptions) {
+		o.Domain = d
+	}
+}
+
 func GetContext(ctx context.Context) GetOption {
 	return func(o *GetOptions) {
 		o.Context = ctx
 	}
 }
 
+// GetDomain looks up the service in the domain, WildcardDomain looks in every domain
+func GetDomain(d string) GetOption {
+	return func(o *GetOptions) {
+		o.Domain = d
+	}
+}
+
 func ListContext(ctx context.Context) ListOption {
 	return func(o *ListOptions) {
 		o.Context = ctx
 	}
 }
 
+// ListDomain lists the services of the domain, WildcardDomain lists every domain
+func ListDomain(d string) ListOption {
+	return func(o *ListOptions) {
+		o.Domain = d
+	}
+}
+
 type servicesKey struct{}
 
 func getServiceRecords(ctx context.Context) map[string]map[string]*record {
@@ -7,6 +7,15 @@ import (
 	"errors"
 )
 
+const (
+	// DefaultDomain is the domain of services registered without one
+	DefaultDomain = "micro"
+	// WildcardDomain looks up, lists or watches services across every domain
+	WildcardDomain = "*"
+	// DomainKey is the service metadata key set to the domain of services returned by a registry
+	DomainKey = "domain"
+)
+
 var (
 	DefaultRegistry = NewRegistry()
 
@@ -14,6 +23,11 @@ var (
 	ErrNotFound = errors.New("service not found")
 	// Watcher stopped error when watcher is stopped
 	ErrWatcherStopped = errors.New("watcher stopped")
+	// ErrRevisionCompacted is returned watching from a revision the registry no longer has
+	ErrRevisionCompacted = errors.New("watch revision compacted")
+	// ErrWatcherOverflow is returned by a watcher which fell too far behind, watch
+	// again from the last revision received to catch up
+	ErrWatcherOverflow = errors.New("watcher overflow")
 )
 
 // registry (注册中心)为服务发现提供了一个接口，并在不同的实现上提供了一个抽象
@@ -43,6 +57,8 @@ type Node struct {
 	Id       string            `json:"id"`
 	Address  string            `json:"address"`
 	Metadata map[string]string `json:"metadata"`
+	// Check is the health check run by the registry, see Check
+	Check *Check `json:"check,omitempty"`
 }
 
 // 服务端点
@@ -0,0 +1,29 @@
+package snapshot
+
+import (
+	"time"
+)
+
+type Options struct {
+	// Path of the snapshot file
+	Path string
+	// SaveInterval is the least time between writes of the snapshot, 0 doesn't write it
+	SaveInterval time.Duration
+}
+
+type Option func(o *Options)
+
+// WithPath sets the snapshot file
+func WithPath(p string) Option {
+	return func(o *Options) {
+		o.Path = p
+	}
+}
+
+// WithSaveInterval sets the least time between writes of the services
+// looked up to the snapshot, 0 only reads it
+func WithSaveInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.SaveInterval = d
+	}
+}
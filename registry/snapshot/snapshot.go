@@ -0,0 +1,339 @@
+// Package snapshot saves the services of a registry to a file, and falls
+// back to them while the registry is unavailable
+package snapshot
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	util "go-micro.dev/v4/util/registry"
+)
+
+var (
+	// DefaultPath is the snapshot file unless set by WithPath
+	DefaultPath = filepath.Join(os.TempDir(), "micro", "registry.snapshot.json")
+	// DefaultSaveInterval is the least time between writes unless set by WithSaveInterval
+	DefaultSaveInterval = time.Second * 30
+)
+
+// snapshot is the file format
+type snapshot struct {
+	Timestamp time.Time           `json:"timestamp"`
+	Services  []*registry.Service `json:"services"`
+}
+
+// Save writes the services to the snapshot file
+func Save(path string, services []*registry.Service) error {
+	b, err := json.MarshalIndent(&snapshot{
+		Timestamp: time.Now(),
+		Services:  services,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// replace the file at once so a crash doesn't leave part of it
+	f, err := os.CreateTemp(dir, ".snapshot-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// Load reads the services of the snapshot file, it may be written by hand
+// to bootstrap a static topology
+func Load(path string) ([]*registry.Service, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	return s.Services, nil
+}
+
+// Export saves every service of the registry to the snapshot file, looking
+// each up as registries may list services without their nodes
+func Export(r registry.Registry, path string, opts ...registry.ListOption) error {
+	list, err := r.ListServices(opts...)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	var services []*registry.Service
+	for _, s := range list {
+		domain := domain(s)
+		key := domain + "/" + s.Name
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		srvs, err := r.GetService(s.Name, registry.GetDomain(domain))
+		if err == registry.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		services = append(services, srvs...)
+	}
+
+	return Save(path, services)
+}
+
+// domain returns the domain of a service returned by a registry
+func domain(s *registry.Service) string {
+	if d := s.Metadata[registry.DomainKey]; len(d) > 0 {
+		return d
+	}
+	return registry.DefaultDomain
+}
+
+// inDomain reports whether the service is in the domain of the options
+func inDomain(s *registry.Service, d string) bool {
+	if len(d) == 0 {
+		d = registry.DefaultDomain
+	}
+	return d == registry.WildcardDomain || d == domain(s)
+}
+
+// Registry is a registry falling back to a snapshot
+type Registry interface {
+	registry.Registry
+	// Flush writes the changes waiting for the save interval, e.g. before the process exits
+	Flush() error
+}
+
+type fallback struct {
+	registry.Registry
+	opts Options
+
+	sync.RWMutex
+	// services of the snapshot by domain and name
+	services map[string][]*registry.Service
+	// timer of the pending write, nil if there is none
+	timer *time.Timer
+	// saved is set once the snapshot has been written
+	saved bool
+
+	// saveMtx stops writes of the snapshot overlapping
+	saveMtx sync.Mutex
+}
+
+// NewRegistry wraps the registry, answering lookups from the snapshot while it
+// errors. Services looked up are saved to the snapshot so the last known
+// topology survives restarts. The first change is saved straight away, later
+// ones at most once per save interval. Processes sharing the snapshot file
+// merge the services they looked up into it.
+func NewRegistry(r registry.Registry, opts ...Option) Registry {
+	options := Options{
+		Path:         DefaultPath,
+		SaveInterval: DefaultSaveInterval,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	f := &fallback{
+		Registry: r,
+		opts:     options,
+		services: make(map[string][]*registry.Service),
+	}
+
+	services, err := Load(options.Path)
+	if err != nil && !os.IsNotExist(err) {
+		if logger.V(logger.WarnLevel, logger.DefaultLogger) {
+			logger.Warnf("Registry snapshot %s not loaded: %v", options.Path, err)
+		}
+	}
+	for _, s := range services {
+		key := domain(s) + "/" + s.Name
+		f.services[key] = append(f.services[key], s)
+	}
+
+	return f
+}
+
+// record keeps the services looked up, scheduling a write if they changed
+func (f *fallback) record(name string, services []*registry.Service) {
+	byDomain := make(map[string][]*registry.Service)
+	for _, s := range services {
+		key := domain(s) + "/" + name
+		byDomain[key] = append(byDomain[key], s)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	var changed bool
+	for key, srvs := range byDomain {
+		if reflect.DeepEqual(f.services[key], srvs) {
+			continue
+		}
+		f.services[key] = util.Copy(srvs)
+		changed = true
+	}
+
+	if changed && f.opts.SaveInterval > 0 && f.timer == nil {
+		delay := f.opts.SaveInterval
+		if !f.saved {
+			delay = 0
+		}
+		f.timer = time.AfterFunc(delay, func() {
+			if err := f.save(); err != nil && logger.V(logger.WarnLevel, logger.DefaultLogger) {
+				logger.Warnf("Registry snapshot %s not saved: %v", f.opts.Path, err)
+			}
+		})
+	}
+}
+
+// save writes the snapshot, keeping the services other processes saved to the file
+func (f *fallback) save() error {
+	f.saveMtx.Lock()
+	defer f.saveMtx.Unlock()
+
+	f.Lock()
+	f.timer = nil
+	f.saved = true
+	f.Unlock()
+
+	saved, err := Load(f.opts.Path)
+	if err != nil && !os.IsNotExist(err) && logger.V(logger.WarnLevel, logger.DefaultLogger) {
+		logger.Warnf("Registry snapshot %s not merged: %v", f.opts.Path, err)
+	}
+	others := make(map[string][]*registry.Service)
+	for _, s := range saved {
+		key := domain(s) + "/" + s.Name
+		others[key] = append(others[key], s)
+	}
+
+	f.Lock()
+	// the services looked up by this process are the most recent
+	for key, srvs := range others {
+		if _, ok := f.services[key]; !ok {
+			f.services[key] = srvs
+		}
+	}
+	var services []*registry.Service
+	for _, srvs := range f.services {
+		services = append(services, srvs...)
+	}
+	f.Unlock()
+
+	return Save(f.opts.Path, services)
+}
+
+func (f *fallback) Flush() error {
+	f.Lock()
+	pending := f.timer != nil
+	if pending {
+		f.timer.Stop()
+	}
+	f.Unlock()
+
+	if !pending {
+		// wait for a write which already started
+		f.saveMtx.Lock()
+		defer f.saveMtx.Unlock()
+		return nil
+	}
+	return f.save()
+}
+
+// lookup returns the snapshot services matching the name, any name if empty, and domain
+func (f *fallback) lookup(name, domain string) []*registry.Service {
+	f.RLock()
+	defer f.RUnlock()
+
+	var services []*registry.Service
+	for _, srvs := range f.services {
+		for _, s := range srvs {
+			if (len(name) == 0 || s.Name == name) && inDomain(s, domain) {
+				services = append(services, s)
+			}
+		}
+	}
+	return util.Copy(services)
+}
+
+func (f *fallback) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	services, err := f.Registry.GetService(name, opts...)
+	if err == nil {
+		f.record(name, services)
+		return services, nil
+	}
+	if err == registry.ErrNotFound {
+		return nil, err
+	}
+
+	var options registry.GetOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	snap := f.lookup(name, options.Domain)
+	if len(snap) == 0 {
+		return nil, err
+	}
+
+	if logger.V(logger.WarnLevel, logger.DefaultLogger) {
+		logger.Warnf("Registry error getting %s, using the snapshot: %v", name, err)
+	}
+	return snap, nil
+}
+
+func (f *fallback) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	services, err := f.Registry.ListServices(opts...)
+	if err == nil {
+		return services, nil
+	}
+
+	var options registry.ListOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	snap := f.lookup("", options.Domain)
+	if len(snap) == 0 {
+		return nil, err
+	}
+
+	if logger.V(logger.WarnLevel, logger.DefaultLogger) {
+		logger.Warnf("Registry error listing services, using the snapshot: %v", err)
+	}
+	return snap, nil
+}
+
+func (f *fallback) String() string {
+	return f.Registry.String()
+}
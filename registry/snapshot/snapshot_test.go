@@ -0,0 +1,180 @@
+package snapshot
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/registry"
+)
+
+var errUnavailable = errors.New("registry unavailable")
+
+// flakyRegistry fails lookups while down
+type flakyRegistry struct {
+	registry.Registry
+	down bool
+}
+
+func (r *flakyRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	if r.down {
+		return nil, errUnavailable
+	}
+	return r.Registry.GetService(name, opts...)
+}
+
+func (r *flakyRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	if r.down {
+		return nil, errUnavailable
+	}
+	return r.Registry.ListServices(opts...)
+}
+
+var testService = &registry.Service{
+	Name:    "test.snapshot",
+	Version: "1.0.0",
+	Nodes: []*registry.Node{
+		{
+			Id:      "test.snapshot-1",
+			Address: "127.0.0.1:9001",
+		},
+	},
+}
+
+func TestExportLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	r := registry.NewMemoryRegistry()
+	if err := r.Register(testService); err != nil {
+		t.Fatal(err)
+	}
+	if err := Export(r, path); err != nil {
+		t.Fatalf("Unexpected export error: %v", err)
+	}
+
+	services, err := Load(path)
+	if err != nil {
+		t.Fatalf("Unexpected load error: %v", err)
+	}
+	if len(services) != 1 || services[0].Name != testService.Name || len(services[0].Nodes) != 1 {
+		t.Fatalf("Expected the exported service, got %+v", services)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	primary := &flakyRegistry{Registry: registry.NewMemoryRegistry()}
+	if err := primary.Register(testService); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRegistry(primary, WithPath(path), WithSaveInterval(time.Millisecond*10))
+	if _, err := r.GetService(testService.Name); err != nil {
+		t.Fatalf("Unexpected error getting service: %v", err)
+	}
+
+	// wait for the snapshot to be written
+	for i := 0; ; i++ {
+		if _, err := Load(path); err == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Timed out waiting for the snapshot")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// a new process falls back to the saved snapshot while the registry is down
+	primary.down = true
+	r = NewRegistry(primary, WithPath(path), WithSaveInterval(0))
+
+	services, err := r.GetService(testService.Name)
+	if err != nil {
+		t.Fatalf("Expected the snapshot, got error: %v", err)
+	}
+	if len(services) != 1 || services[0].Nodes[0].Address != "127.0.0.1:9001" {
+		t.Fatalf("Expected the snapshot service, got %+v", services)
+	}
+
+	if _, err := r.GetService("unknown"); err != errUnavailable {
+		t.Fatalf("Expected error: %v, got: %v", errUnavailable, err)
+	}
+	if _, err := r.GetService(testService.Name, registry.GetDomain("staging")); err != errUnavailable {
+		t.Fatalf("Expected error: %v, got: %v", errUnavailable, err)
+	}
+
+	list, err := r.ListServices()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("Expected 1 service from the snapshot, got %d: %v", len(list), err)
+	}
+}
+
+func TestFallbackShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	other := &registry.Service{
+		Name:    "test.snapshot.other",
+		Version: "1.0.0",
+		Nodes: []*registry.Node{
+			{
+				Id:      "test.snapshot.other-1",
+				Address: "127.0.0.1:9002",
+			},
+		},
+	}
+
+	// two processes sharing the snapshot file look up different services
+	var rs []Registry
+	for _, s := range []*registry.Service{testService, other} {
+		primary := registry.NewMemoryRegistry()
+		if err := primary.Register(s); err != nil {
+			t.Fatal(err)
+		}
+
+		r := NewRegistry(primary, WithPath(path), WithSaveInterval(time.Hour))
+		if _, err := r.GetService(s.Name); err != nil {
+			t.Fatalf("Unexpected error getting service: %v", err)
+		}
+		if err := r.Flush(); err != nil {
+			t.Fatalf("Unexpected flush error: %v", err)
+		}
+		rs = append(rs, r)
+	}
+
+	// changes after the first write wait for the interval unless flushed
+	moved := &registry.Service{
+		Name:    testService.Name,
+		Version: testService.Version,
+		Nodes: []*registry.Node{
+			{
+				Id:      "test.snapshot-1",
+				Address: "127.0.0.1:9003",
+			},
+		},
+	}
+	primary := registry.NewMemoryRegistry()
+	if err := primary.Register(moved); err != nil {
+		t.Fatal(err)
+	}
+	rs[0].(*fallback).Registry = primary
+	if _, err := rs[0].GetService(testService.Name); err != nil {
+		t.Fatalf("Unexpected error getting service: %v", err)
+	}
+	if err := rs[0].Flush(); err != nil {
+		t.Fatalf("Unexpected flush error: %v", err)
+	}
+
+	services, err := Load(path)
+	if err != nil {
+		t.Fatalf("Unexpected load error: %v", err)
+	}
+	addrs := make(map[string]string)
+	for _, s := range services {
+		addrs[s.Name] = s.Nodes[0].Address
+	}
+	if addrs[testService.Name] != "127.0.0.1:9003" || addrs[other.Name] != "127.0.0.1:9002" {
+		t.Fatalf("Expected the services of both processes, got %v", addrs)
+	}
+}
@@ -15,6 +15,9 @@ type Watcher interface {
 type Result struct {
 	Action  string
 	Service *Service
+	// Revision orders the results of the registry, it is 0 if the
+	// registry doesn't number its changes. See WatchRevision.
+	Revision uint64
 }
 
 // EventType defines registry event type
@@ -10,6 +10,7 @@ import (
 type registrySelector struct {
 	so Options
 	rc cache.Cache
+	ol *outliers
 }
 
 func (c *registrySelector) newCache() cache.Cache {
@@ -29,6 +30,7 @@ func (c *registrySelector) Init(opts ...Option) error {
 
 	c.rc.Stop()
 	c.rc = c.newCache()
+	c.ol = newOutliers(c.so.Outlier)
 
 	return nil
 }
@@ -57,6 +59,12 @@ func (c *registrySelector) Select(service string, opts ...SelectOption) (Next, e
 		return nil, err
 	}
 
+	// skip unhealthy nodes
+	services = FilterHealthy()(services)
+
+	// skip the nodes ejected for failing
+	services = c.ol.filter(service, services)
+
 	// apply the filters
 	for _, filter := range sopts.Filters {
 		services = filter(services)
@@ -71,9 +79,26 @@ func (c *registrySelector) Select(service string, opts ...SelectOption) (Next, e
 }
 
 func (c *registrySelector) Mark(service string, node *registry.Node, err error) {
+	// errors returned by the service, e.g. bad requests, don't slow the node
+	if err != nil && isFailure(err) {
+		stats.penalize(node)
+	}
+	c.ol.mark(service, node, err)
+}
+
+// Track counts the request in flight and times it for the strategies weighing nodes by load
+func (c *registrySelector) Track(service string, node *registry.Node) func(err error) {
+	stats.start(node)
+	start := time.Now()
+
+	return func(err error) {
+		stats.done(node, time.Since(start))
+		c.Mark(service, node, err)
+	}
 }
 
 func (c *registrySelector) Reset(service string) {
+	c.ol.reset(service)
 }
 
 // Close stops the watcher and destroys the cache
@@ -90,6 +115,7 @@ func (c *registrySelector) String() string {
 func NewSelector(opts ...Option) Selector {
 	sopts := Options{
 		Strategy: Random,
+		Outlier:  DefaultOutlierOptions,
 	}
 
 	for _, opt := range opts {
@@ -102,6 +128,7 @@ func NewSelector(opts ...Option) Selector {
 
 	s := &registrySelector{
 		so: sopts,
+		ol: newOutliers(sopts.Outlier),
 	}
 	s.rc = s.newCache()
 
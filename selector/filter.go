@@ -71,3 +71,38 @@ func FilterVersion(version string) Filter {
 		return services
 	}
 }
+
+// FilterHealthy is a health based Select Filter which will
+// skip nodes the registry marked as failing their health check.
+func FilterHealthy() Filter {
+	return func(old []*registry.Service) []*registry.Service {
+		var services []*registry.Service
+
+		for _, service := range old {
+			var nodes []*registry.Node
+
+			for _, node := range service.Nodes {
+				if node.Metadata[registry.HealthKey] == registry.Unhealthy {
+					continue
+				}
+				nodes = append(nodes, node)
+			}
+
+			// only add service if there's some nodes
+			if len(nodes) == 0 {
+				continue
+			}
+
+			// copy rather than change the cached service
+			if len(nodes) < len(service.Nodes) {
+				serv := new(registry.Service)
+				*serv = *service
+				serv.Nodes = nodes
+				service = serv
+			}
+			services = append(services, service)
+		}
+
+		return services
+	}
+}
@@ -0,0 +1,164 @@
+package selector
+
+import (
+	"hash/crc32"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go-micro.dev/v4/registry"
+)
+
+var (
+	// DefaultReplicas is the number of virtual nodes of each node on the hash ring
+	DefaultReplicas = 100
+	// DefaultLoadFactor bounds the requests in flight to a node picked by
+	// ConsistentHash to this many times the average
+	DefaultLoadFactor = 1.25
+
+	// rings built for the nodes of recent selects
+	rings = &ringCache{rings: make(map[string]*ring)}
+)
+
+// ring is a hash ring of node ids, placing each at DefaultReplicas points so
+// the keys are spread evenly and a node joining or leaving only moves its own
+type ring struct {
+	points []uint32
+	ids    map[uint32]string
+}
+
+func newRing(ids []string) *ring {
+	r := &ring{
+		points: make([]uint32, 0, len(ids)*DefaultReplicas),
+		ids:    make(map[uint32]string, len(ids)*DefaultReplicas),
+	}
+	for _, id := range ids {
+		for i := 0; i < DefaultReplicas; i++ {
+			p := hash(id + "-" + strconv.Itoa(i))
+			if _, ok := r.ids[p]; ok {
+				continue
+			}
+			r.points = append(r.points, p)
+			r.ids[p] = id
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// walk calls fn with the node ids clockwise from the key until it returns true
+func (r *ring) walk(key string, fn func(id string) bool) {
+	if len(r.points) == 0 {
+		return
+	}
+	h := hash(key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	for i := 0; i < len(r.points); i++ {
+		if fn(r.ids[r.points[(start+i)%len(r.points)]]) {
+			return
+		}
+	}
+}
+
+func hash(s string) uint32 {
+	return crc32.ChecksumIEEE([]byte(s))
+}
+
+// ringCache keeps the rings of the node sets selected from, so a ring is only
+// built again when the nodes change
+type ringCache struct {
+	sync.Mutex
+	rings map[string]*ring
+}
+
+// get returns the ring of the node ids, which are sorted
+func (c *ringCache) get(ids []string) *ring {
+	key := strings.Join(ids, ",")
+
+	c.Lock()
+	defer c.Unlock()
+
+	if r, ok := c.rings[key]; ok {
+		return r
+	}
+	// the nodes of services come and go, forget the old rings
+	if len(c.rings) >= 64 {
+		c.rings = make(map[string]*ring)
+	}
+	r := newRing(ids)
+	c.rings[key] = r
+	return r
+}
+
+// ConsistentHash is a strategy picking the same node for the same key, such as
+// a user id, so nodes can cache what they serve for it. The nodes are placed on
+// a hash ring, so when they change only the keys of the nodes joining or
+// leaving move. A node with more requests in flight than DefaultLoadFactor
+// times the average is passed over for the next on the ring, see Track.
+// Calling Next again returns the next node on the ring to retry on.
+func ConsistentHash(key string) Strategy {
+	return func(services []*registry.Service) Next {
+		byID := make(map[string]*registry.Node)
+		for _, n := range nodes(services) {
+			byID[n.Id] = n
+		}
+		ids := make([]string, 0, len(byID))
+		for id := range byID {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		r := rings.get(ids)
+		tried := make(map[string]bool)
+		var mtx sync.Mutex
+
+		return func() (*registry.Node, error) {
+			if len(byID) == 0 {
+				return nil, ErrNoneAvailable
+			}
+
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			// every node was tried, start over
+			if len(tried) == len(byID) {
+				tried = make(map[string]bool)
+			}
+
+			var total int
+			for _, n := range byID {
+				total += stats.load(n)
+			}
+			bound := int(math.Ceil(DefaultLoadFactor * float64(total+1) / float64(len(byID))))
+
+			var first, pick *registry.Node
+			r.walk(key, func(id string) bool {
+				if tried[id] {
+					return false
+				}
+				n := byID[id]
+				if first == nil {
+					first = n
+				}
+				if stats.load(n)+1 <= bound {
+					pick = n
+					return true
+				}
+				return false
+			})
+			if pick == nil {
+				pick = first
+			}
+
+			tried[pick.Id] = true
+			return pick, nil
+		}
+	}
+}
+
+// WithHashKey selects nodes by consistent hashing of the key, see ConsistentHash
+func WithHashKey(key string) SelectOption {
+	return WithStrategy(ConsistentHash(key))
+}
@@ -0,0 +1,102 @@
+package selector
+
+import (
+	"fmt"
+	"testing"
+
+	"go-micro.dev/v4/registry"
+)
+
+func hashServices(n int) []*registry.Service {
+	s := &registry.Service{Name: "test.hash", Version: "1.0.0"}
+	for i := 0; i < n; i++ {
+		s.Nodes = append(s.Nodes, &registry.Node{
+			Id:      fmt.Sprintf("test.hash-%d", i),
+			Address: fmt.Sprintf("127.0.0.1:%d", 9000+i),
+		})
+	}
+	return []*registry.Service{s}
+}
+
+func TestConsistentHash(t *testing.T) {
+	pick := func(services []*registry.Service, key string) *registry.Node {
+		node, err := ConsistentHash(key)(services)()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return node
+	}
+
+	services := hashServices(5)
+	before := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		node := pick(services, key)
+		if again := pick(services, key); again.Id != node.Id {
+			t.Fatalf("Expected %s for %s, got %s", node.Id, key, again.Id)
+		}
+		before[key] = node.Id
+		counts[node.Id]++
+	}
+	for id, c := range counts {
+		if c < 100 || c > 300 {
+			t.Fatalf("Expected keys spread evenly, %s got %d: %v", id, c, counts)
+		}
+	}
+
+	// removing a node only moves its own keys
+	removed := services[0].Nodes[2].Id
+	fewer := hashServices(5)
+	fewer[0].Nodes = append(fewer[0].Nodes[:2], fewer[0].Nodes[3:]...)
+	for key, id := range before {
+		node := pick(fewer, key)
+		if id != removed && node.Id != id {
+			t.Fatalf("Expected %s to stay on %s, got %s", key, id, node.Id)
+		}
+	}
+
+	// retries go to the other nodes in turn
+	next := ConsistentHash("user-1")(services)
+	seen := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		node, err := next()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		seen[node.Id] = true
+	}
+	if len(seen) != 5 {
+		t.Fatalf("Expected every node tried, got %v", seen)
+	}
+
+	if _, err := ConsistentHash("user-1")(nil)(); err != ErrNoneAvailable {
+		t.Fatalf("Expected error: %v, got: %v", ErrNoneAvailable, err)
+	}
+}
+
+func TestConsistentHashBoundedLoad(t *testing.T) {
+	services := hashServices(4)
+	hot, err := ConsistentHash("user-1")(services)()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// a node far above the average load is passed over
+	for i := 0; i < 4; i++ {
+		stats.start(hot)
+	}
+	defer func() {
+		for i := 0; i < 4; i++ {
+			stats.done(hot, 0)
+		}
+	}()
+
+	other, err := ConsistentHash("user-1")(services)()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if other.Id == hot.Id {
+		t.Fatalf("Expected a node other than the loaded %s", hot.Id)
+	}
+}
@@ -9,6 +9,8 @@ import (
 type Options struct {
 	Registry registry.Registry
 	Strategy Strategy
+	// Outlier configures ejecting failing nodes, see OutlierDetection
+	Outlier OutlierOptions
 
 	// Other options for implementations of the interface
 	// can be stored in a context
@@ -44,6 +46,14 @@ func SetStrategy(fn Strategy) Option {
 	}
 }
 
+// OutlierDetection sets when nodes failing are ejected from selection and
+// for how long. Zero ConsecutiveErrors and ErrorRate disable it.
+func OutlierDetection(opts OutlierOptions) Option {
+	return func(o *Options) {
+		o.Outlier = opts
+	}
+}
+
 // WithFilter adds a filter function to the list of filters
 // used during the Select call.
 func WithFilter(fn ...Filter) SelectOption {
@@ -0,0 +1,217 @@
+package selector
+
+import (
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/registry"
+)
+
+// OutlierOptions configures ejecting the nodes which keep failing from selection,
+// see OutlierDetection. Errors count if they are timeouts, server errors or not
+// micro errors, as marked through Selector.Mark.
+type OutlierOptions struct {
+	// ConsecutiveErrors ejects a node after this many errors in a row, 0 disables it
+	ConsecutiveErrors int
+	// ErrorRate ejects a node whose errors are above this share of its requests
+	// in the Interval, 0 disables it
+	ErrorRate float64
+	// MinRequests is the least requests in the Interval the error rate is judged on
+	MinRequests int
+	// Interval the error rate is counted over
+	Interval time.Duration
+	// BaseEjection is how long a node is first ejected, each ejection
+	// following another is longer by as much
+	BaseEjection time.Duration
+	// MaxEjection caps how long a node is ejected
+	MaxEjection time.Duration
+	// MaxEjectionPercent caps the share of the nodes of a service ejected at
+	// once, so a whole service can't be ejected
+	MaxEjectionPercent int
+}
+
+// DefaultOutlierOptions are the outlier options of the selector unless set by OutlierDetection
+var DefaultOutlierOptions = OutlierOptions{
+	ConsecutiveErrors:  5,
+	ErrorRate:          0.5,
+	MinRequests:        10,
+	Interval:           time.Second * 10,
+	BaseEjection:       time.Second * 30,
+	MaxEjection:        time.Minute * 5,
+	MaxEjectionPercent: 50,
+}
+
+// outlier is the error record of a node
+type outlier struct {
+	consecutive int
+	// requests and errors since start, reset every interval
+	requests int
+	errors   int
+	start    time.Time
+
+	// ejections in a row, each ejects for longer
+	ejections int
+	// until is when the ejection ends, zero if the node was never ejected
+	until time.Time
+	// probing is set once the node returns from an ejection, until it succeeds
+	probing bool
+}
+
+func (o *outlier) ejected(now time.Time) bool {
+	return now.Before(o.until)
+}
+
+// outliers tracks the errors of the nodes of each service
+type outliers struct {
+	sync.Mutex
+	opts OutlierOptions
+	// nodes by service and node id
+	nodes map[string]map[string]*outlier
+	// total is the number of nodes of each service last selected from
+	total map[string]int
+}
+
+func newOutliers(opts OutlierOptions) *outliers {
+	return &outliers{
+		opts:  opts,
+		nodes: make(map[string]map[string]*outlier),
+		total: make(map[string]int),
+	}
+}
+
+func (o *outliers) enabled() bool {
+	return o.opts.ConsecutiveErrors > 0 || o.opts.ErrorRate > 0
+}
+
+// mark records the result of a request to the node, ejecting it if it is an outlier
+func (o *outliers) mark(service string, node *registry.Node, err error) {
+	if !o.enabled() {
+		return
+	}
+
+	o.Lock()
+	defer o.Unlock()
+
+	nodes, ok := o.nodes[service]
+	if !ok {
+		nodes = make(map[string]*outlier)
+		o.nodes[service] = nodes
+	}
+	n, ok := nodes[node.Id]
+	if !ok {
+		n = &outlier{}
+		nodes[node.Id] = n
+	}
+
+	now := time.Now()
+	if n.start.IsZero() || now.Sub(n.start) > o.opts.Interval {
+		n.start = now
+		n.requests = 0
+		n.errors = 0
+	}
+	// a node healthy for long enough starts over
+	if !n.until.IsZero() && !n.probing && now.Sub(n.until) > o.opts.MaxEjection {
+		n.ejections = 0
+	}
+
+	n.requests++
+	if err == nil || !isFailure(err) {
+		n.consecutive = 0
+		n.probing = false
+		return
+	}
+	n.consecutive++
+	n.errors++
+
+	// a node still ejected was picked before it was, its errors are already known
+	if n.ejected(now) {
+		return
+	}
+
+	outlier := n.probing ||
+		(o.opts.ConsecutiveErrors > 0 && n.consecutive >= o.opts.ConsecutiveErrors) ||
+		(o.opts.ErrorRate > 0 && n.requests >= o.opts.MinRequests &&
+			float64(n.errors)/float64(n.requests) > o.opts.ErrorRate)
+	if !outlier {
+		return
+	}
+
+	// keep the share of the service ejected below the cap
+	ejected := 1
+	for id, other := range nodes {
+		if id != node.Id && other.ejected(now) {
+			ejected++
+		}
+	}
+	if ejected*100 > o.total[service]*o.opts.MaxEjectionPercent {
+		return
+	}
+
+	n.ejections++
+	d := o.opts.BaseEjection * time.Duration(n.ejections)
+	if o.opts.MaxEjection > 0 && d > o.opts.MaxEjection {
+		d = o.opts.MaxEjection
+	}
+	n.until = now.Add(d)
+	n.probing = true
+	n.consecutive = 0
+	n.requests = 0
+	n.errors = 0
+}
+
+// filter removes the ejected nodes from the services, copying those changed
+func (o *outliers) filter(service string, services []*registry.Service) []*registry.Service {
+	if !o.enabled() {
+		return services
+	}
+
+	var total int
+	for _, s := range services {
+		total += len(s.Nodes)
+	}
+
+	o.Lock()
+	o.total[service] = total
+	nodes := o.nodes[service]
+	if len(nodes) == 0 {
+		o.Unlock()
+		return services
+	}
+
+	now := time.Now()
+	var filtered []*registry.Service
+	for _, s := range services {
+		var keep []*registry.Node
+		for _, n := range s.Nodes {
+			if on, ok := nodes[n.Id]; ok && on.ejected(now) {
+				continue
+			}
+			keep = append(keep, n)
+		}
+		if len(keep) == 0 {
+			continue
+		}
+		if len(keep) < len(s.Nodes) {
+			cp := new(registry.Service)
+			*cp = *s
+			cp.Nodes = keep
+			s = cp
+		}
+		filtered = append(filtered, s)
+	}
+	o.Unlock()
+
+	// never eject every node
+	if len(filtered) == 0 {
+		return services
+	}
+	return filtered
+}
+
+// reset forgets the errors of the nodes of the service
+func (o *outliers) reset(service string) {
+	o.Lock()
+	defer o.Unlock()
+	delete(o.nodes, service)
+	delete(o.total, service)
+}
@@ -0,0 +1,91 @@
+package selector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/errors"
+	"go-micro.dev/v4/registry"
+)
+
+func TestOutlierDetection(t *testing.T) {
+	r := registry.NewMemoryRegistry(registry.Services(testData))
+	s := NewSelector(Registry(r), OutlierDetection(OutlierOptions{
+		ConsecutiveErrors:  3,
+		Interval:           time.Second,
+		BaseEjection:       time.Millisecond * 50,
+		MaxEjection:        time.Second,
+		MaxEjectionPercent: 50,
+	}))
+	defer s.Close()
+
+	// selected returns the ids of the nodes which may be selected
+	selected := func() map[string]bool {
+		next, err := s.Select("foo", WithStrategy(RoundRobin))
+		if err != nil {
+			t.Fatalf("Unexpected select error: %v", err)
+		}
+		ids := make(map[string]bool)
+		for i := 0; i < 8; i++ {
+			node, err := next()
+			if err != nil {
+				t.Fatalf("Unexpected next error: %v", err)
+			}
+			ids[node.Id] = true
+		}
+		return ids
+	}
+	fail := func(id string, err error, n int) {
+		for i := 0; i < n; i++ {
+			s.Mark("foo", &registry.Node{Id: id}, err)
+		}
+	}
+
+	if ids := selected(); len(ids) != 4 {
+		t.Fatalf("Expected 4 nodes, got %v", ids)
+	}
+
+	// errors of the request rather than the node don't count
+	fail("foo-1.0.0-123", errors.BadRequest("foo", "bad"), 5)
+	if ids := selected(); len(ids) != 4 {
+		t.Fatalf("Expected 4 nodes, got %v", ids)
+	}
+
+	fail("foo-1.0.0-123", fmt.Errorf("connection refused"), 3)
+	if ids := selected(); len(ids) != 3 || ids["foo-1.0.0-123"] {
+		t.Fatalf("Expected foo-1.0.0-123 ejected, got %v", ids)
+	}
+
+	// no more than half the nodes are ejected
+	fail("foo-1.0.1-321", errors.InternalServerError("foo", "down"), 3)
+	fail("foo-1.0.3-345", errors.Timeout("foo", "slow"), 3)
+	if ids := selected(); len(ids) != 2 || !ids["foo-1.0.3-345"] {
+		t.Fatalf("Expected 2 nodes ejected, got %v", ids)
+	}
+
+	// ejected nodes are probed again once the ejection ends
+	time.Sleep(time.Millisecond * 60)
+	if ids := selected(); len(ids) != 4 {
+		t.Fatalf("Expected 4 nodes, got %v", ids)
+	}
+
+	// a probe failing ejects the node again, for longer
+	fail("foo-1.0.0-123", fmt.Errorf("connection refused"), 1)
+	time.Sleep(time.Millisecond * 60)
+	if ids := selected(); ids["foo-1.0.0-123"] {
+		t.Fatalf("Expected foo-1.0.0-123 ejected, got %v", ids)
+	}
+
+	// a probe succeeding restores the node
+	fail("foo-1.0.1-321", nil, 1)
+	fail("foo-1.0.1-321", fmt.Errorf("connection refused"), 1)
+	if ids := selected(); !ids["foo-1.0.1-321"] {
+		t.Fatalf("Expected foo-1.0.1-321 selected, got %v", ids)
+	}
+
+	s.Reset("foo")
+	if ids := selected(); len(ids) != 4 {
+		t.Fatalf("Expected 4 nodes after reset, got %v", ids)
+	}
+}
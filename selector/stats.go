@@ -0,0 +1,155 @@
+package selector
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/errors"
+	"go-micro.dev/v4/registry"
+)
+
+var (
+	// DefaultDecay is how quickly the latency average of a node forgets old requests
+	DefaultDecay = time.Second * 10
+	// DefaultErrorPenalty is the latency an error counts as, making failing nodes less preferred
+	DefaultErrorPenalty = time.Second
+
+	// stats of the nodes requested, shared by the strategies weighing nodes by load
+	stats = newNodeStats()
+)
+
+// Tracker is implemented by selectors which track the requests made to nodes,
+// see the LeastOutstanding, PowerOfTwo and PeakEWMA strategies
+type Tracker interface {
+	// Track records a request to the node starting. The returned func is
+	// called once the request is done, marking the node with its error.
+	Track(service string, node *registry.Node) func(err error)
+}
+
+// Track records a request to the node starting if the selector is a Tracker.
+// Call the returned func once the request is done, it marks the node.
+func Track(s Selector, service string, node *registry.Node) func(err error) {
+	if t, ok := s.(Tracker); ok {
+		return t.Track(service, node)
+	}
+	return func(err error) {
+		s.Mark(service, node, err)
+	}
+}
+
+// nodeStat is the load of a node
+type nodeStat struct {
+	// inflight is the number of requests in flight
+	inflight int
+	// ewma is the peak weighted moving average of the latency in nanoseconds
+	ewma float64
+	// stamp is when ewma was last updated
+	stamp time.Time
+}
+
+type nodeStats struct {
+	sync.Mutex
+	nodes map[string]*nodeStat
+}
+
+func newNodeStats() *nodeStats {
+	return &nodeStats{nodes: make(map[string]*nodeStat)}
+}
+
+// get returns the stat of the node, it is called locked
+func (s *nodeStats) get(node *registry.Node) *nodeStat {
+	st, ok := s.nodes[node.Id]
+	if !ok {
+		st = &nodeStat{}
+		s.nodes[node.Id] = st
+	}
+	return st
+}
+
+// start records a request to the node starting
+func (s *nodeStats) start(node *registry.Node) {
+	s.Lock()
+	defer s.Unlock()
+	s.get(node).inflight++
+}
+
+// done records a request to the node which took d
+func (s *nodeStats) done(node *registry.Node, d time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	st := s.get(node)
+	if st.inflight > 0 {
+		st.inflight--
+	}
+	st.observe(d)
+	s.prune()
+}
+
+// isFailure reports whether the error is the node failing rather than the request
+func isFailure(err error) bool {
+	e := errors.Parse(err.Error())
+	return e.Code == 0 || e.Code == 408 || e.Code >= 500
+}
+
+// penalize records an error of the node
+func (s *nodeStats) penalize(node *registry.Node) {
+	s.Lock()
+	defer s.Unlock()
+	s.get(node).observe(DefaultErrorPenalty)
+}
+
+// observe adds the latency to the average, a latency above it replaces it
+// so a node slowing down is avoided at once
+func (st *nodeStat) observe(d time.Duration) {
+	now := time.Now()
+	rtt := float64(d)
+
+	if st.stamp.IsZero() || rtt > st.ewma {
+		st.ewma = rtt
+	} else {
+		w := math.Exp(-float64(now.Sub(st.stamp)) / float64(DefaultDecay))
+		st.ewma = st.ewma*w + rtt*(1-w)
+	}
+	st.stamp = now
+}
+
+// prune forgets nodes idle for long enough to be gone, it is called locked
+func (s *nodeStats) prune() {
+	if len(s.nodes) < 1024 {
+		return
+	}
+	for id, st := range s.nodes {
+		if st.inflight == 0 && time.Since(st.stamp) > DefaultDecay*10 {
+			delete(s.nodes, id)
+		}
+	}
+}
+
+// load returns the requests in flight to the node
+func (s *nodeStats) load(node *registry.Node) int {
+	s.Lock()
+	defer s.Unlock()
+	if st, ok := s.nodes[node.Id]; ok {
+		return st.inflight
+	}
+	return 0
+}
+
+// cost returns the expected latency of a request to the node, its decayed
+// latency times the requests it would have in flight
+func (s *nodeStats) cost(node *registry.Node) float64 {
+	s.Lock()
+	defer s.Unlock()
+
+	st, ok := s.nodes[node.Id]
+	if !ok {
+		return 0
+	}
+
+	ewma := st.ewma
+	if !st.stamp.IsZero() {
+		ewma *= math.Exp(-float64(time.Since(st.stamp)) / float64(DefaultDecay))
+	}
+	return ewma * float64(st.inflight+1)
+}
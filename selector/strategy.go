@@ -54,3 +54,81 @@ func RoundRobin(services []*registry.Service) Next {
 		return node, nil
 	}
 }
+
+// nodes returns the nodes of the services
+func nodes(services []*registry.Service) []*registry.Node {
+	nodes := make([]*registry.Node, 0, len(services))
+	for _, service := range services {
+		nodes = append(nodes, service.Nodes...)
+	}
+	return nodes
+}
+
+// LeastOutstanding is a strategy picking the node with the fewest requests in
+// flight, ties are broken at random. Requests are counted by Track.
+func LeastOutstanding(services []*registry.Service) Next {
+	nodes := nodes(services)
+
+	return func() (*registry.Node, error) {
+		if len(nodes) == 0 {
+			return nil, ErrNoneAvailable
+		}
+
+		var best *registry.Node
+		least, ties := 0, 0
+		for _, i := range rand.Perm(len(nodes)) {
+			load := stats.load(nodes[i])
+			switch {
+			case best == nil || load < least:
+				best, least, ties = nodes[i], load, 1
+			case load == least:
+				// pick one of the ties at random
+				ties++
+				if rand.Intn(ties) == 0 {
+					best = nodes[i]
+				}
+			}
+		}
+		return best, nil
+	}
+}
+
+// PowerOfTwo is a strategy picking the node with fewer requests in flight of
+// two picked at random, avoiding every client rushing to the least loaded node.
+// Requests are counted by Track.
+func PowerOfTwo(services []*registry.Service) Next {
+	return p2c(nodes(services), func(n *registry.Node) float64 {
+		return float64(stats.load(n))
+	})
+}
+
+// PeakEWMA is a strategy picking the node with the lower expected latency of
+// two picked at random. The latency of a node is a moving average of its
+// requests which jumps to peaks at once, times the requests it has in flight.
+// Requests are timed by Track and errors marked count as slow requests.
+func PeakEWMA(services []*registry.Service) Next {
+	return p2c(nodes(services), stats.cost)
+}
+
+// p2c picks the cheaper node of two picked at random
+func p2c(nodes []*registry.Node, cost func(*registry.Node) float64) Next {
+	return func() (*registry.Node, error) {
+		switch len(nodes) {
+		case 0:
+			return nil, ErrNoneAvailable
+		case 1:
+			return nodes[0], nil
+		}
+
+		i := rand.Intn(len(nodes))
+		j := rand.Intn(len(nodes) - 1)
+		if j >= i {
+			j++
+		}
+
+		if cost(nodes[j]) < cost(nodes[i]) {
+			return nodes[j], nil
+		}
+		return nodes[i], nil
+	}
+}
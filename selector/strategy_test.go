@@ -3,7 +3,9 @@ package selector
 import (
 	"os"
 	"testing"
+	"time"
 
+	"go-micro.dev/v4/errors"
 	"go-micro.dev/v4/registry"
 )
 
@@ -56,3 +58,71 @@ func TestStrategies(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadStrategies(t *testing.T) {
+	testData := []*registry.Service{
+		{
+			Name:    "test2",
+			Version: "latest",
+			Nodes: []*registry.Node{
+				{
+					Id:      "test2-1",
+					Address: "10.0.0.1:1001",
+				},
+				{
+					Id:      "test2-2",
+					Address: "10.0.0.2:1002",
+				},
+			},
+		},
+	}
+	busy, idle := testData[0].Nodes[0], testData[0].Nodes[1]
+
+	s := NewSelector(Registry(registry.NewMemoryRegistry()))
+
+	// the busy node has a request in flight which took long
+	for i := 0; i < 3; i++ {
+		Track(s, "test2", busy)
+	}
+	Track(s, "test2", idle)(nil)
+	done := Track(s, "test2", busy)
+	time.Sleep(time.Millisecond * 20)
+	done(nil)
+
+	for name, strategy := range map[string]Strategy{
+		"least_outstanding": LeastOutstanding,
+		"p2c":               PowerOfTwo,
+		"peak_ewma":         PeakEWMA,
+	} {
+		next := strategy(testData)
+		for i := 0; i < 10; i++ {
+			node, err := next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if node.Id != idle.Id {
+				t.Fatalf("%s: expected the idle node, got %s", name, node.Id)
+			}
+		}
+	}
+
+	if _, err := PeakEWMA(nil)(); err != ErrNoneAvailable {
+		t.Fatalf("Expected error: %v, got: %v", ErrNoneAvailable, err)
+	}
+}
+
+func TestMarkPenalty(t *testing.T) {
+	s := NewSelector(Registry(registry.NewMemoryRegistry()))
+	node := &registry.Node{Id: "test-penalty-1", Address: "10.0.0.1:1001"}
+
+	// errors of the request don't penalize the node
+	s.Mark("test", node, errors.BadRequest("test", "bad request"))
+	if cost := stats.cost(node); cost >= float64(DefaultErrorPenalty)/2 {
+		t.Fatalf("Expected no penalty for a bad request, got cost %v", cost)
+	}
+
+	s.Mark("test", node, errors.InternalServerError("test", "failed"))
+	if cost := stats.cost(node); cost < float64(DefaultErrorPenalty)/2 {
+		t.Fatalf("Expected a penalty for a failure, got cost %v", cost)
+	}
+}
@@ -7,6 +7,11 @@ import (
 
 type serverKey struct{}
 
+type topicKey struct{}
+
+// subscriberKey holds the subscriber owning the broker subscription a message was received on
+type subscriberKey struct{}
+
 func wait(ctx context.Context) *sync.WaitGroup {
 	if ctx == nil {
 		return nil
@@ -26,3 +31,20 @@ func FromContext(ctx context.Context) (Server, bool) {
 func NewContext(ctx context.Context, s Server) context.Context {
 	return context.WithValue(ctx, serverKey{}, s)
 }
+
+// SubscriberFromContext returns the subscriber the message being handled was received for
+func SubscriberFromContext(ctx context.Context) (Subscriber, bool) {
+	s, ok := ctx.Value(subscriberKey{}).(Subscriber)
+	return s, ok
+}
+
+// NewSubscriberContext returns a context holding the subscriber a message was received for
+func NewSubscriberContext(ctx context.Context, s Subscriber) context.Context {
+	return context.WithValue(ctx, subscriberKey{}, s)
+}
+
+// TopicFromContext returns the topic the message being handled by a subscriber was published to
+func TopicFromContext(ctx context.Context) (string, bool) {
+	t, ok := ctx.Value(topicKey{}).(string)
+	return t, ok
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/logger"
+)
+
+var errFlowStopped = errors.New("subscriber stopped")
+
+// flow bounds the messages a subscriber handles at once, see SubscriberMaxInFlight,
+// SubscriberWorkers and SubscriberRateLimit. The broker subscriber is paused while
+// the subscriber is saturated and resumed once it has drained.
+type flow struct {
+	opts    SubscriberOptions
+	handler broker.Handler
+	limiter *limiter
+
+	// slots are the messages in flight, nil when unbounded
+	slots chan bool
+	// queue hands messages to the workers, nil without workers
+	queue chan broker.Event
+	exit  chan bool
+	wg    sync.WaitGroup
+
+	sync.Mutex
+	sub broker.Subscriber
+	// pending is the number of messages in flight or waiting for a slot
+	pending int
+	paused  bool
+}
+
+func newFlow(opts SubscriberOptions, h broker.Handler) *flow {
+	f := &flow{
+		opts:    opts,
+		handler: h,
+		exit:    make(chan bool),
+	}
+
+	max := opts.MaxInFlight
+	if max <= 0 && opts.Workers > 0 {
+		max = opts.Workers
+	}
+	if max > 0 {
+		f.slots = make(chan bool, max)
+	}
+	if opts.RateLimit > 0 {
+		f.limiter = newLimiter(opts.RateLimit)
+	}
+
+	if opts.Workers > 0 {
+		f.queue = make(chan broker.Event, max)
+		for i := 0; i < opts.Workers; i++ {
+			f.wg.Add(1)
+			go f.work()
+		}
+	}
+
+	return f
+}
+
+// subscribed sets the broker subscriber to pause and resume
+func (f *flow) subscribed(sub broker.Subscriber) {
+	f.Lock()
+	defer f.Unlock()
+	f.sub = sub
+	if f.paused {
+		f.pause(true)
+	}
+}
+
+// pause pauses or resumes the broker subscriber, it is called locked
+func (f *flow) pause(p bool) {
+	f.paused = p
+	pr, ok := f.sub.(broker.Pauser)
+	if !ok {
+		return
+	}
+
+	var err error
+	if p {
+		err = pr.Pause()
+	} else {
+		err = pr.Resume()
+	}
+	if err != nil && logger.V(logger.WarnLevel, logger.DefaultLogger) {
+		logger.Warnf("Failed to pause subscriber to %v: %v", f.sub.Topic(), err)
+	}
+}
+
+// acquire takes a slot for a message, waiting while none are free
+func (f *flow) acquire() bool {
+	if f.slots == nil {
+		return true
+	}
+
+	f.Lock()
+	f.pending++
+	// stop receiving more than can be handled
+	if f.pending >= cap(f.slots) && !f.paused {
+		f.pause(true)
+	}
+	f.Unlock()
+
+	select {
+	case f.slots <- true:
+		return true
+	case <-f.exit:
+		f.release()
+		return false
+	}
+}
+
+// release frees the slot of a message, resuming once half the slots are free
+func (f *flow) release() {
+	if f.slots == nil {
+		return
+	}
+
+	select {
+	case <-f.slots:
+	default:
+	}
+
+	f.Lock()
+	f.pending--
+	if f.paused && f.pending <= cap(f.slots)/2 {
+		f.pause(false)
+	}
+	f.Unlock()
+}
+
+// handle is the broker handler of the subscriber
+func (f *flow) handle(e broker.Event) error {
+	if !f.acquire() {
+		return errFlowStopped
+	}
+
+	// workers ack the message once handled
+	if f.queue != nil {
+		f.queue <- e
+		return nil
+	}
+
+	defer f.release()
+	return f.process(e)
+}
+
+func (f *flow) process(e broker.Event) error {
+	if f.limiter != nil && !f.limiter.wait(f.exit) {
+		return errFlowStopped
+	}
+	return f.handler(e)
+}
+
+func (f *flow) work() {
+	defer f.wg.Done()
+
+	for {
+		select {
+		case e := <-f.queue:
+			err := f.process(e)
+			if err == nil && f.opts.AutoAck {
+				err = e.Ack()
+			}
+			if err != nil && err != errFlowStopped && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Subscriber error handling %v: %v", e.Topic(), err)
+			}
+			f.release()
+		case <-f.exit:
+			return
+		}
+	}
+}
+
+// stop stops the workers, messages still queued are not handled
+func (f *flow) stop() {
+	f.Lock()
+	select {
+	case <-f.exit:
+		f.Unlock()
+		return
+	default:
+		close(f.exit)
+	}
+	f.Unlock()
+
+	f.wg.Wait()
+}
+
+// flowSubscriber stops the flow when unsubscribed
+type flowSubscriber struct {
+	broker.Subscriber
+	f *flow
+}
+
+func (s *flowSubscriber) Unsubscribe() error {
+	err := s.Subscriber.Unsubscribe()
+	s.f.stop()
+	return err
+}
+
+// limiter spaces out messages to a rate per second
+type limiter struct {
+	sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimiter(rate float64) *limiter {
+	return &limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// wait blocks until the next message may be handled, returning false if exit closes first
+func (l *limiter) wait(exit chan bool) bool {
+	l.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return true
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-exit:
+		return false
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/broker"
+)
+
+type testEvent struct {
+	broker.Event
+	acked int32
+}
+
+func (e *testEvent) Ack() error {
+	atomic.AddInt32(&e.acked, 1)
+	return nil
+}
+
+func (e *testEvent) Topic() string {
+	return "test"
+}
+
+type testPauser struct {
+	broker.Subscriber
+	sync.Mutex
+	paused, resumed int
+}
+
+func (p *testPauser) Pause() error {
+	p.Lock()
+	defer p.Unlock()
+	p.paused++
+	return nil
+}
+
+func (p *testPauser) Resume() error {
+	p.Lock()
+	defer p.Unlock()
+	p.resumed++
+	return nil
+}
+
+func TestFlowWorkers(t *testing.T) {
+	var running, peak int32
+	release := make(chan bool)
+
+	f := newFlow(NewSubscriberOptions(SubscriberWorkers(2), SubscriberMaxInFlight(4)), func(e broker.Event) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+	defer f.stop()
+
+	pr := &testPauser{}
+	f.subscribed(pr)
+
+	// four messages are taken, the fifth waits for a slot
+	evs := make([]*testEvent, 5)
+	done := make(chan bool, 5)
+	for i := range evs {
+		evs[i] = &testEvent{}
+		go func(e *testEvent) {
+			f.handle(e)
+			done <- true
+		}(evs[i])
+	}
+
+	for i := 0; i < 4; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out handing messages to the workers")
+		}
+	}
+	select {
+	case <-done:
+		t.Fatal("Expected the delivery to wait while the subscriber is saturated")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	pr.Lock()
+	if pr.paused != 1 {
+		t.Fatalf("Expected the subscriber to be paused once, got %d", pr.paused)
+	}
+	pr.Unlock()
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the delivery to resume")
+	}
+
+	// every message is handled and acked
+	deadline := time.Now().Add(time.Second)
+	for _, e := range evs {
+		for atomic.LoadInt32(&e.acked) == 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 10)
+		}
+		if atomic.LoadInt32(&e.acked) != 1 {
+			t.Fatal("Expected every message to be acked once")
+		}
+	}
+
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Fatalf("Expected at most 2 messages handled at once, got %d", p)
+	}
+	pr.Lock()
+	if pr.resumed != 1 {
+		t.Fatalf("Expected the subscriber to be resumed once, got %d", pr.resumed)
+	}
+	pr.Unlock()
+}
+
+func TestFlowRateLimit(t *testing.T) {
+	var handled int32
+	f := newFlow(NewSubscriberOptions(SubscriberRateLimit(20)), func(e broker.Event) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+	defer f.stop()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := f.handle(&testEvent{}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// the first is handled at once, then one every 50ms
+	if d := time.Since(start); d < time.Millisecond*200 {
+		t.Fatalf("Expected the messages to be spaced out, took %v", d)
+	}
+	if handled != 5 {
+		t.Fatalf("Expected 5 messages handled, got %d", handled)
+	}
+}
+
+func TestFlowStop(t *testing.T) {
+	f := newFlow(NewSubscriberOptions(SubscriberMaxInFlight(1)), func(e broker.Event) error {
+		time.Sleep(time.Millisecond * 100)
+		return nil
+	})
+
+	go f.handle(&testEvent{})
+	time.Sleep(time.Millisecond * 10)
+
+	// a delivery waiting for a slot gives up once stopped
+	errs := make(chan error, 1)
+	go func() {
+		errs <- f.handle(&testEvent{})
+	}()
+	time.Sleep(time.Millisecond * 10)
+	f.stop()
+
+	select {
+	case err := <-errs:
+		if err != errFlowStopped {
+			t.Fatalf("Expected %v, got %v", errFlowStopped, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the delivery to give up")
+	}
+}
@@ -1,6 +1,9 @@
 package server
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type HandlerOption func(*HandlerOptions)
 
@@ -17,7 +20,21 @@ type SubscriberOptions struct {
 	AutoAck  bool
 	Queue    string
 	Internal bool
-	Context  context.Context
+	// Wildcard subscribes to every topic matching the topic pattern, such as orders.* or orders.>
+	Wildcard bool
+	// BatchSize hands the subscriber a slice of up to this many messages, see SubscriberBatch
+	BatchSize int
+	// BatchWait is how long a batch waits to fill before it is handled
+	BatchWait time.Duration
+	// MaxInFlight bounds the messages being handled or waiting for a worker,
+	// the broker subscriber is paused while it is reached
+	MaxInFlight int
+	// Workers is the number of goroutines handling messages, when set messages are
+	// handed to the workers and acked once handled
+	Workers int
+	// RateLimit is the maximum number of messages handled per second
+	RateLimit float64
+	Context   context.Context
 }
 
 // EndpointMetadata is a Handler option that allows metadata to be added to
@@ -72,6 +89,47 @@ func SubscriberQueue(n string) SubscriberOption {
 	}
 }
 
+// SubscriberWildcard subscribes to every topic matching the topic pattern. The topic
+// a message was published to can be read with TopicFromContext.
+func SubscriberWildcard() SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.Wildcard = true
+	}
+}
+
+// SubscriberBatch hands the subscriber the messages in batches, flushed once size messages
+// are waiting or the oldest has waited for the duration. The subscriber takes a slice of the
+// message type, e.g. func(context.Context, []*Reading) error.
+func SubscriberBatch(size int, wait time.Duration) SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.BatchSize = size
+		o.BatchWait = wait
+	}
+}
+
+// SubscriberMaxInFlight bounds the messages being handled at once. Deliveries wait while
+// the bound is reached and brokers which support it are paused, see broker.Pauser.
+func SubscriberMaxInFlight(n int) SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.MaxInFlight = n
+	}
+}
+
+// SubscriberWorkers handles messages with a pool of n goroutines. The messages waiting
+// for a worker are bounded by SubscriberMaxInFlight, or the number of workers if unset.
+func SubscriberWorkers(n int) SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.Workers = n
+	}
+}
+
+// SubscriberRateLimit limits the messages handled to the rate per second
+func SubscriberRateLimit(perSecond float64) SubscriberOption {
+	return func(o *SubscriberOptions) {
+		o.RateLimit = perSecond
+	}
+}
+
 // SubscriberContext set context options to allow broker SubscriberOption passed
 func SubscriberContext(ctx context.Context) SubscriberOption {
 	return func(o *SubscriberOptions) {
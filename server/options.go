@@ -34,6 +34,8 @@ type Options struct {
 	RegisterTTL time.Duration
 	// The interval on which to register
 	RegisterInterval time.Duration
+	// HealthCheck is run against the node by the registry, see registry.Check
+	HealthCheck *registry.Check
 
 	// The router for requests
 	Router Router
@@ -200,6 +202,13 @@ func RegisterInterval(t time.Duration) Option {
 	}
 }
 
+// RegisterHealthCheck sets the check the registry runs against the registered node
+func RegisterHealthCheck(c *registry.Check) Option {
+	return func(o *Options) {
+		o.HealthCheck = c
+	}
+}
+
 // TLSConfig specifies a *tls.Config
 func TLSConfig(t *tls.Config) Option {
 	return func(o *Options) {
@@ -31,6 +31,13 @@ type rpcMessage struct {
 	codec       codec.NewCodec
 }
 
+// batchMessage is a batch handed to a batch subscriber, it passes through the
+// subscriber wrappers as one message with the header its messages share
+type batchMessage struct {
+	*rpcMessage
+	msgs []Message
+}
+
 func (r *rpcRequest) Codec() codec.Reader {
 	return r.codec
 }
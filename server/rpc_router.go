@@ -18,6 +18,7 @@ import (
 	"unicode"
 	"unicode/utf8"
 
+	"go-micro.dev/v4/broker"
 	"go-micro.dev/v4/codec"
 	merrors "go-micro.dev/v4/errors"
 	"go-micro.dev/v4/logger"
@@ -515,15 +516,47 @@ func (router *router) ProcessMessage(ctx context.Context, msg Message) (err erro
 		}
 	}()
 
-	router.su.RLock()
-	// get the subscribers by topic
-	subs, ok := router.subscribers[msg.Topic()]
-	// unlock since we only need to get the subs
-	router.su.RUnlock()
-	if !ok {
+	var subs []*subscriber
+	if sub, ok := ctx.Value(subscriberKey{}).(*subscriber); ok {
+		// batches are handed to their subscriber as a slice
+		if batch, ok := msg.(*batchMessage); ok {
+			return router.processBatch(ctx, sub, batch)
+		}
+		// received on the subscription of a subscriber, the others have their own
+		subs = []*subscriber{sub}
+	} else {
+		router.su.RLock()
+		// get the subscribers by topic
+		subs = router.subscribers[msg.Topic()]
+		// and those subscribed to a pattern matching it
+		for pattern, psubs := range router.subscribers {
+			if pattern == msg.Topic() || !broker.MatchTopic(pattern, msg.Topic()) {
+				continue
+			}
+			for _, sub := range psubs {
+				if sub.opts.Wildcard {
+					subs = append(subs[:len(subs):len(subs)], sub)
+				}
+			}
+		}
+		// unlock since we only need to get the subs
+		router.su.RUnlock()
+	}
+
+	// batch subscribers are handed their messages by processBatch
+	for i := 0; i < len(subs); i++ {
+		if subs[i].opts.BatchSize > 0 {
+			subs = append(subs[:i:i], subs[i+1:]...)
+			i--
+		}
+	}
+	if len(subs) == 0 {
 		return nil
 	}
 
+	// handlers of wildcard subscribers can tell which topic the message was published to
+	ctx = context.WithValue(ctx, topicKey{}, msg.Topic())
+
 	var errResults []string
 
 	// we may have multiple subscribers for the topic
@@ -611,3 +644,104 @@ func (router *router) ProcessMessage(ctx context.Context, msg Message) (err erro
 
 	return err
 }
+
+// processBatch hands the messages to the handlers of a batch subscriber as a slice
+func (router *router) processBatch(ctx context.Context, sub *subscriber, batch *batchMessage) (err error) {
+	defer func() {
+		// recover any panics
+		if r := recover(); r != nil {
+			logger.Errorf("panic recovered: %v", r)
+			logger.Error(string(debug.Stack()))
+			err = merrors.InternalServerError("go.micro.server", "panic recovered: %v", r)
+		}
+	}()
+
+	msgs := batch.msgs
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	var errResults []string
+
+	// we may have multiple handlers per subscriber
+	for i := 0; i < len(sub.handlers); i++ {
+		handler := sub.handlers[i]
+		elemType := handler.reqType.Elem()
+		reqs := reflect.MakeSlice(handler.reqType, 0, len(msgs))
+
+		for _, msg := range msgs {
+			var req reflect.Value
+
+			// check whether the element is a pointer
+			if elemType.Kind() == reflect.Ptr {
+				req = reflect.New(elemType.Elem())
+			} else {
+				req = reflect.New(elemType)
+			}
+
+			cc := msg.Codec()
+
+			// read the header. mostly a noop
+			if err = cc.ReadHeader(&codec.Message{}, codec.Event); err != nil {
+				return err
+			}
+
+			// read the body into the element
+			if err = cc.ReadBody(req.Interface()); err != nil {
+				return err
+			}
+
+			if elemType.Kind() != reflect.Ptr {
+				req = req.Elem()
+			}
+			reqs = reflect.Append(reqs, req)
+		}
+
+		// create the handler which will honour the SubscriberFunc type
+		fn := func(ctx context.Context, msg Message) error {
+			var vals []reflect.Value
+			if sub.typ.Kind() != reflect.Func {
+				vals = append(vals, sub.rcvr)
+			}
+			if handler.ctxType != nil {
+				vals = append(vals, reflect.ValueOf(ctx))
+			}
+
+			// values to pass the handler
+			vals = append(vals, reflect.ValueOf(msg.Payload()))
+
+			// execute the actual call of the handler
+			returnValues := handler.method.Call(vals)
+			if rerr := returnValues[0].Interface(); rerr != nil {
+				return rerr.(error)
+			}
+			return nil
+		}
+
+		// wrap with subscriber wrappers
+		for i := len(router.subWrappers); i > 0; i-- {
+			fn = router.subWrappers[i-1](fn)
+		}
+
+		// the wrappers see the batch as one message with the slice as its payload
+		rpcMsg := &rpcMessage{
+			topic:       batch.topic,
+			contentType: batch.contentType,
+			payload:     reqs.Interface(),
+			codec:       batch.codec,
+			header:      batch.header,
+		}
+
+		// execute the message handler
+		if err = fn(ctx, rpcMsg); err != nil {
+			errResults = append(errResults, err.Error())
+		}
+	}
+
+	// if no errors just return
+	if len(errResults) > 0 {
+		err = merrors.InternalServerError("go.micro.server", "subscriber error: %v", strings.Join(errResults, "\n"))
+	}
+
+	return err
+}
@@ -64,6 +64,109 @@ func newRpcServer(opts ...Option) Server {
 // HandleEvent handles inbound messages to the service directly
 // TODO: handle requests from an event. We won't send a response.
 func (s *rpcServer) HandleEvent(e broker.Event) error {
+	ctx, rpcMsg, err := s.newMessage(e)
+	if err != nil {
+		return err
+	}
+
+	return s.processMessage(ctx, rpcMsg)
+}
+
+// handleEvent returns the broker handler of a subscriber, which hands the messages
+// of its subscription to it alone rather than to every subscriber of the topic
+func (s *rpcServer) handleEvent(sb Subscriber) broker.Handler {
+	return func(e broker.Event) error {
+		ctx, rpcMsg, err := s.newMessage(e)
+		if err != nil {
+			return err
+		}
+		if sub, ok := sb.(*subscriber); ok {
+			ctx = context.WithValue(ctx, subscriberKey{}, sub)
+		}
+
+		return s.processMessage(ctx, rpcMsg)
+	}
+}
+
+// processMessage passes the message through the subscriber wrappers to the router
+func (s *rpcServer) processMessage(ctx context.Context, rpcMsg Message) error {
+	// existing router
+	r := Router(s.router)
+
+	// if the router is present then execute it
+	if s.opts.Router != nil {
+		// create a wrapped function
+		handler := s.opts.Router.ProcessMessage
+
+		// execute the wrapper for it
+		for i := len(s.opts.SubWrappers); i > 0; i-- {
+			handler = s.opts.SubWrappers[i-1](handler)
+		}
+
+		// set the router
+		r = rpcRouter{m: handler}
+	}
+
+	return r.ProcessMessage(ctx, rpcMsg)
+}
+
+// handleBatch returns the broker handler of a batch subscriber
+func (s *rpcServer) handleBatch(sb *subscriber) broker.BatchHandler {
+	return func(evs []broker.Event) error {
+		if len(evs) == 0 {
+			return nil
+		}
+
+		msgs := make([]Message, 0, len(evs))
+		for _, e := range evs {
+			_, msg, err := s.newMessage(e)
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, msg)
+		}
+
+		// the batch carries the header its messages share, e.g. tracing and auth
+		first := msgs[0].(*rpcMessage)
+		hdr := make(map[string]string, len(first.header))
+		for k, v := range first.header {
+			hdr[k] = v
+		}
+		for _, msg := range msgs[1:] {
+			for k, v := range hdr {
+				if msg.Header()[k] != v {
+					delete(hdr, k)
+				}
+			}
+		}
+
+		md := make(map[string]string, len(hdr))
+		for k, v := range hdr {
+			md[k] = v
+		}
+		ctx := metadata.NewContext(context.Background(), md)
+		ctx = context.WithValue(ctx, subscriberKey{}, sb)
+
+		// the messages of a wildcard subscriber may have different topics
+		if !sb.opts.Wildcard {
+			ctx = context.WithValue(ctx, topicKey{}, sb.topic)
+		}
+
+		return s.processMessage(ctx, &batchMessage{
+			rpcMessage: &rpcMessage{
+				topic:       sb.topic,
+				contentType: first.contentType,
+				payload:     msgs,
+				codec:       first.codec,
+				header:      hdr,
+			},
+			msgs: msgs,
+		})
+	}
+}
+
+// newMessage returns the message of the event and a context carrying its header as metadata
+func (s *rpcServer) newMessage(e broker.Event) (context.Context, *rpcMessage, error) {
 	// formatting horrible cruft
 	msg := e.Message()
 
@@ -84,7 +187,7 @@ func (s *rpcServer) HandleEvent(e broker.Event) error {
 	// get codec
 	cf, err := s.newCodec(ct)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// copy headers
@@ -100,33 +203,14 @@ func (s *rpcServer) HandleEvent(e broker.Event) error {
 	// Micro-Service means a request
 	// Micro-Topic means a message
 
-	rpcMsg := &rpcMessage{
+	return ctx, &rpcMessage{
 		topic:       msg.Header["Micro-Topic"],
 		contentType: ct,
 		payload:     &raw.Frame{Data: msg.Body},
 		codec:       cf,
 		header:      msg.Header,
 		body:        msg.Body,
-	}
-
-	// existing router
-	r := Router(s.router)
-
-	// if the router is present then execute it
-	if s.opts.Router != nil {
-		// create a wrapped function
-		handler := s.opts.Router.ProcessMessage
-
-		// execute the wrapper for it
-		for i := len(s.opts.SubWrappers); i > 0; i-- {
-			handler = s.opts.SubWrappers[i-1](handler)
-		}
-
-		// set the router
-		r = rpcRouter{m: handler}
-	}
-
-	return r.ProcessMessage(ctx, rpcMsg)
+	}, nil
 }
 
 // ServeConn serves a single connection
@@ -594,6 +678,7 @@ func (s *rpcServer) Register() error {
 		Id:       config.Name + "-" + config.Id,
 		Address:  addr,
 		Metadata: md,
+		Check:    config.HealthCheck,
 	}
 
 	node.Metadata["transport"] = config.Transport.String()
@@ -698,7 +783,37 @@ func (s *rpcServer) Register() error {
 			opts = append(opts, broker.DisableAutoAck())
 		}
 
-		sub, err := config.Broker.Subscribe(sb.Topic(), s.HandleEvent, opts...)
+		if sb.Options().Wildcard {
+			opts = append(opts, broker.Wildcard())
+		}
+
+		var sub broker.Subscriber
+		if size := sb.Options().BatchSize; size > 0 {
+			bsub, ok := sb.(*subscriber)
+			if !ok {
+				return fmt.Errorf("batch subscriber for %v not created by NewSubscriber", sb.Topic())
+			}
+			opts = append(opts, broker.BatchSize(size))
+			if wait := sb.Options().BatchWait; wait > 0 {
+				opts = append(opts, broker.BatchWait(wait))
+			}
+			sub, err = broker.SubscribeBatch(config.Broker, sb.Topic(), s.handleBatch(bsub), opts...)
+		} else if o := sb.Options(); o.MaxInFlight > 0 || o.Workers > 0 || o.RateLimit > 0 {
+			f := newFlow(o, s.handleEvent(sb))
+			// workers ack messages once they are handled
+			if o.Workers > 0 {
+				opts = append(opts, broker.DisableAutoAck())
+			}
+			sub, err = config.Broker.Subscribe(sb.Topic(), f.handle, opts...)
+			if err != nil {
+				f.stop()
+				return err
+			}
+			f.subscribed(sub)
+			sub = &flowSubscriber{Subscriber: sub, f: f}
+		} else {
+			sub, err = config.Broker.Subscribe(sb.Topic(), s.handleEvent(sb), opts...)
+		}
 		if err != nil {
 			return err
 		}
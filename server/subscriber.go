@@ -8,7 +8,8 @@ import (
 )
 
 const (
-	subSig = "func(context.Context, interface{}) error"
+	subSig      = "func(context.Context, interface{}) error"
+	batchSubSig = "func(context.Context, []interface{}) error"
 )
 
 type handler struct {
@@ -116,6 +117,9 @@ func validateSubscriber(sub Subscriber) error {
 		default:
 			return fmt.Errorf("subscriber %v takes wrong number of args: %v required signature %s", name, typ.NumIn(), subSig)
 		}
+		if err := validateBatch(sub, name, argType); err != nil {
+			return err
+		}
 		if !isExportedOrBuiltinType(argType) {
 			return fmt.Errorf("subscriber %v argument type not exported: %v", name, argType)
 		}
@@ -141,6 +145,9 @@ func validateSubscriber(sub Subscriber) error {
 					name, method.Name, method.Type.NumIn(), subSig)
 			}
 
+			if err := validateBatch(sub, name+"."+method.Name, argType); err != nil {
+				return err
+			}
 			if !isExportedOrBuiltinType(argType) {
 				return fmt.Errorf("%v argument type not exported: %v", name, argType)
 			}
@@ -158,6 +165,20 @@ func validateSubscriber(sub Subscriber) error {
 	return nil
 }
 
+// validateBatch checks batch subscribers take a slice of an exported type
+func validateBatch(sub Subscriber, name string, argType reflect.Type) error {
+	if sub.Options().BatchSize <= 0 {
+		return nil
+	}
+	if argType.Kind() != reflect.Slice {
+		return fmt.Errorf("batch subscriber %v argument type not a slice: %v required signature %s", name, argType, batchSubSig)
+	}
+	if !isExportedOrBuiltinType(argType.Elem()) {
+		return fmt.Errorf("batch subscriber %v argument type not exported: %v", name, argType)
+	}
+	return nil
+}
+
 func (s *subscriber) Topic() string {
 	return s.topic
 }
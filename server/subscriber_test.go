@@ -0,0 +1,358 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/metadata"
+	"go-micro.dev/v4/registry"
+	"go-micro.dev/v4/transport"
+)
+
+type WildcardOrder struct {
+	Id string `json:"id"`
+}
+
+func TestWildcardSubscriber(t *testing.T) {
+	b := broker.NewMemoryBroker()
+	srv := newRpcServer(
+		Name("test.wildcard"),
+		Address("127.0.0.1:0"),
+		Broker(b),
+		Registry(registry.NewMemoryRegistry()),
+		Transport(transport.NewHTTPTransport()),
+	)
+
+	received := make(chan string, 3)
+	sub := srv.NewSubscriber("orders.*", func(ctx context.Context, o *WildcardOrder) error {
+		topic, ok := TopicFromContext(ctx)
+		if !ok {
+			t.Errorf("Expected the topic in the handler context")
+		}
+		received <- topic + " " + o.Id
+		return nil
+	}, SubscriberWildcard())
+
+	if err := srv.Subscribe(sub); err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Unexpected start error: %v", err)
+	}
+	defer srv.Stop()
+
+	publish := func(topic, id string) {
+		err := b.Publish(topic, &broker.Message{
+			Header: map[string]string{
+				"Content-Type": "application/json",
+				"Micro-Topic":  topic,
+			},
+			Body: []byte(`{"id":"` + id + `"}`),
+		})
+		if err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+
+	publish("orders.created", "1")
+	publish("orders.eu.created", "2")
+	publish("payments.created", "3")
+	publish("orders.paid", "4")
+
+	for _, want := range []string{"orders.created 1", "orders.paid 4"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("Expected %q, got %q", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %q", want)
+		}
+	}
+
+	select {
+	case got := <-received:
+		t.Fatalf("Unexpected message %q", got)
+	default:
+	}
+}
+
+func TestWildcardAndExactSubscriber(t *testing.T) {
+	b := broker.NewMemoryBroker()
+	srv := newRpcServer(
+		Name("test.wildcard.exact"),
+		Address("127.0.0.1:0"),
+		Broker(b),
+		Registry(registry.NewMemoryRegistry()),
+		Transport(transport.NewHTTPTransport()),
+	)
+
+	received := make(chan string, 4)
+	subs := []Subscriber{
+		srv.NewSubscriber("orders.*", func(ctx context.Context, o *WildcardOrder) error {
+			received <- "wildcard " + o.Id
+			return nil
+		}, SubscriberWildcard()),
+		srv.NewSubscriber("orders.created", func(ctx context.Context, o *WildcardOrder) error {
+			received <- "exact " + o.Id
+			return nil
+		}),
+	}
+	for _, sub := range subs {
+		if err := srv.Subscribe(sub); err != nil {
+			t.Fatalf("Unexpected subscribe error: %v", err)
+		}
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Unexpected start error: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := b.Publish("orders.created", &broker.Message{
+		Header: map[string]string{
+			"Content-Type": "application/json",
+			"Micro-Topic":  "orders.created",
+		},
+		Body: []byte(`{"id":"1"}`),
+	}); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	// each subscriber handles the message once
+	got := make(map[string]int)
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-received:
+			got[r]++
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for the message, got %v", got)
+		}
+	}
+	select {
+	case r := <-received:
+		got[r]++
+	case <-time.After(time.Millisecond * 100):
+	}
+	if got["wildcard 1"] != 1 || got["exact 1"] != 1 || len(got) != 2 {
+		t.Fatalf("Expected each subscriber to handle the message once, got %v", got)
+	}
+}
+
+func TestFlowSubscriber(t *testing.T) {
+	b := broker.NewMemoryBroker()
+	srv := newRpcServer(
+		Name("test.flow"),
+		Address("127.0.0.1:0"),
+		Broker(b),
+		Registry(registry.NewMemoryRegistry()),
+		Transport(transport.NewHTTPTransport()),
+	)
+
+	received := make(chan string, 4)
+	subs := []Subscriber{
+		srv.NewSubscriber("orders.created", func(ctx context.Context, o *WildcardOrder) error {
+			received <- "workers " + o.Id
+			return nil
+		}, SubscriberWorkers(2)),
+		srv.NewSubscriber("orders.created", func(ctx context.Context, o *WildcardOrder) error {
+			received <- "plain " + o.Id
+			return nil
+		}),
+	}
+	for _, sub := range subs {
+		if err := srv.Subscribe(sub); err != nil {
+			t.Fatalf("Unexpected subscribe error: %v", err)
+		}
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Unexpected start error: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := b.Publish("orders.created", &broker.Message{
+		Header: map[string]string{
+			"Content-Type": "application/json",
+			"Micro-Topic":  "orders.created",
+		},
+		Body: []byte(`{"id":"1"}`),
+	}); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	// the flow only hands the message to its own subscriber
+	got := make(map[string]int)
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-received:
+			got[r]++
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for the message, got %v", got)
+		}
+	}
+	select {
+	case r := <-received:
+		got[r]++
+	case <-time.After(time.Millisecond * 100):
+	}
+	if got["workers 1"] != 1 || got["plain 1"] != 1 || len(got) != 2 {
+		t.Fatalf("Expected each subscriber to handle the message once, got %v", got)
+	}
+}
+
+type BatchReading struct {
+	Value int `json:"value"`
+}
+
+func TestBatchSubscriber(t *testing.T) {
+	b := broker.NewMemoryBroker()
+	srv := newRpcServer(
+		Name("test.batch"),
+		Address("127.0.0.1:0"),
+		Broker(b),
+		Registry(registry.NewMemoryRegistry()),
+		Transport(transport.NewHTTPTransport()),
+	)
+
+	batches := make(chan []*BatchReading, 3)
+	sub := srv.NewSubscriber("telemetry", func(ctx context.Context, r []*BatchReading) error {
+		batches <- r
+		return nil
+	}, SubscriberBatch(2, time.Millisecond*100))
+
+	// other subscribers to the topic still receive messages one by one
+	single := make(chan *BatchReading, 3)
+	ssub := srv.NewSubscriber("telemetry", func(ctx context.Context, r *BatchReading) error {
+		single <- r
+		return nil
+	})
+
+	for _, s := range []Subscriber{sub, ssub} {
+		if err := srv.Subscribe(s); err != nil {
+			t.Fatalf("Unexpected subscribe error: %v", err)
+		}
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Unexpected start error: %v", err)
+	}
+	defer srv.Stop()
+
+	var msgs []*broker.Message
+	for _, v := range []string{"1", "2", "3"} {
+		msgs = append(msgs, &broker.Message{
+			Header: map[string]string{
+				"Content-Type": "application/json",
+				"Micro-Topic":  "telemetry",
+			},
+			Body: []byte(`{"value":` + v + `}`),
+		})
+	}
+	if err := broker.PublishBatch(b, "telemetry", msgs); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	// a full batch then the rest once it has waited
+	for _, want := range [][]int{{1, 2}, {3}} {
+		select {
+		case got := <-batches:
+			if len(got) != len(want) {
+				t.Fatalf("Expected a batch of %d, got %d", len(want), len(got))
+			}
+			for i, r := range got {
+				if r.Value != want[i] {
+					t.Fatalf("Expected %v, got %v", want[i], r.Value)
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for batch %v", want)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-single:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for a single message")
+		}
+	}
+
+	select {
+	case got := <-single:
+		t.Fatalf("Unexpected message %v", got)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestBatchSubscriberWrapper(t *testing.T) {
+	b := broker.NewMemoryBroker()
+
+	type seen struct {
+		header, md map[string]string
+	}
+	wrapped := make(chan seen, 1)
+	srv := newRpcServer(
+		Name("test.batch.wrapper"),
+		Address("127.0.0.1:0"),
+		Broker(b),
+		Registry(registry.NewMemoryRegistry()),
+		Transport(transport.NewHTTPTransport()),
+		WrapSubscriber(func(fn SubscriberFunc) SubscriberFunc {
+			return func(ctx context.Context, msg Message) error {
+				md, _ := metadata.FromContext(ctx)
+				wrapped <- seen{header: msg.Header(), md: md}
+				return fn(ctx, msg)
+			}
+		}),
+	)
+
+	sub := srv.NewSubscriber("telemetry", func(ctx context.Context, r []*BatchReading) error {
+		return nil
+	}, SubscriberBatch(2, time.Second))
+	if err := srv.Subscribe(sub); err != nil {
+		t.Fatalf("Unexpected subscribe error: %v", err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Unexpected start error: %v", err)
+	}
+	defer srv.Stop()
+
+	var msgs []*broker.Message
+	for _, v := range []string{"1", "2"} {
+		msgs = append(msgs, &broker.Message{
+			Header: map[string]string{
+				"Content-Type": "application/json",
+				"Micro-Topic":  "telemetry",
+				"Micro-Id":     v,
+				"X-Trace":      "abc",
+			},
+			Body: []byte(`{"value":` + v + `}`),
+		})
+	}
+	if err := broker.PublishBatch(b, "telemetry", msgs); err != nil {
+		t.Fatalf("Unexpected publish error: %v", err)
+	}
+
+	// the wrappers see the header the messages share, in the message and the context
+	select {
+	case got := <-wrapped:
+		if got.header["X-Trace"] != "abc" || got.md["X-Trace"] != "abc" {
+			t.Fatalf("Expected the shared header, got %v and %v", got.header, got.md)
+		}
+		if _, ok := got.header["Micro-Id"]; ok {
+			t.Fatalf("Expected the header of a single message to be left out, got %v", got.header)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the batch")
+	}
+}
+
+func TestBatchSubscriberValidation(t *testing.T) {
+	srv := newRpcServer()
+	sub := srv.NewSubscriber("telemetry", func(ctx context.Context, r *BatchReading) error {
+		return nil
+	}, SubscriberBatch(10, time.Second))
+	if err := srv.Subscribe(sub); err == nil {
+		t.Fatal("Expected an error subscribing a batch subscriber not taking a slice")
+	}
+}
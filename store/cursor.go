@@ -0,0 +1,48 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// Cursor is the position a page of results ended at. It is passed
+// between pages as an opaque token, see ListCursor and ReadCursor.
+type Cursor struct {
+	// Key of the last record returned
+	Key string `json:"k"`
+	// Value is the indexed value of the last record when reading through an index
+	Value interface{} `json:"v,omitempty"`
+}
+
+// String encodes the cursor as an opaque token
+func (c *Cursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Seen reports whether a record at the given index value and key was
+// returned before the cursor. Records are ordered by value then key.
+func (c *Cursor) Seen(value interface{}, key string, o Order) bool {
+	v := CompareValues(value, c.Value)
+	if v == 0 {
+		v = strings.Compare(key, c.Key)
+	}
+	if o == OrderDesc {
+		return v >= 0
+	}
+	return v <= 0
+}
+
+// ParseCursor decodes a token returned by a previous page
+func ParseCursor(token string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &Cursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
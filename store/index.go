@@ -0,0 +1,137 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Index 是 table 中记录的二级索引
+type Index struct {
+	// Name of the index, used by ReadIndex
+	Name string
+	// Field is the JSON field in Record.Value to index.
+	// Nested fields are separated by dots e.g "user.email"
+	Field string
+	// Metadata is the Record.Metadata key to index, used instead of Field
+	Metadata string
+}
+
+// Value returns the indexed value of the record and whether the record has one.
+// Only scalar values (strings, numbers and bools) are indexed.
+func (i Index) Value(r *Record) (interface{}, bool) {
+	var v interface{}
+
+	if len(i.Metadata) > 0 {
+		v = r.Metadata[i.Metadata]
+	} else {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(r.Value, &doc); err != nil {
+			return nil, false
+		}
+		v = doc
+		for _, f := range strings.Split(i.Field, ".") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			v = m[f]
+		}
+	}
+
+	switch v.(type) {
+	case string, bool, float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// Order of the records returned by an index query
+type Order int
+
+const (
+	// OrderAsc returns records in ascending order of the indexed value
+	OrderAsc Order = iota
+	// OrderDesc returns records in descending order of the indexed value
+	OrderDesc
+)
+
+// CompareValues orders indexed values. Numbers are compared numerically
+// and anything else by its string form.
+func CompareValues(a, b interface{}) int {
+	fa, aok := number(a)
+	fb, bok := number(b)
+	switch {
+	case aok && bok:
+		if fa < fb {
+			return -1
+		} else if fa > fb {
+			return 1
+		}
+		return 0
+	case aok:
+		// numbers sort before anything else
+		return -1
+	case bok:
+		return 1
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// IndexKey returns the key of an indexed value, its type and string form, so
+// stores keep the string "1" apart from the number 1. Numbers of any type
+// with the same value have the same key.
+func IndexKey(value interface{}) string {
+	if n, ok := number(value); ok {
+		return "n:" + strconv.FormatFloat(n, 'g', -1, 64)
+	}
+	if b, ok := value.(bool); ok {
+		return "b:" + strconv.FormatBool(b)
+	}
+	return "s:" + fmt.Sprint(value)
+}
+
+// MatchKeys returns the keys of the indexed values whose string form is key,
+// a string, number or bool, used to look up an index by the key of ReadIndex
+func MatchKeys(key string) []string {
+	keys := []string{IndexKey(key)}
+	if f, err := strconv.ParseFloat(key, 64); err == nil && strconv.FormatFloat(f, 'g', -1, 64) == key {
+		keys = append(keys, IndexKey(f))
+	}
+	if key == "true" || key == "false" {
+		keys = append(keys, IndexKey(key == "true"))
+	}
+	return keys
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -2,14 +2,19 @@ package store
 
 import (
 	"path/filepath"
-	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
 )
 
+// expiryInterval is how often expired records are removed from the memory store,
+// notifying their watchers
+var expiryInterval = time.Second
+
 // NewMemoryStore returns a memory store
 func NewMemoryStore(opts ...Option) Store {
 	s := &memoryStore{
@@ -17,31 +22,67 @@ func NewMemoryStore(opts ...Option) Store {
 			Database: "micro",
 			Table:    "micro",
 		},
-		store: cache.New(cache.NoExpiration, 5*time.Minute),
+		// expired records are removed by sweep rather than the cache janitor,
+		// so they are evicted under the store lock
+		store:    cache.New(cache.NoExpiration, 0),
+		keys:     newMemoryKeys(),
+		watchers: make(map[string]*memoryWatcher),
+		indexes:  make(map[string]map[string]*memoryIndex),
+		versions: make(map[string]uint64),
 	}
 	for _, o := range opts {
 		o(&s.options)
 	}
+	s.store.OnEvicted(s.evicted)
 	return s
 }
 
 type memoryStore struct {
 	options Options
 
+	// serialises writes so versions and batches are consistent
+	sync.Mutex
 	store *cache.Cache
+	// sweeping is set while expired records are being swept
+	sweeping bool
+	// keys of each table in order
+	keys *memoryKeys
+	// versions maps a key to the version of its last write, kept once it's
+	// deleted or expired so its versions never repeat
+	versions map[string]uint64
+
+	watchLock sync.RWMutex
+	watchers  map[string]*memoryWatcher
+
+	indexLock sync.RWMutex
+	// indexes maps a table prefix and index name to the index
+	indexes map[string]map[string]*memoryIndex
 }
 
 type storeRecord struct {
+	table     string
 	key       string
 	value     []byte
 	metadata  map[string]interface{}
 	expiresAt time.Time
+	version   uint64
 }
 
 func (m *memoryStore) key(prefix, key string) string {
 	return filepath.Join(prefix, key)
 }
 
+// tableKey returns the key as listed in the table
+func (m *memoryStore) tableKey(prefix, key string) string {
+	return strings.TrimPrefix(m.key(prefix, key), prefix+"/")
+}
+
+// found reports whether the key of the table is stored and not expired
+func (m *memoryStore) found(prefix, key string) bool {
+	_, found := m.store.Get(m.key(prefix, key))
+	return found
+}
+
 func (m *memoryStore) prefix(database, table string) string {
 	if len(database) == 0 {
 		database = m.options.Database
@@ -67,25 +108,43 @@ func (m *memoryStore) get(prefix, key string) (*Record, error) {
 	}
 
 	// Copy the record on the way out
+	return storedRecord.record(), nil
+}
+
+// record returns a copy of the stored record
+func (s *storeRecord) record() *Record {
 	newRecord := &Record{}
-	newRecord.Key = strings.TrimPrefix(storedRecord.key, prefix+"/")
-	newRecord.Value = make([]byte, len(storedRecord.value))
+	newRecord.Key = s.key
+	newRecord.Value = make([]byte, len(s.value))
 	newRecord.Metadata = make(map[string]interface{})
+	newRecord.Version = s.version
 
 	// copy the value into the new record
-	copy(newRecord.Value, storedRecord.value)
+	copy(newRecord.Value, s.value)
 
 	// check if we need to set the expiry
-	if !storedRecord.expiresAt.IsZero() {
-		newRecord.Expiry = time.Until(storedRecord.expiresAt)
+	if !s.expiresAt.IsZero() {
+		newRecord.Expiry = time.Until(s.expiresAt)
 	}
 
 	// copy in the metadata
-	for k, v := range storedRecord.metadata {
+	for k, v := range s.metadata {
 		newRecord.Metadata[k] = v
 	}
 
-	return newRecord, nil
+	return newRecord
+}
+
+func (m *memoryStore) version(key string) uint64 {
+	r, found := m.store.Get(key)
+	if !found {
+		return 0
+	}
+	storedRecord, ok := r.(*storeRecord)
+	if !ok {
+		return 0
+	}
+	return storedRecord.version
 }
 
 func (m *memoryStore) set(prefix string, r *Record) {
@@ -94,10 +153,14 @@ func (m *memoryStore) set(prefix string, r *Record) {
 	// copy the incoming record and then
 	// convert the expiry in to a hard timestamp
 	i := &storeRecord{}
+	i.table = prefix
 	i.key = r.Key
 	i.value = make([]byte, len(r.Value))
 	i.metadata = make(map[string]interface{})
 
+	m.versions[key]++
+	i.version = m.versions[key]
+
 	// copy the the value
 	copy(i.value, r.Value)
 
@@ -112,49 +175,165 @@ func (m *memoryStore) set(prefix string, r *Record) {
 	}
 
 	m.store.Set(key, i, r.Expiry)
+	if r.Expiry != 0 && !m.sweeping {
+		m.sweeping = true
+		go m.sweep(expiryInterval)
+	}
+	m.keys.add(prefix, m.tableKey(prefix, r.Key))
+	m.index(prefix, r)
+	m.notify(key, Put, i.record())
 }
 
 func (m *memoryStore) delete(prefix, key string) {
+	m.unindex(prefix, key)
+	m.keys.remove(prefix, m.tableKey(prefix, key))
 	key = m.key(prefix, key)
+	r, found := m.store.Get(key)
 	m.store.Delete(key)
+	if !found {
+		return
+	}
+	if storedRecord, ok := r.(*storeRecord); ok {
+		m.notify(key, Delete, &Record{Key: storedRecord.key})
+	}
 }
 
+// evicted is called by the cache when an item is deleted or expired, always
+// under the store lock as only delete and sweep remove items
+func (m *memoryStore) evicted(key string, v interface{}) {
+	storedRecord, ok := v.(*storeRecord)
+	if !ok || storedRecord.expiresAt.IsZero() || storedRecord.expiresAt.After(time.Now()) {
+		return
+	}
+	m.unindex(storedRecord.table, storedRecord.key)
+	m.keys.remove(storedRecord.table, m.tableKey(storedRecord.table, storedRecord.key))
+	m.notify(key, Expire, &Record{Key: storedRecord.key})
+}
+
+// sweep removes the expired records every interval, until none are left to expire
+func (m *memoryStore) sweep(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for range t.C {
+		m.Lock()
+		m.store.DeleteExpired()
+
+		expiring := false
+		for _, item := range m.store.Items() {
+			if storedRecord, ok := item.Object.(*storeRecord); ok && !storedRecord.expiresAt.IsZero() {
+				expiring = true
+				break
+			}
+		}
+		if !expiring {
+			m.sweeping = false
+		}
+		m.Unlock()
+
+		if !expiring {
+			return
+		}
+	}
+}
+
+// notify sends the event to every watcher of key, it never blocks
+func (m *memoryStore) notify(key string, t EventType, r *Record) {
+	e := &Event{
+		Type:      t,
+		Record:    r,
+		Timestamp: time.Now(),
+	}
+
+	m.watchLock.RLock()
+	defer m.watchLock.RUnlock()
+
+	for _, w := range m.watchers {
+		if w.match(key) {
+			w.send(e)
+		}
+	}
+}
+
+// list returns the sorted keys of the table from offset, up to limit
 func (m *memoryStore) list(prefix string, limit, offset uint) []string {
-	allItems := m.store.Items()
-	allKeys := make([]string, len(allItems))
-	i := 0
+	var keys []string
+	m.keys.scan(prefix, "", func(k string) bool {
+		if !m.found(prefix, k) {
+			// expired but not yet evicted
+			return true
+		}
+		if offset > 0 {
+			offset--
+			return true
+		}
+		keys = append(keys, k)
+		return limit == 0 || uint(len(keys)) < limit
+	})
+	return keys
+}
 
-	for k := range allItems {
-		if !strings.HasPrefix(k, prefix+"/") {
-			continue
+// page returns the sorted keys after the cursor, up to limit, and the cursor of
+// the next page. It seeks to the cursor so walking every page reads each key once.
+func (m *memoryStore) page(prefix, keyPrefix, keySuffix, cursor string, limit uint) ([]string, string, error) {
+	from := keyPrefix
+	var after *Cursor
+	if len(cursor) > 0 {
+		c, err := ParseCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = c
+		if c.Key > from {
+			from = c.Key
 		}
-		allKeys[i] = strings.TrimPrefix(k, prefix+"/")
-		i++
 	}
 
-	if limit != 0 || offset != 0 {
-		sort.Slice(allKeys, func(i, j int) bool { return allKeys[i] < allKeys[j] })
-		min := func(i, j uint) uint {
-			if i < j {
-				return i
-			}
-			return j
+	// read one key past the limit to know whether there is a next page
+	var keys []string
+	m.keys.scan(prefix, from, func(k string) bool {
+		if after != nil && k <= after.Key {
+			return true
 		}
-		return allKeys[offset:min(limit, uint(len(allKeys)))]
+		if !strings.HasPrefix(k, keyPrefix) {
+			// the keys with the prefix are all behind
+			return false
+		}
+		if !strings.HasSuffix(k, keySuffix) || !m.found(prefix, k) {
+			return true
+		}
+		keys = append(keys, k)
+		return limit == 0 || uint(len(keys)) <= limit
+	})
+
+	if limit == 0 || uint(len(keys)) <= limit {
+		return keys, "", nil
 	}
 
-	return allKeys
+	keys = keys[:limit]
+	next := &Cursor{Key: keys[len(keys)-1]}
+	return keys, next.String(), nil
 }
 
 func (m *memoryStore) Close() error {
+	m.Lock()
+	defer m.Unlock()
 	m.store.Flush() // Delete all items from the cache.
+	m.keys.reset()
+	m.indexLock.Lock()
+	m.indexes = make(map[string]map[string]*memoryIndex)
+	m.indexLock.Unlock()
 	return nil
 }
 
 func (m *memoryStore) Init(opts ...Option) error {
+	m.Lock()
+	defer m.Unlock()
 	for _, o := range opts {
 		o(&m.options)
 	}
+	// index the records written before the indexes were declared
+	m.reindex()
 	return nil
 }
 
@@ -170,34 +349,47 @@ func (m *memoryStore) Read(key string, opts ...ReadOption) ([]*Record, error) {
 
 	prefix := m.prefix(readOpts.Database, readOpts.Table)
 
+	if len(readOpts.Index) > 0 {
+		return m.query(prefix, key, readOpts)
+	}
+
 	var keys []string
 
 	// Handle Prefix / suffix
-	if readOpts.Prefix || readOpts.Suffix {
-		k := m.list(prefix, 0, 0)
-		limit := int(readOpts.Limit)
-		offset := int(readOpts.Offset)
-
-		if limit > len(k) {
-			limit = len(k)
+	if (readOpts.Prefix || readOpts.Suffix) && (len(readOpts.Cursor) > 0 || readOpts.Next != nil) {
+		var keyPrefix, keySuffix string
+		if readOpts.Prefix {
+			keyPrefix = key
 		}
-
-		if offset > len(k) {
-			offset = len(k)
+		if readOpts.Suffix {
+			keySuffix = key
 		}
+		k, next, err := m.page(prefix, keyPrefix, keySuffix, readOpts.Cursor, readOpts.Limit)
+		if err != nil {
+			return nil, err
+		}
+		if readOpts.Next != nil {
+			*readOpts.Next = next
+		}
+		keys = k
+	} else if readOpts.Prefix || readOpts.Suffix {
+		var keyPrefix, keySuffix string
+		if readOpts.Prefix {
+			keyPrefix = key
+		}
+		if readOpts.Suffix {
+			keySuffix = key
+		}
+		keys, _, _ = m.page(prefix, keyPrefix, keySuffix, "", 0)
 
-		for i := offset; i < limit; i++ {
-			kk := k[i]
-
-			if readOpts.Prefix && !strings.HasPrefix(kk, key) {
-				continue
-			}
-
-			if readOpts.Suffix && !strings.HasSuffix(kk, key) {
-				continue
-			}
-
-			keys = append(keys, kk)
+		// apply the offset and limit to the matching keys
+		offset := int(readOpts.Offset)
+		if offset > len(keys) {
+			offset = len(keys)
+		}
+		keys = keys[offset:]
+		if limit := int(readOpts.Limit); limit > 0 && limit < len(keys) {
+			keys = keys[:limit]
 		}
 	} else {
 		keys = []string{key}
@@ -224,6 +416,9 @@ func (m *memoryStore) Write(r *Record, opts ...WriteOption) error {
 
 	prefix := m.prefix(writeOpts.Database, writeOpts.Table)
 
+	m.Lock()
+	defer m.Unlock()
+
 	if len(opts) > 0 {
 		// Copy the record before applying options, or the incoming record will be mutated
 		newRecord := Record{}
@@ -261,10 +456,76 @@ func (m *memoryStore) Delete(key string, opts ...DeleteOption) error {
 	}
 
 	prefix := m.prefix(deleteOptions.Database, deleteOptions.Table)
+
+	m.Lock()
 	m.delete(prefix, key)
+	m.Unlock()
 	return nil
 }
 
+func (m *memoryStore) Batch(ops []Op, opts ...BatchOption) error {
+	batchOpts := BatchOptions{}
+	for _, o := range opts {
+		o(&batchOpts)
+	}
+
+	prefix := m.prefix(batchOpts.Database, batchOpts.Table)
+
+	m.Lock()
+	defer m.Unlock()
+
+	// check every version before applying anything
+	for _, op := range ops {
+		if op.Record == nil {
+			return errors.New("batch operation has no record")
+		}
+		if !op.Compare {
+			continue
+		}
+		if m.version(m.key(prefix, op.Record.Key)) != op.Record.Version {
+			return ErrConflict
+		}
+	}
+
+	for _, op := range ops {
+		switch op.Type {
+		case OpWrite:
+			m.set(prefix, op.Record)
+		case OpDelete:
+			m.delete(prefix, op.Record.Key)
+		}
+	}
+
+	return nil
+}
+
+func (m *memoryStore) Watch(prefix string, opts ...WatchOption) (Watcher, error) {
+	watchOpts := WatchOptions{}
+	for _, o := range opts {
+		o(&watchOpts)
+	}
+
+	w := &memoryWatcher{
+		id:       uuid.New().String(),
+		table:    m.prefix(watchOpts.Database, watchOpts.Table),
+		prefix:   prefix,
+		events:   make(chan *Event, 100),
+		exit:     make(chan bool),
+		overflow: make(chan bool),
+	}
+	w.remove = func() {
+		m.watchLock.Lock()
+		delete(m.watchers, w.id)
+		m.watchLock.Unlock()
+	}
+
+	m.watchLock.Lock()
+	m.watchers[w.id] = w
+	m.watchLock.Unlock()
+
+	return w, nil
+}
+
 func (m *memoryStore) Options() Options {
 	return m.options
 }
@@ -277,26 +538,31 @@ func (m *memoryStore) List(opts ...ListOption) ([]string, error) {
 	}
 
 	prefix := m.prefix(listOptions.Database, listOptions.Table)
-	keys := m.list(prefix, listOptions.Limit, listOptions.Offset)
 
-	if len(listOptions.Prefix) > 0 {
-		var prefixKeys []string
-		for _, k := range keys {
-			if strings.HasPrefix(k, listOptions.Prefix) {
-				prefixKeys = append(prefixKeys, k)
-			}
+	if len(listOptions.Cursor) > 0 || listOptions.Next != nil {
+		keys, next, err := m.page(prefix, listOptions.Prefix, listOptions.Suffix, listOptions.Cursor, listOptions.Limit)
+		if err != nil {
+			return nil, err
 		}
-		keys = prefixKeys
+		if listOptions.Next != nil {
+			*listOptions.Next = next
+		}
+		return keys, nil
 	}
 
-	if len(listOptions.Suffix) > 0 {
-		var suffixKeys []string
-		for _, k := range keys {
-			if strings.HasSuffix(k, listOptions.Suffix) {
-				suffixKeys = append(suffixKeys, k)
-			}
-		}
-		keys = suffixKeys
+	if len(listOptions.Prefix) == 0 && len(listOptions.Suffix) == 0 {
+		return m.list(prefix, listOptions.Limit, listOptions.Offset), nil
+	}
+
+	// apply the offset and limit to the matching keys
+	keys, _, _ := m.page(prefix, listOptions.Prefix, listOptions.Suffix, "", 0)
+	offset := int(listOptions.Offset)
+	if offset > len(keys) {
+		offset = len(keys)
+	}
+	keys = keys[offset:]
+	if limit := int(listOptions.Limit); limit > 0 && limit < len(keys) {
+		keys = keys[:limit]
 	}
 
 	return keys, nil
@@ -0,0 +1,317 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// memoryIndex is a secondary index over the records in a table
+type memoryIndex struct {
+	// keys maps a record key to the index key of its indexed value
+	keys map[string]string
+	// values maps the index key of an indexed value to the keys holding it
+	values map[string]*memoryIndexValue
+	// sorted is the indexed values in order, so queries seek to where they start
+	sorted []*memoryIndexValue
+}
+
+type memoryIndexValue struct {
+	value interface{}
+	// str is the string form of the value queries match
+	str  string
+	keys map[string]bool
+}
+
+func newMemoryIndex() *memoryIndex {
+	return &memoryIndex{
+		keys:   make(map[string]string),
+		values: make(map[string]*memoryIndexValue),
+	}
+}
+
+func (i *memoryIndex) add(key string, value interface{}) {
+	i.remove(key)
+
+	s := IndexKey(value)
+	v, ok := i.values[s]
+	if !ok {
+		v = &memoryIndexValue{
+			value: value,
+			str:   fmt.Sprint(value),
+			keys:  make(map[string]bool),
+		}
+		i.values[s] = v
+
+		n := i.search(value)
+		i.sorted = append(i.sorted, nil)
+		copy(i.sorted[n+1:], i.sorted[n:])
+		i.sorted[n] = v
+	}
+	v.keys[key] = true
+	i.keys[key] = s
+}
+
+func (i *memoryIndex) remove(key string) {
+	s, ok := i.keys[key]
+	if !ok {
+		return
+	}
+	delete(i.keys, key)
+
+	v := i.values[s]
+	delete(v.keys, key)
+	if len(v.keys) != 0 {
+		return
+	}
+	delete(i.values, s)
+
+	// values of different keys may compare equal, look for this one among them
+	for n := i.search(v.value); n < len(i.sorted); n++ {
+		if i.sorted[n] == v {
+			i.sorted = append(i.sorted[:n], i.sorted[n+1:]...)
+			break
+		}
+	}
+}
+
+// search returns the position of the first sorted value not before value
+func (i *memoryIndex) search(value interface{}) int {
+	return sort.Search(len(i.sorted), func(n int) bool {
+		return CompareValues(i.sorted[n].value, value) >= 0
+	})
+}
+
+// memoryIndexEntry is a record key and its indexed value
+type memoryIndexEntry struct {
+	value interface{}
+	key   string
+}
+
+// match returns the indexed values matching key in ascending order, numbers
+// being matched apart as they don't sort by their string form
+func (i *memoryIndex) match(key string, opts ReadOptions, after *Cursor) [][]*memoryIndexValue {
+	if !opts.Prefix && !opts.Suffix {
+		// the key matches a string, number or bool of the same string form
+		var values []*memoryIndexValue
+		for _, c := range MatchKeys(key) {
+			if v, ok := i.values[c]; ok && v.str == key {
+				values = append(values, v)
+			}
+		}
+		sort.Slice(values, func(a, b int) bool {
+			return CompareValues(values[a].value, values[b].value) < 0
+		})
+		return [][]*memoryIndexValue{values}
+	}
+
+	numbers := sort.Search(len(i.sorted), func(n int) bool {
+		_, ok := number(i.sorted[n].value)
+		return !ok
+	})
+
+	matches := func(v *memoryIndexValue) bool {
+		return (!opts.Prefix || strings.HasPrefix(v.str, key)) && (!opts.Suffix || strings.HasSuffix(v.str, key))
+	}
+
+	var values []*memoryIndexValue
+	for _, v := range i.sorted[:numbers] {
+		if matches(v) {
+			values = append(values, v)
+		}
+	}
+
+	if opts.Suffix {
+		for _, v := range i.sorted[numbers:] {
+			if matches(v) {
+				values = append(values, v)
+			}
+		}
+		return [][]*memoryIndexValue{values}
+	}
+
+	// other values sort by their string form, those with the prefix are together
+	rest := i.sorted[numbers:]
+	lo := sort.Search(len(rest), func(n int) bool { return rest[n].str >= key })
+	hi := lo + sort.Search(len(rest)-lo, func(n int) bool { return !strings.HasPrefix(rest[lo+n].str, key) })
+	run := rest[lo:hi]
+
+	// skip the values before the cursor
+	if after != nil {
+		if opts.Order == OrderDesc {
+			run = run[:sort.Search(len(run), func(n int) bool { return CompareValues(run[n].value, after.Value) > 0 })]
+		} else {
+			run = run[sort.Search(len(run), func(n int) bool { return CompareValues(run[n].value, after.Value) >= 0 }):]
+		}
+	}
+
+	return [][]*memoryIndexValue{values, run}
+}
+
+// scan calls fn with the entries whose indexed value matches key, in order
+// from the cursor, until it returns false
+func (i *memoryIndex) scan(key string, opts ReadOptions, after *Cursor, fn func(memoryIndexEntry) bool) {
+	parts := i.match(key, opts, after)
+	desc := opts.Order == OrderDesc
+
+	each := func(v *memoryIndexValue) bool {
+		// records with the same value are ordered by key
+		vk := make([]string, 0, len(v.keys))
+		for k := range v.keys {
+			vk = append(vk, k)
+		}
+		sort.Strings(vk)
+		if desc {
+			sort.Sort(sort.Reverse(sort.StringSlice(vk)))
+		}
+		for _, k := range vk {
+			if after != nil && after.Seen(v.value, k, opts.Order) {
+				continue
+			}
+			if !fn(memoryIndexEntry{value: v.value, key: k}) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for p := range parts {
+		if desc {
+			p = len(parts) - 1 - p
+		}
+		values := parts[p]
+		for n := range values {
+			if desc {
+				n = len(values) - 1 - n
+			}
+			if !each(values[n]) {
+				return
+			}
+		}
+	}
+}
+
+// index adds the record to every index of the table
+func (m *memoryStore) index(table string, r *Record) {
+	if len(m.options.Indexes) == 0 {
+		return
+	}
+
+	m.indexLock.Lock()
+	defer m.indexLock.Unlock()
+
+	indexes, ok := m.indexes[table]
+	if !ok {
+		indexes = make(map[string]*memoryIndex)
+		m.indexes[table] = indexes
+	}
+
+	for _, idx := range m.options.Indexes {
+		i, ok := indexes[idx.Name]
+		if !ok {
+			i = newMemoryIndex()
+			indexes[idx.Name] = i
+		}
+		if v, ok := idx.Value(r); ok {
+			i.add(r.Key, v)
+		} else {
+			i.remove(r.Key)
+		}
+	}
+}
+
+// reindex builds the indexes of every table from the records stored, so
+// indexes declared after records are written cover them. It is called locked.
+func (m *memoryStore) reindex() {
+	m.indexLock.Lock()
+	m.indexes = make(map[string]map[string]*memoryIndex)
+	m.indexLock.Unlock()
+
+	for _, item := range m.store.Items() {
+		if r, ok := item.Object.(*storeRecord); ok {
+			m.index(r.table, r.record())
+		}
+	}
+}
+
+// unindex removes the key from every index of the table
+func (m *memoryStore) unindex(table, key string) {
+	m.indexLock.Lock()
+	defer m.indexLock.Unlock()
+
+	for _, i := range m.indexes[table] {
+		i.remove(key)
+	}
+}
+
+// query reads the records matching key through an index
+func (m *memoryStore) query(prefix, key string, opts ReadOptions) ([]*Record, error) {
+	declared := false
+	for _, idx := range m.options.Indexes {
+		if idx.Name == opts.Index {
+			declared = true
+			break
+		}
+	}
+	if !declared {
+		return nil, ErrIndexNotFound
+	}
+
+	var after *Cursor
+	if len(opts.Cursor) > 0 {
+		c, err := ParseCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+
+	var results []*Record
+	var next string
+	var err error
+	offset := opts.Offset
+	if after != nil {
+		offset = 0
+	}
+
+	// a cursor is returned once there's an entry after the last result
+	var last *Cursor
+
+	m.indexLock.RLock()
+	if i, ok := m.indexes[prefix][opts.Index]; ok {
+		i.scan(key, opts, after, func(e memoryIndexEntry) bool {
+			if last != nil {
+				next = last.String()
+				return false
+			}
+			r, gerr := m.get(prefix, e.key)
+			if gerr == ErrNotFound {
+				// expired but not yet evicted
+				return true
+			} else if gerr != nil {
+				err = gerr
+				return false
+			}
+			if offset > 0 {
+				offset--
+				return true
+			}
+			results = append(results, r)
+			if opts.Limit > 0 && uint(len(results)) == opts.Limit {
+				last = &Cursor{Key: e.key, Value: e.value}
+			}
+			return true
+		})
+	}
+	m.indexLock.RUnlock()
+
+	if err != nil {
+		return results, err
+	}
+
+	if opts.Next != nil {
+		*opts.Next = next
+	}
+
+	return results, nil
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"sort"
+	"sync"
+)
+
+// memoryKeys keeps the keys of each table sorted, so listing and paging seek
+// to where they start rather than sorting every key of the store
+type memoryKeys struct {
+	sync.RWMutex
+	// tables maps a table prefix to its sorted keys
+	tables map[string][]string
+}
+
+func newMemoryKeys() *memoryKeys {
+	return &memoryKeys{tables: make(map[string][]string)}
+}
+
+func (m *memoryKeys) add(table, key string) {
+	m.Lock()
+	defer m.Unlock()
+
+	keys := m.tables[table]
+	i := sort.SearchStrings(keys, key)
+	if i < len(keys) && keys[i] == key {
+		return
+	}
+	keys = append(keys, "")
+	copy(keys[i+1:], keys[i:])
+	keys[i] = key
+	m.tables[table] = keys
+}
+
+func (m *memoryKeys) remove(table, key string) {
+	m.Lock()
+	defer m.Unlock()
+
+	keys := m.tables[table]
+	i := sort.SearchStrings(keys, key)
+	if i == len(keys) || keys[i] != key {
+		return
+	}
+	keys = append(keys[:i], keys[i+1:]...)
+	if len(keys) == 0 {
+		delete(m.tables, table)
+		return
+	}
+	m.tables[table] = keys
+}
+
+// scan calls fn with the keys of the table from the first not before from,
+// in order, until it returns false
+func (m *memoryKeys) scan(table, from string, fn func(key string) bool) {
+	m.RLock()
+	defer m.RUnlock()
+
+	keys := m.tables[table]
+	for i := sort.SearchStrings(keys, from); i < len(keys); i++ {
+		if !fn(keys[i]) {
+			return
+		}
+	}
+}
+
+func (m *memoryKeys) reset() {
+	m.Lock()
+	m.tables = make(map[string][]string)
+	m.Unlock()
+}
@@ -0,0 +1,297 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func recordKeys(records []*Record) string {
+	var k []string
+	for _, r := range records {
+		k = append(k, r.Key)
+	}
+	return strings.Join(k, ",")
+}
+
+func TestNoopBatch(t *testing.T) {
+	s := NewNoopStore()
+	if err := s.Batch([]Op{DeleteOp("a")}); err != ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", ErrNotSupported, err)
+	}
+}
+
+func TestNoopWatch(t *testing.T) {
+	s := NewNoopStore()
+	if _, err := s.Watch(""); err != ErrNotSupported {
+		t.Fatalf("Expected %v, got %v", ErrNotSupported, err)
+	}
+}
+
+func TestMemoryVersion(t *testing.T) {
+	s := NewMemoryStore()
+
+	s.Write(&Record{Key: "foo", Value: []byte("bar")})
+	r, err := s.Read("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := r[0].Version
+
+	// a key deleted and written again isn't back at the version it was read at
+	s.Delete("foo")
+	s.Write(&Record{Key: "foo", Value: []byte("baz")})
+	if err := s.Batch([]Op{WriteOp(&Record{Key: "foo"}).IfVersion(version)}); err != ErrConflict {
+		t.Fatalf("Expected %v, got %v", ErrConflict, err)
+	}
+	if r, err := s.Read("foo"); err != nil || r[0].Version <= version {
+		t.Fatalf("Expected a version after %d, got %v %v", version, r, err)
+	}
+}
+
+func TestMemoryWatch(t *testing.T) {
+	s := NewMemoryStore()
+
+	w, err := s.Watch("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Write(&Record{Key: "bar", Value: []byte("ignored")})
+	s.Write(&Record{Key: "foo", Value: []byte("ignored")}, WriteTo("", "other"))
+	s.Write(&Record{Key: "foo", Value: []byte("bar")})
+	s.Write(&Record{Key: "foobar", Value: []byte("baz")})
+	s.Delete("foo")
+
+	expected := []struct {
+		Type EventType
+		Key  string
+	}{
+		{Put, "foo"},
+		{Put, "foobar"},
+		{Delete, "foo"},
+	}
+
+	for _, e := range expected {
+		ev, err := w.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != e.Type || ev.Record.Key != e.Key {
+			t.Fatalf("Expected %v %s, got %v %s", e.Type, e.Key, ev.Type, ev.Record.Key)
+		}
+	}
+
+	// stopping removes the watcher from the store
+	w.Stop()
+	if _, err := w.Next(); err != ErrWatcherStopped {
+		t.Fatalf("Expected %v, got %v", ErrWatcherStopped, err)
+	}
+	m := s.(*memoryStore)
+	m.watchLock.RLock()
+	n := len(m.watchers)
+	m.watchLock.RUnlock()
+	if n != 0 {
+		t.Fatalf("Expected no watchers, got %d", n)
+	}
+}
+
+func TestMemoryWatchOverflow(t *testing.T) {
+	s := NewMemoryStore()
+
+	w, err := s.Watch("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// writes never block on a watcher which isn't keeping up
+	for i := 0; i < 200; i++ {
+		s.Write(&Record{Key: "foo", Value: []byte("bar")})
+	}
+
+	if _, err := w.Next(); err != ErrWatcherOverflow {
+		t.Fatalf("Expected %v, got %v", ErrWatcherOverflow, err)
+	}
+}
+
+func TestMemoryWatchExpire(t *testing.T) {
+	defer func(d time.Duration) { expiryInterval = d }(expiryInterval)
+	expiryInterval = time.Millisecond * 10
+
+	s := NewMemoryStore()
+	w, err := s.Watch("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	start := time.Now()
+	s.Write(&Record{Key: "foo", Value: []byte("bar")}, WriteTTL(time.Millisecond*20))
+
+	for _, typ := range []EventType{Put, Expire} {
+		ev, err := w.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != typ || ev.Record.Key != "foo" {
+			t.Fatalf("Expected %v foo, got %v %s", typ, ev.Type, ev.Record.Key)
+		}
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Expected the expiry within a second, took %v", d)
+	}
+}
+
+func TestMemoryExpireWrite(t *testing.T) {
+	defer func(d time.Duration) { expiryInterval = d }(expiryInterval)
+	expiryInterval = time.Millisecond
+
+	s := NewMemoryStore(Indexes(Index{Name: "team", Metadata: "team"}))
+	team := map[string]interface{}{"team": "red"}
+
+	// write the key again and again as it expires
+	for i := 0; i < 100; i++ {
+		s.Write(&Record{Key: "foo", Metadata: team}, WriteTTL(time.Millisecond))
+		time.Sleep(time.Millisecond)
+	}
+	s.Write(&Record{Key: "foo", Metadata: team})
+	time.Sleep(time.Millisecond * 10)
+
+	// the last write is still listed and indexed
+	if keys, err := s.List(); err != nil || strings.Join(keys, ",") != "foo" {
+		t.Fatalf("Expected foo, got %v %v", keys, err)
+	}
+	if r, err := s.Read("red", ReadIndex("team")); err != nil || recordKeys(r) != "foo" {
+		t.Fatalf("Expected foo, got %v %v", recordKeys(r), err)
+	}
+}
+
+func TestMemoryWatchConcurrentOverflow(t *testing.T) {
+	// expiries and writes send to the watcher at the same time
+	w := &memoryWatcher{
+		events:   make(chan *Event, 1),
+		exit:     make(chan bool),
+		overflow: make(chan bool),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				w.send(&Event{Type: Put, Record: &Record{Key: "foo"}})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := w.Next(); err != ErrWatcherOverflow {
+		t.Fatalf("Expected %v, got %v", ErrWatcherOverflow, err)
+	}
+}
+
+func TestMemoryCursor(t *testing.T) {
+	s := NewMemoryStore()
+
+	for i := 0; i < 10; i++ {
+		s.Write(&Record{Key: fmt.Sprintf("k%d", i)})
+	}
+	s.Write(&Record{Key: "a"})
+	s.Write(&Record{Key: "z"})
+
+	var keys []string
+	var cursor string
+	for pages := 0; ; pages++ {
+		if pages > 4 {
+			t.Fatal("Expected 4 pages")
+		}
+		var next string
+		page, err := s.List(ListPrefix("k"), ListLimit(3), ListCursor(cursor), ListNextCursor(&next))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, page...)
+		if len(next) == 0 {
+			break
+		}
+		cursor = next
+	}
+	if k := strings.Join(keys, ","); k != "k0,k1,k2,k3,k4,k5,k6,k7,k8,k9" {
+		t.Fatalf("Expected every key once in order, got %s", k)
+	}
+
+	// records are paged the same way
+	var next string
+	r, err := s.Read("k", ReadPrefix(), ReadLimit(4), ReadCursor(""), ReadNextCursor(&next))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := recordKeys(r); k != "k0,k1,k2,k3" || len(next) == 0 {
+		t.Fatalf("Expected the first page, got %s %q", k, next)
+	}
+	r, err = s.Read("k", ReadPrefix(), ReadLimit(4), ReadCursor(next), ReadNextCursor(&next))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := recordKeys(r); k != "k4,k5,k6,k7" {
+		t.Fatalf("Expected the second page, got %s", k)
+	}
+
+	if _, err := s.List(ListCursor("not a cursor")); err != ErrInvalidCursor {
+		t.Fatalf("Expected %v, got %v", ErrInvalidCursor, err)
+	}
+
+	// deleted keys are no longer listed
+	s.Delete("k0")
+	if keys, err := s.List(ListPrefix("k"), ListLimit(1)); err != nil || strings.Join(keys, ",") != "k1" {
+		t.Fatalf("Expected k1, got %v %v", keys, err)
+	}
+}
+
+func TestMemoryIndexCursor(t *testing.T) {
+	s := NewMemoryStore(Indexes(Index{Name: "time", Metadata: "time"}))
+
+	// two topics indexed by topic and time, as the events store does
+	for i := 0; i < 10; i++ {
+		for _, topic := range []string{"a", "b"} {
+			s.Write(&Record{
+				Key:      fmt.Sprintf("%s%d", topic, i),
+				Metadata: map[string]interface{}{"time": fmt.Sprintf("%s/%02d", topic, i)},
+			})
+		}
+	}
+	s.Delete("a3")
+
+	for _, tc := range []struct {
+		Order Order
+		Keys  string
+	}{
+		{OrderAsc, "a0,a1,a2,a4,a5,a6,a7,a8,a9"},
+		{OrderDesc, "a9,a8,a7,a6,a5,a4,a2,a1,a0"},
+	} {
+		var keys []string
+		var cursor string
+		for pages := 0; ; pages++ {
+			if pages > 3 {
+				t.Fatal("Expected 3 pages")
+			}
+			var next string
+			r, err := s.Read("a/", ReadIndex("time"), ReadPrefix(), ReadOrder(tc.Order), ReadLimit(4), ReadCursor(cursor), ReadNextCursor(&next))
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys = append(keys, recordKeys(r))
+			if len(next) == 0 {
+				break
+			}
+			cursor = next
+		}
+		if k := strings.Join(keys, ","); k != tc.Keys {
+			t.Fatalf("Expected %s, got %s", tc.Keys, k)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"strings"
+	"sync"
+)
+
+type memoryWatcher struct {
+	id string
+	// table is the database/table prefix of the watched keys
+	table  string
+	prefix string
+	events chan *Event
+	exit   chan bool
+	// overflow is closed once an event is lost
+	overflow chan bool
+	// lost closes overflow once, as writes and expiries can both overflow the watcher
+	lost sync.Once
+	// remove deregisters the watcher from the store
+	remove func()
+	once   sync.Once
+}
+
+// match reports whether the cache key is watched
+func (m *memoryWatcher) match(key string) bool {
+	if !strings.HasPrefix(key, m.table+"/") {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimPrefix(key, m.table+"/"), m.prefix)
+}
+
+// send queues the event without blocking the store, a watcher too far behind overflows
+func (m *memoryWatcher) send(e *Event) {
+	select {
+	case <-m.overflow:
+		return
+	default:
+	}
+	select {
+	case m.events <- e:
+	default:
+		m.lost.Do(func() {
+			close(m.overflow)
+		})
+	}
+}
+
+func (m *memoryWatcher) Next() (*Event, error) {
+	select {
+	case <-m.exit:
+		return nil, ErrWatcherStopped
+	case <-m.overflow:
+		return nil, ErrWatcherOverflow
+	default:
+	}
+
+	select {
+	case e := <-m.events:
+		return e, nil
+	case <-m.overflow:
+		return nil, ErrWatcherOverflow
+	case <-m.exit:
+		return nil, ErrWatcherStopped
+	}
+}
+
+func (m *memoryWatcher) Stop() {
+	m.once.Do(func() {
+		close(m.exit)
+		m.remove()
+	})
+}
@@ -30,6 +30,14 @@ func (n *noopStore) List(opts ...ListOption) ([]string, error) {
 	return []string{}, nil
 }
 
+func (n *noopStore) Batch(ops []Op, opts ...BatchOption) error {
+	return ErrNotSupported
+}
+
+func (n *noopStore) Watch(prefix string, opts ...WatchOption) (Watcher, error) {
+	return nil, ErrNotSupported
+}
+
 func (n *noopStore) Close() error {
 	return nil
 }
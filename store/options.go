@@ -20,6 +20,8 @@ type Options struct {
 	Context context.Context
 	// Client to use for RPC
 	Client client.Client
+	// Indexes are the secondary indexes maintained for every table
+	Indexes []Index
 }
 
 // Option 设置 Options 的值
@@ -62,6 +64,14 @@ func WithClient(c client.Client) Option {
 	}
 }
 
+// Indexes declares secondary indexes to maintain for every table.
+// Records written before the indexes are declared with Init are indexed by it.
+func Indexes(i ...Index) Option {
+	return func(o *Options) {
+		o.Indexes = i
+	}
+}
+
 // ReadOptions 配置单独的 Read 操作
 type ReadOptions struct {
 	Database, Table string
@@ -73,6 +83,14 @@ type ReadOptions struct {
 	Limit uint
 	// Offset when combined with Limit supports pagination
 	Offset uint
+	// Index reads through the named index, matching the key against the indexed value
+	Index string
+	// Order of the records read through an index
+	Order Order
+	// Cursor continues reading after the page which returned it, in place of Offset
+	Cursor string
+	// Next is set to the cursor of the next page, or empty if there are no more records
+	Next *string
 }
 
 // ReadOption sets values in ReadOptions
@@ -114,6 +132,36 @@ func ReadOffset(o uint) ReadOption {
 	}
 }
 
+// ReadIndex reads records through the named index. The key is matched against
+// the indexed value, and Prefix and Suffix apply to the indexed value.
+func ReadIndex(name string) ReadOption {
+	return func(r *ReadOptions) {
+		r.Index = name
+	}
+}
+
+// ReadOrder sets the order of records read through an index
+func ReadOrder(o Order) ReadOption {
+	return func(r *ReadOptions) {
+		r.Order = o
+	}
+}
+
+// ReadCursor continues reading after the page which returned the cursor.
+// Use in conjunction with Limit for pagination that is stable under writes.
+func ReadCursor(c string) ReadOption {
+	return func(r *ReadOptions) {
+		r.Cursor = c
+	}
+}
+
+// ReadNextCursor sets next to the cursor of the next page, or empty if there are no more records
+func ReadNextCursor(next *string) ReadOption {
+	return func(r *ReadOptions) {
+		r.Next = next
+	}
+}
+
 // WriteOptions 配置单独的写操作
 // If Expiry and TTL are set TTL takes precedence
 type WriteOptions struct {
@@ -177,6 +225,10 @@ type ListOptions struct {
 	Limit uint
 	// Offset when combined with Limit supports pagination
 	Offset uint
+	// Cursor continues listing after the page which returned it, in place of Offset
+	Cursor string
+	// Next is set to the cursor of the next page, or empty if there are no more keys
+	Next *string
 }
 
 // ListOption sets values in ListOptions
@@ -217,3 +269,50 @@ func ListOffset(o uint) ListOption {
 		l.Offset = o
 	}
 }
+
+// ListCursor continues listing after the page which returned the cursor.
+// Use in conjunction with Limit for pagination that is stable under writes.
+func ListCursor(c string) ListOption {
+	return func(l *ListOptions) {
+		l.Cursor = c
+	}
+}
+
+// ListNextCursor sets next to the cursor of the next page, or empty if there are no more keys
+func ListNextCursor(next *string) ListOption {
+	return func(l *ListOptions) {
+		l.Next = next
+	}
+}
+
+// BatchOptions 配置单独的 Batch 操作
+type BatchOptions struct {
+	Database, Table string
+}
+
+// BatchOption sets values in BatchOptions
+type BatchOption func(b *BatchOptions)
+
+// BatchTo the database and table
+func BatchTo(database, table string) BatchOption {
+	return func(b *BatchOptions) {
+		b.Database = database
+		b.Table = table
+	}
+}
+
+// WatchOptions 配置单独的 Watch 操作
+type WatchOptions struct {
+	Database, Table string
+}
+
+// WatchOption sets values in WatchOptions
+type WatchOption func(w *WatchOptions)
+
+// WatchFrom the database and table
+func WatchFrom(database, table string) WatchOption {
+	return func(w *WatchOptions) {
+		w.Database = database
+		w.Table = table
+	}
+}
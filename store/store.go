@@ -11,6 +11,19 @@ import (
 var (
 	// ErrNotFound 当 key 不存在返回
 	ErrNotFound = errors.New("not found")
+	// ErrConflict 当 batch 中某条记录的版本检查失败时返回
+	ErrConflict = errors.New("version conflict")
+	// ErrNotSupported is returned by stores which do not implement an operation
+	ErrNotSupported = errors.New("operation not supported by store")
+	// ErrIndexNotFound is returned when reading through an index which is not declared
+	ErrIndexNotFound = errors.New("index not found")
+	// ErrInvalidCursor is returned when a cursor can't be decoded
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrWatcherStopped is returned when the watcher is stopped
+	ErrWatcherStopped = errors.New("watcher stopped")
+	// ErrWatcherOverflow is returned by a watcher which fell too far behind and
+	// lost events, read the records again and watch again to catch up
+	ErrWatcherOverflow = errors.New("watcher overflow")
 	// DefaultStore 是内存存储
 	DefaultStore Store = NewStore()
 )
@@ -29,6 +42,11 @@ type Store interface {
 	Delete(key string, opts ...DeleteOption) error
 	// List returns any keys that match, or an empty list with no error if none matched.
 	List(opts ...ListOption) ([]string, error)
+	// Batch applies all the operations atomically. If any version check fails
+	// ErrConflict is returned and none of the operations are applied.
+	Batch(ops []Op, opts ...BatchOption) error
+	// Watch returns a Watcher which streams changes to records whose key has the given prefix
+	Watch(prefix string, opts ...WatchOption) (Watcher, error)
 	// Close 关闭 store
 	Close() error
 	// String 返回实现的名字
@@ -45,6 +63,57 @@ type Record struct {
 	Metadata map[string]interface{} `json:"metadata"`
 	// Time to expire a record: TODO: change to timestamp
 	Expiry time.Duration `json:"expiry,omitempty"`
+	// Version of the record, incremented by the store on every write
+	Version uint64 `json:"version,omitempty"`
+}
+
+// OpType is the type of an operation in a batch
+type OpType int
+
+const (
+	// OpWrite writes the record
+	OpWrite OpType = iota
+	// OpDelete deletes the record
+	OpDelete
+	// OpCheck only checks the version of the record
+	OpCheck
+)
+
+// Op 是 Batch 中的一个操作
+type Op struct {
+	Type OpType
+	// Record to write. Delete and check only use the Key and Version.
+	Record *Record
+	// Compare makes the operation conditional on the stored version
+	// matching Record.Version. A Version of 0 means the key must not exist.
+	Compare bool
+}
+
+// WriteOp returns an unconditional write of r
+func WriteOp(r *Record) Op {
+	return Op{Type: OpWrite, Record: r}
+}
+
+// DeleteOp returns an unconditional delete of key
+func DeleteOp(key string) Op {
+	return Op{Type: OpDelete, Record: &Record{Key: key}}
+}
+
+// CheckOp returns an operation asserting that key is at version
+func CheckOp(key string, version uint64) Op {
+	return Op{Type: OpCheck, Record: &Record{Key: key, Version: version}, Compare: true}
+}
+
+// IfVersion makes the operation conditional on the stored record being at version
+func (o Op) IfVersion(version uint64) Op {
+	r := &Record{}
+	if o.Record != nil {
+		*r = *o.Record
+	}
+	r.Version = version
+	o.Record = r
+	o.Compare = true
+	return o
 }
 
 func NewStore(opts ...Option) Store {
@@ -0,0 +1,20 @@
+package store_test
+
+import (
+	"testing"
+
+	"go-micro.dev/v4/store"
+	"go-micro.dev/v4/store/test"
+)
+
+func TestMemoryBatch(t *testing.T) {
+	test.Batch(t, store.NewMemoryStore())
+}
+
+func TestMemoryIndex(t *testing.T) {
+	test.Index(t, store.NewMemoryStore())
+}
+
+func TestMemoryLimitOffset(t *testing.T) {
+	test.LimitOffset(t, store.NewMemoryStore())
+}
@@ -0,0 +1,225 @@
+// Package test provides tests shared by the implementations of store.Store
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go-micro.dev/v4/store"
+)
+
+// Keys returns the keys of the records joined by commas
+func Keys(records []*store.Record) string {
+	var k []string
+	for _, r := range records {
+		k = append(k, r.Key)
+	}
+	return strings.Join(k, ",")
+}
+
+// Batch tests the batches and version checks of an empty store table
+func Batch(t *testing.T, s store.Store) {
+	testCases := []struct {
+		Name string
+		Ops  []store.Op
+		Err  error
+		// Versions of the keys after the batch, 0 for a key which doesn't exist
+		Versions map[string]uint64
+	}{
+		{
+			"CreateIfNotExists",
+			[]store.Op{
+				store.WriteOp(&store.Record{Key: "a", Value: []byte("1")}).IfVersion(0),
+				store.WriteOp(&store.Record{Key: "b", Value: []byte("1")}).IfVersion(0),
+			},
+			nil,
+			map[string]uint64{"a": 1, "b": 1},
+		},
+		{
+			// a stale version must fail the whole batch
+			"StaleVersion",
+			[]store.Op{
+				store.WriteOp(&store.Record{Key: "a", Value: []byte("2")}).IfVersion(1),
+				store.DeleteOp("b").IfVersion(0),
+			},
+			store.ErrConflict,
+			map[string]uint64{"a": 1, "b": 1},
+		},
+		{
+			// a check of a key which doesn't exist fails unless expecting version 0
+			"CheckMissing",
+			[]store.Op{store.CheckOp("c", 1)},
+			store.ErrConflict,
+			map[string]uint64{"c": 0},
+		},
+		{
+			"Apply",
+			[]store.Op{
+				store.CheckOp("c", 0),
+				store.WriteOp(&store.Record{Key: "a", Value: []byte("2")}).IfVersion(1),
+				store.DeleteOp("b").IfVersion(1),
+			},
+			nil,
+			map[string]uint64{"a": 2, "b": 0, "c": 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			if err := s.Batch(tc.Ops); err != tc.Err {
+				t.Fatalf("Expected %v, got %v", tc.Err, err)
+			}
+			for key, version := range tc.Versions {
+				r, err := s.Read(key)
+				if version == 0 {
+					if err != store.ErrNotFound {
+						t.Fatalf("Expected %s to be %v, got %v", key, store.ErrNotFound, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if r[0].Version != version {
+					t.Fatalf("Expected %s at version %d, got %d", key, version, r[0].Version)
+				}
+			}
+		})
+	}
+}
+
+// Index tests the indexes and index queries of an empty store table
+// declared without indexes
+func Index(t *testing.T, s store.Store) {
+	users := []struct {
+		Key  string
+		Age  int
+		Team string
+	}{
+		{"alice", 30, "red"},
+		{"bob", 5, "blue"},
+		{"carol", 100, "red"},
+		{"dave", 5, "red"},
+	}
+	for _, u := range users {
+		if err := s.Write(&store.Record{
+			Key:      u.Key,
+			Value:    []byte(fmt.Sprintf(`{"user":{"age":%d}}`, u.Age)),
+			Metadata: map[string]interface{}{"team": u.Team},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// indexes declared after the records are written cover them
+	if err := s.Init(store.Indexes(
+		store.Index{Name: "age", Field: "user.age"},
+		store.Index{Name: "team", Metadata: "team"},
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a string of the same form as a number is a different value
+	if err := s.Write(&store.Record{Key: "eve", Value: []byte(`{"user":{"age":"5"}}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		Name     string
+		Key      string
+		Opts     []store.ReadOption
+		Expected string
+	}{
+		{"Equal", "5", []store.ReadOption{store.ReadIndex("age")}, "bob,dave,eve"},
+		{"OrderedScan", "", []store.ReadOption{store.ReadIndex("age"), store.ReadPrefix()}, "bob,dave,alice,carol,eve"},
+		{"Descending", "", []store.ReadOption{store.ReadIndex("age"), store.ReadPrefix(), store.ReadOrder(store.OrderDesc)}, "eve,carol,alice,dave,bob"},
+		{"Prefix", "1", []store.ReadOption{store.ReadIndex("age"), store.ReadPrefix()}, "carol"},
+		{"LimitOffset", "red", []store.ReadOption{store.ReadIndex("team"), store.ReadLimit(2), store.ReadOffset(1)}, "carol,dave"},
+		{"Suffix", "ue", []store.ReadOption{store.ReadIndex("team"), store.ReadSuffix()}, "bob"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			r, err := s.Read(tc.Key, tc.Opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if k := Keys(r); k != tc.Expected {
+				t.Fatalf("Expected %s, got %s", tc.Expected, k)
+			}
+		})
+	}
+
+	// updates and deletes must move and remove the index entries
+	s.Write(&store.Record{Key: "bob", Value: []byte(`{"user":{"age":6}}`)})
+	s.Delete("dave")
+	s.Delete("eve")
+	if r, err := s.Read("5", store.ReadIndex("age")); err != nil || len(r) != 0 {
+		t.Fatalf("Expected no records, got %s %v", Keys(r), err)
+	}
+
+	if _, err := s.Read("5", store.ReadIndex("height")); err != store.ErrIndexNotFound {
+		t.Fatalf("Expected %v, got %v", store.ErrIndexNotFound, err)
+	}
+}
+
+// LimitOffset tests the limit and offset of List and of prefix and suffix reads
+// of an empty store table
+func LimitOffset(t *testing.T, s store.Store) {
+	// keys sorting before the prefix must not use up the limit
+	for _, k := range []string{"a1", "a2", "a3", "b1", "b2", "b3", "b4"} {
+		if err := s.Write(&store.Record{Key: k}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readCases := []struct {
+		Name     string
+		Key      string
+		Opts     []store.ReadOption
+		Expected string
+	}{
+		{"Prefix", "b", []store.ReadOption{store.ReadPrefix()}, "b1,b2,b3,b4"},
+		{"Limit", "b", []store.ReadOption{store.ReadPrefix(), store.ReadLimit(2)}, "b1,b2"},
+		{"LimitOffset", "b", []store.ReadOption{store.ReadPrefix(), store.ReadLimit(2), store.ReadOffset(1)}, "b2,b3"},
+		{"OffsetPastLimit", "b", []store.ReadOption{store.ReadPrefix(), store.ReadLimit(1), store.ReadOffset(3)}, "b4"},
+		{"OffsetPastEnd", "b", []store.ReadOption{store.ReadPrefix(), store.ReadOffset(10)}, ""},
+		{"Suffix", "3", []store.ReadOption{store.ReadSuffix(), store.ReadLimit(1), store.ReadOffset(1)}, "b3"},
+	}
+
+	for _, tc := range readCases {
+		t.Run("Read"+tc.Name, func(t *testing.T) {
+			r, err := s.Read(tc.Key, tc.Opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if k := Keys(r); k != tc.Expected {
+				t.Fatalf("Expected %s, got %s", tc.Expected, k)
+			}
+		})
+	}
+
+	listCases := []struct {
+		Name     string
+		Opts     []store.ListOption
+		Expected string
+	}{
+		{"LimitOffset", []store.ListOption{store.ListLimit(2), store.ListOffset(2)}, "a3,b1"},
+		{"PrefixLimitOffset", []store.ListOption{store.ListPrefix("b"), store.ListLimit(2), store.ListOffset(1)}, "b2,b3"},
+		{"OffsetPastLimit", []store.ListOption{store.ListPrefix("b"), store.ListLimit(1), store.ListOffset(3)}, "b4"},
+		{"SuffixOffset", []store.ListOption{store.ListSuffix("1"), store.ListOffset(1)}, "b1"},
+	}
+
+	for _, tc := range listCases {
+		t.Run("List"+tc.Name, func(t *testing.T) {
+			keys, err := s.List(tc.Opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if k := strings.Join(keys, ","); k != tc.Expected {
+				t.Fatalf("Expected %s, got %s", tc.Expected, k)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package store
+
+import "time"
+
+// Watcher 返回 store 中记录变更的事件
+type Watcher interface {
+	// Next is a blocking call
+	Next() (*Event, error)
+	// Stop the watcher
+	Stop()
+}
+
+// EventType defines store event type
+type EventType int
+
+const (
+	// Put is emitted when a record is written
+	Put EventType = iota
+	// Delete is emitted when a record is deleted
+	Delete
+	// Expire is emitted when a record is removed because it expired
+	Expire
+)
+
+// String returns human readable event type
+func (t EventType) String() string {
+	switch t {
+	case Put:
+		return "put"
+	case Delete:
+		return "delete"
+	case Expire:
+		return "expire"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to a record in the store
+type Event struct {
+	// Type defines type of event
+	Type EventType
+	// Record which changed. Delete and expire events only carry the Key.
+	Record *Record
+	// Timestamp is event timestamp
+	Timestamp time.Time
+}
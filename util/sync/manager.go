@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"go-micro.dev/v4/logger"
 	"go-micro.dev/v4/store"
 )
 
@@ -43,11 +44,144 @@ func (c *syncStore) syncManager() {
 	}
 }
 
+// replicate queues the changes to a table of L0 for every other layer until
+// the sync is disposed. When the watcher loses events the table is copied again.
+func (c *syncStore) replicate(w store.Watcher, database, table string) {
+	for {
+		err := c.follow(w, database, table)
+		w.Stop()
+		if err != store.ErrWatcherOverflow || c.storeOpts.Context.Err() != nil {
+			return
+		}
+
+		// watch again before copying so no change is missed
+		w, err = c.syncOpts.Stores[0].Watch("", store.WatchFrom(database, table))
+		if err != nil {
+			return
+		}
+		if err := c.resync(database, table); err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Sync of table %s failed: %v", table, err)
+			}
+		}
+	}
+}
+
+// follow queues the changes seen by the watcher until it errors
+func (c *syncStore) follow(w store.Watcher, database, table string) error {
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		select {
+		case <-c.storeOpts.Context.Done():
+			w.Stop()
+		case <-done:
+		}
+	}()
+
+	for {
+		e, err := w.Next()
+		if err != nil {
+			return err
+		}
+		ir := &internalRecord{
+			database: database,
+			table:    table,
+			key:      e.Record.Key,
+		}
+		switch e.Type {
+		case store.Put:
+			ir.operation = writeOp
+			ir.value = e.Record.Value
+			ir.metadata = e.Record.Metadata
+			if e.Record.Expiry != 0 {
+				ir.expiresAt = time.Now().Add(e.Record.Expiry)
+			}
+		case store.Delete:
+			ir.operation = deleteOp
+		default:
+			// expired records expire in each layer by themselves
+			continue
+		}
+		c.Lock()
+		for _, q := range c.pendingWrites {
+			q.PushBack(ir)
+		}
+		c.Unlock()
+	}
+}
+
+// resync queues every record of a table of L0 for the other layers, and
+// deletes of the keys they have which L0 doesn't
+func (c *syncStore) resync(database, table string) error {
+	list, err := c.syncOpts.Stores[0].List(store.ListFrom(database, table))
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]bool, len(list))
+	queued := make([]*internalRecord, 0, len(list))
+	for _, k := range list {
+		recs, err := c.syncOpts.Stores[0].Read(k, store.ReadFrom(database, table))
+		if err == store.ErrNotFound {
+			// deleted since the keys were listed
+			continue
+		} else if err != nil {
+			return err
+		}
+		r := recs[0]
+		keys[r.Key] = true
+		ir := &internalRecord{
+			operation: writeOp,
+			database:  database,
+			table:     table,
+			key:       r.Key,
+			value:     r.Value,
+			metadata:  r.Metadata,
+		}
+		if r.Expiry != 0 {
+			ir.expiresAt = time.Now().Add(r.Expiry)
+		}
+		queued = append(queued, ir)
+	}
+
+	// list the keys of each layer before queueing, the queue is locked meanwhile
+	stale := make([][]string, len(c.pendingWrites))
+	for i := range c.pendingWrites {
+		list, err := c.syncOpts.Stores[i+1].List(store.ListFrom(database, table))
+		if err != nil {
+			return err
+		}
+		for _, k := range list {
+			if !keys[k] {
+				stale[i] = append(stale[i], k)
+			}
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	for i, q := range c.pendingWrites {
+		for _, ir := range queued {
+			q.PushBack(ir)
+		}
+		for _, k := range stale[i] {
+			q.PushBack(&internalRecord{
+				operation: deleteOp,
+				database:  database,
+				table:     table,
+				key:       k,
+			})
+		}
+	}
+	return nil
+}
+
 func (c *syncStore) processQueue(index int) {
 	c.Lock()
 	defer c.Unlock()
 	q := c.pendingWrites[index]
-	for i := 0; i < q.Len(); i++ {
+	for i, n := 0, q.Len(); i < n; i++ {
 		r, ok := q.PopFront()
 		if !ok {
 			panic(errors.Errorf("retrieved an invalid value from the L%d sync queue", index+1))
@@ -56,19 +190,27 @@ func (c *syncStore) processQueue(index int) {
 		if !ok {
 			panic(errors.Errorf("retrieved a non-internal record from the L%d sync queue", index+1))
 		}
+		if ir.operation == deleteOp {
+			if err := c.syncOpts.Stores[index+1].Delete(ir.key, store.DeleteFrom(ir.database, ir.table)); err != nil {
+				// some error, so queue for retry and bail
+				q.PushBack(ir)
+				return
+			}
+			continue
+		}
 		if !ir.expiresAt.IsZero() && time.Now().After(ir.expiresAt) {
 			continue
 		}
 		nr := &store.Record{
-			Key: ir.key,
+			Key:      ir.key,
+			Metadata: ir.metadata,
 		}
 		nr.Value = make([]byte, len(ir.value))
 		copy(nr.Value, ir.value)
 		if !ir.expiresAt.IsZero() {
 			nr.Expiry = time.Until(ir.expiresAt)
 		}
-		// Todo = internal queue also has to hold the corresponding store.WriteOptions
-		if err := c.syncOpts.Stores[index+1].Write(nr); err != nil {
+		if err := c.syncOpts.Stores[index+1].Write(nr, store.WriteTo(ir.database, ir.table)); err != nil {
 			// some error, so queue for retry and bail
 			q.PushBack(ir)
 			return
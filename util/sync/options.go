@@ -14,6 +14,8 @@ type Options struct {
 	SyncInterval time.Duration
 	// SyncMultiplier is the multiplication factor between each store.
 	SyncMultiplier int64
+	// Tables of L0 replicated to the other layers, the table of the sync if empty
+	Tables []string
 }
 
 // Option sets Sync Options
@@ -42,3 +44,10 @@ func SyncMultiplier(i int64) Option {
 		o.SyncMultiplier = i
 	}
 }
+
+// Tables sets the tables of L0 replicated to the other layers
+func Tables(t ...string) Option {
+	return func(o *Options) {
+		o.Tables = t
+	}
+}
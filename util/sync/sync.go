@@ -70,6 +70,18 @@ func (c *syncStore) Init(opts ...store.Option) error {
 		c.pendingWriteTickers[i] = time.NewTicker(c.syncOpts.SyncInterval * time.Duration(intpow(c.syncOpts.SyncMultiplier, int64(i))))
 	}
 	go c.syncManager()
+	// replicate incrementally if L0 can be watched
+	tables := c.syncOpts.Tables
+	if len(tables) == 0 {
+		tables = []string{c.storeOpts.Table}
+	}
+	for _, t := range tables {
+		w, err := c.syncOpts.Stores[0].Watch("", store.WatchFrom(c.storeOpts.Database, t))
+		if err != nil {
+			continue
+		}
+		go c.replicate(w, c.storeOpts.Database, t)
+	}
 	return nil
 }
 
@@ -104,12 +116,26 @@ func (c *syncStore) Delete(key string, opts ...store.DeleteOption) error {
 	return c.syncOpts.Stores[0].Delete(key, opts...)
 }
 
+// Batch applies the operations to the first store in the sync
+func (c *syncStore) Batch(ops []store.Op, opts ...store.BatchOption) error {
+	return c.syncOpts.Stores[0].Batch(ops, opts...)
+}
+
+// Watch watches the first store in the sync
+func (c *syncStore) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	return c.syncOpts.Stores[0].Watch(prefix, opts...)
+}
+
 func (c *syncStore) Sync() error {
 	return nil
 }
 
 type internalRecord struct {
+	operation action
+	database  string
+	table     string
 	key       string
 	value     []byte
+	metadata  map[string]interface{}
 	expiresAt time.Time
 }
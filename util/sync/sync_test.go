@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/store"
+)
+
+// waitFor polls until fn returns true
+func waitFor(t *testing.T, what string, fn func() bool) {
+	for i := 0; !fn(); i++ {
+		if i == 100 {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestSyncReplicateTables(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l0, l1 := store.NewMemoryStore(), store.NewMemoryStore()
+	s := NewSync(Stores(l0, l1), SyncInterval(time.Millisecond*10), Tables("users", "orders"))
+	if err := s.Init(store.WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Write(&store.Record{Key: "alice", Value: []byte("1")}, store.WriteTo("", "users")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(&store.Record{Key: "order-1", Value: []byte("2")}, store.WriteTo("", "orders")); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the tables to replicate", func() bool {
+		_, err1 := l1.Read("alice", store.ReadFrom("", "users"))
+		_, err2 := l1.Read("order-1", store.ReadFrom("", "orders"))
+		return err1 == nil && err2 == nil
+	})
+
+	if err := s.Delete("alice", store.DeleteFrom("", "users")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the delete to replicate", func() bool {
+		_, err := l1.Read("alice", store.ReadFrom("", "users"))
+		return err == store.ErrNotFound
+	})
+}
+
+// overflowStore loses the events of its first watcher
+type overflowStore struct {
+	store.Store
+	watched bool
+}
+
+type overflowWatcher struct{}
+
+func (overflowWatcher) Next() (*store.Event, error) {
+	return nil, store.ErrWatcherOverflow
+}
+
+func (overflowWatcher) Stop() {}
+
+func (o *overflowStore) Watch(prefix string, opts ...store.WatchOption) (store.Watcher, error) {
+	if !o.watched {
+		o.watched = true
+		return overflowWatcher{}, nil
+	}
+	return o.Store.Watch(prefix, opts...)
+}
+
+func TestSyncResyncOnOverflow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l0, l1 := store.NewMemoryStore(), store.NewMemoryStore()
+	l0.Write(&store.Record{Key: "kept", Value: []byte("1")})
+	l1.Write(&store.Record{Key: "stale", Value: []byte("1")})
+
+	s := NewSync(Stores(&overflowStore{Store: l0}, l1), SyncInterval(time.Millisecond*10))
+	if err := s.Init(store.WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lost events are recovered by copying the table
+	waitFor(t, "the table to resync", func() bool {
+		_, err1 := l1.Read("kept")
+		_, err2 := l1.Read("stale")
+		return err1 == nil && err2 == store.ErrNotFound
+	})
+}
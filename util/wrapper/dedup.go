@@ -0,0 +1,183 @@
+package wrapper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/cache"
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/server"
+	"go-micro.dev/v4/store"
+)
+
+var (
+	// DefaultDedupWindow is how long the ID of a handled message is remembered
+	DefaultDedupWindow = time.Minute * 10
+	// DefaultDedupHeader is the header carrying the message ID, it is set by
+	// client.Publish and can be fixed across retries with client.WithMessageID
+	DefaultDedupHeader = HeaderPrefix + "Id"
+)
+
+type DedupOptions struct {
+	// Window is how long the ID of a handled message is remembered
+	Window time.Duration
+	// Header is the message header carrying the ID
+	Header string
+	// Cache the seen IDs are kept in, used unless Store is set
+	Cache cache.Cache
+	// Store the seen IDs are kept in, so they are shared between instances
+	Store store.Store
+	// Database and Table of the store
+	Database, Table string
+}
+
+type DedupOption func(*DedupOptions)
+
+// DedupWindow sets how long the ID of a handled message is remembered
+func DedupWindow(d time.Duration) DedupOption {
+	return func(o *DedupOptions) {
+		o.Window = d
+	}
+}
+
+// DedupHeader sets the message header carrying the ID
+func DedupHeader(h string) DedupOption {
+	return func(o *DedupOptions) {
+		o.Header = h
+	}
+}
+
+// DedupCache keeps the seen IDs in the cache
+func DedupCache(c cache.Cache) DedupOption {
+	return func(o *DedupOptions) {
+		o.Cache = c
+	}
+}
+
+// DedupStore keeps the seen IDs in the store table
+func DedupStore(s store.Store, database, table string) DedupOption {
+	return func(o *DedupOptions) {
+		o.Store = s
+		o.Database = database
+		o.Table = table
+	}
+}
+
+// seen tracks the IDs of handled messages
+type seen interface {
+	// reserve records the key for the duration, returning false if it is already recorded
+	reserve(key string, d time.Duration) (bool, error)
+	// release forgets the key so its message is handled again
+	release(key string) error
+}
+
+type cacheSeen struct {
+	// the cache can't add a key only if it is missing
+	sync.Mutex
+	c cache.Cache
+}
+
+func (s *cacheSeen) reserve(key string, d time.Duration) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	_, _, err := s.c.Get(key)
+	if err == nil {
+		return false, nil
+	} else if err != cache.ErrKeyNotFound && err != cache.ErrItemExpired {
+		return false, err
+	}
+	return true, s.c.Put(key, true, d)
+}
+
+func (s *cacheSeen) release(key string) error {
+	err := s.c.Delete(key)
+	if err == cache.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+type storeSeen struct {
+	s               store.Store
+	database, table string
+}
+
+func (s *storeSeen) reserve(key string, d time.Duration) (bool, error) {
+	// the write fails if another instance has recorded the key
+	op := store.WriteOp(&store.Record{Key: key, Expiry: d}).IfVersion(0)
+	err := s.s.Batch([]store.Op{op}, store.BatchTo(s.database, s.table))
+	if err == store.ErrConflict {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *storeSeen) release(key string) error {
+	err := s.s.Delete(key, store.DeleteFrom(s.database, s.table))
+	if err == store.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// DedupSubscriber wraps a subscriber to drop messages with an ID it handled within the window,
+// making the handler idempotent for redeliveries and publisher retries. An ID is recorded before
+// the handler is called, dropping duplicates delivered meanwhile, and forgotten if the handler
+// fails so the message is handled again. The store must support Batch to record IDs atomically.
+// IDs are kept per topic and subscriber queue, subscribers to the same topic outside a queue
+// should not share a cache or table.
+// Batch subscribers are not deduplicated, the batch is handled as one message without an ID.
+func DedupSubscriber(opts ...DedupOption) server.SubscriberWrapper {
+	options := DedupOptions{
+		Window: DefaultDedupWindow,
+		Header: DefaultDedupHeader,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var s seen
+	if options.Store != nil {
+		s = &storeSeen{s: options.Store, database: options.Database, table: options.Table}
+	} else {
+		c := options.Cache
+		if c == nil {
+			c = cache.NewCache()
+		}
+		s = &cacheSeen{c: c}
+	}
+
+	return func(fn server.SubscriberFunc) server.SubscriberFunc {
+		return func(ctx context.Context, msg server.Message) error {
+			id := msg.Header()[options.Header]
+			// messages without an ID can't be deduplicated
+			if len(id) == 0 {
+				return fn(ctx, msg)
+			}
+
+			// the subscribers of each queue handle the message once
+			key := msg.Topic() + "/" + id
+			if sub, ok := server.SubscriberFromContext(ctx); ok && len(sub.Options().Queue) > 0 {
+				key = sub.Options().Queue + "/" + key
+			}
+			if ok, err := s.reserve(key, options.Window); err != nil {
+				return err
+			} else if !ok {
+				return nil
+			}
+
+			if err := fn(ctx, msg); err != nil {
+				if rerr := s.release(key); rerr != nil && logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("[dedup] failed to release %v: %v", key, rerr)
+				}
+				return err
+			}
+
+			return nil
+		}
+	}
+}
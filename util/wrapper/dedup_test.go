@@ -0,0 +1,144 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/cache"
+	"go-micro.dev/v4/codec"
+	"go-micro.dev/v4/server"
+	"go-micro.dev/v4/store"
+)
+
+type testMessage struct {
+	topic  string
+	header map[string]string
+}
+
+func (m *testMessage) Topic() string             { return m.topic }
+func (m *testMessage) Payload() interface{}      { return nil }
+func (m *testMessage) ContentType() string       { return "application/json" }
+func (m *testMessage) Header() map[string]string { return m.header }
+func (m *testMessage) Body() []byte              { return nil }
+func (m *testMessage) Codec() codec.Reader       { return nil }
+
+func TestDedupSubscriber(t *testing.T) {
+	testData := map[string]DedupOption{
+		"cache": DedupCache(cache.NewCache()),
+		"store": DedupStore(store.NewMemoryStore(), "test", "dedup"),
+	}
+
+	for name, opt := range testData {
+		t.Run(name, func(t *testing.T) {
+			var handled int
+			var fail bool
+			fn := DedupSubscriber(opt, DedupWindow(time.Millisecond*100))(func(ctx context.Context, msg server.Message) error {
+				if fail {
+					return errors.New("failed")
+				}
+				handled++
+				return nil
+			})
+
+			msg := func(topic, id string) server.Message {
+				return &testMessage{topic: topic, header: map[string]string{"Micro-Id": id}}
+			}
+
+			// a failed message is handled again when redelivered
+			fail = true
+			if err := fn(context.Background(), msg("orders", "1")); err == nil {
+				t.Fatal("Expected the handler error")
+			}
+			fail = false
+
+			for _, m := range []server.Message{
+				msg("orders", "1"),
+				msg("orders", "1"),
+				msg("orders", "2"),
+				msg("payments", "1"),
+				&testMessage{topic: "orders"},
+				&testMessage{topic: "orders"},
+			} {
+				if err := fn(context.Background(), m); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			if handled != 5 {
+				t.Fatalf("Expected 5 messages handled, got %d", handled)
+			}
+
+			// the ID is forgotten after the window
+			time.Sleep(time.Millisecond * 200)
+			if err := fn(context.Background(), msg("orders", "1")); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if handled != 6 {
+				t.Fatalf("Expected the message to be handled after the window, got %d", handled)
+			}
+		})
+	}
+}
+
+func TestDedupSubscriberQueue(t *testing.T) {
+	var handled int
+	fn := DedupSubscriber(DedupCache(cache.NewCache()))(func(ctx context.Context, msg server.Message) error {
+		handled++
+		return nil
+	})
+
+	queue := func(q string) context.Context {
+		sub := server.NewSubscriber("orders", func(context.Context, interface{}) error { return nil }, server.SubscriberQueue(q))
+		return server.NewSubscriberContext(context.Background(), sub)
+	}
+	msg := &testMessage{topic: "orders", header: map[string]string{"Micro-Id": "1"}}
+
+	// each queue handles the message once
+	for _, ctx := range []context.Context{queue("billing"), queue("shipping"), queue("billing")} {
+		if err := fn(ctx, msg); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if handled != 2 {
+		t.Fatalf("Expected the message handled once by each queue, got %d", handled)
+	}
+}
+
+func TestDedupSubscriberConcurrent(t *testing.T) {
+	testData := map[string]DedupOption{
+		"cache": DedupCache(cache.NewCache()),
+		"store": DedupStore(store.NewMemoryStore(), "test", "dedup"),
+	}
+
+	for name, opt := range testData {
+		t.Run(name, func(t *testing.T) {
+			var handled int32
+			fn := DedupSubscriber(opt)(func(ctx context.Context, msg server.Message) error {
+				atomic.AddInt32(&handled, 1)
+				time.Sleep(time.Millisecond * 50)
+				return nil
+			})
+
+			// duplicates delivered while the first is being handled are dropped
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					msg := &testMessage{topic: "orders", header: map[string]string{"Micro-Id": "1"}}
+					if err := fn(context.Background(), msg); err != nil {
+						t.Errorf("Unexpected error: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if n := atomic.LoadInt32(&handled); n != 1 {
+				t.Fatalf("Expected the message to be handled once, got %d", n)
+			}
+		})
+	}
+}